@@ -28,8 +28,14 @@ from models.schemas import (
     ServiceFields,
     PurchaseFields
 )
+from core.request_log import log
 
 
+# The Go server's direct backend sends the same prompts (llmSystemPrompt and
+# llmExtractionPrompt in internal/service/llm_extractor.go). Change both
+# together; a Go test fails when they differ.
+SYSTEM_PROMPT = "你是一位专业的法律合同信息提取专家。请严格按照指定的JSON格式提取信息，字段名必须完全一致。"
+
 EXTRACTION_PROMPT = """你是一位专业的法律合同分析师。请从以下合同文本中提取关键信息，并以JSON格式返回。
 
 合同文本:
@@ -168,6 +174,7 @@ EXTRACTION_PROMPT = """你是一位专业的法律合同分析师。请从以下
 2. 如果是服务合同(service)，type_specific.service_fields必须包含以下字段：service_content, service_standard, service_period, service_fee, acceptance_criteria, confidence
 3. 如果无法提取某字段，填写"Unknown"，数组填写[]
 4. 只返回JSON，不要额外解释
+5. 每个部分的source_references列出支持该部分信息的原文，格式为{{"page": 页码, "paragraph": 段落序号, "text": "原文"}}，text必须从合同文本中逐字摘录，不得改写或概括
 """
 
 
@@ -180,7 +187,7 @@ class GLMExtractor:
     async def extract(self, request: ExtractionRequest) -> ExtractionResponse:
         prompt = EXTRACTION_PROMPT.format(document_text=request.document_text)
         
-        print(f"[DEBUG] Document text length: {len(request.document_text)}")
+        log("DEBUG", f"Document text length: {len(request.document_text)}")
         
         async with httpx.AsyncClient(timeout=120.0) as client:
             response = await client.post(
@@ -194,7 +201,7 @@ class GLMExtractor:
                     "messages": [
                         {
                             "role": "system",
-                            "content": "你是一位专业的法律合同信息提取专家。请严格按照指定的JSON格式提取信息，字段名必须完全一致。"
+                            "content": SYSTEM_PROMPT
                         },
                         {
                             "role": "user",
@@ -212,8 +219,8 @@ class GLMExtractor:
             result = response.json()
             content = result["choices"][0]["message"]["content"]
             
-            print(f"[DEBUG] AI Response preview: {content[:500]}...")
-            print(f"[DEBUG] AI Response full:\n{content}")
+            log("DEBUG", f"AI Response preview: {content[:500]}...")
+            log("DEBUG", f"AI Response full:\n{content}")
             
             return self._parse_response(content)
     
@@ -234,15 +241,15 @@ class GLMExtractor:
             if json_start >= 0 and json_end > json_start:
                 json_str = json_str[json_start:json_end]
             
-            print(f"[DEBUG] Cleaned JSON length: {len(json_str)}")
+            log("DEBUG", f"Cleaned JSON length: {len(json_str)}")
             
             try:
                 data = json.loads(json_str)
             except json.JSONDecodeError as e:
-                print(f"[DEBUG] First parse failed, trying to fix JSON: {e}")
-                print(f"[DEBUG] Problem area: ...{json_str[max(0, e.pos-50):e.pos+50]}...")
+                log("DEBUG", f"First parse failed, trying to fix JSON: {e}")
+                log("DEBUG", f"Problem area: ...{json_str[max(0, e.pos-50):e.pos+50]}...")
                 json_str = self._fix_json_string(json_str)
-                print(f"[DEBUG] Fixed JSON length: {len(json_str)}")
+                log("DEBUG", f"Fixed JSON length: {len(json_str)}")
                 data = json.loads(json_str)
             
             return ExtractionResponse(
@@ -261,10 +268,10 @@ class GLMExtractor:
                 ocr_required=False
             )
         except json.JSONDecodeError as e:
-            print(f"[ERROR] JSON decode error: {e}")
+            log("ERROR", f"JSON decode error: {e}")
             return self._create_default_response()
         except Exception as e:
-            print(f"[ERROR] Parse error: {e}")
+            log("ERROR", f"Parse error: {e}")
             return self._create_default_response()
     
     def _fix_json_string(self, json_str: str) -> str:
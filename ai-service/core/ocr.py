@@ -1,18 +1,33 @@
 import base64
 import httpx
-from typing import Optional, List
+from typing import Optional, List, Tuple, Iterator
 import tempfile
 import os
 from dotenv import load_dotenv
 from pathlib import Path
 import io
 
+from core.request_log import log
+
 env_path = Path(__file__).parent.parent / ".env"
 load_dotenv(env_path)
 
 OCR_PROMPT = """请从这张图片中提取所有文字内容。只返回提取的文字，不要添加任何解释。保持原有的段落结构。"""
 
 
+def estimate_confidence(text: Optional[str]) -> float:
+    """GLM-4V does not report recognition confidence, so estimate it from the
+    share of characters that look like real text (CJK, letters, digits and
+    common punctuation) rather than replacement or control characters."""
+    if not text:
+        return 0.0
+    chars = [c for c in text if not c.isspace()]
+    if not chars:
+        return 0.0
+    good = sum(1 for c in chars if c.isalnum() or '\u4e00' <= c <= '\u9fff' or c in "，。、；：？！“”‘’（）《》【】,.;:?!\"'()-/%¥$")
+    return round(good / len(chars), 2)
+
+
 class GLMOCR:
     def __init__(self, api_key: Optional[str] = None):
         self.api_key = api_key or os.getenv("ZHIPU_API_KEY", "")
@@ -24,7 +39,7 @@ class GLMOCR:
         
         img = Image.open(io.BytesIO(image_data))
         
-        if img.mode in ('RGBA', 'P'):
+        if img.mode not in ('RGB', 'L'):
             img = img.convert('RGB')
         
         ratio = min(max_size / img.width, max_size / img.height)
@@ -39,7 +54,7 @@ class GLMOCR:
         
     async def extract_text(self, image_data: bytes) -> str:
         compressed_data = self._compress_image(image_data)
-        print(f"[DEBUG] Image compressed: {len(image_data)} -> {len(compressed_data)} bytes")
+        log("DEBUG", f"Image compressed: {len(image_data)} -> {len(compressed_data)} bytes")
         
         base64_image = base64.b64encode(compressed_data).decode('utf-8')
         
@@ -89,50 +104,82 @@ class GLMOCR:
                 text = await self.extract_text(image_data)
                 results.append(f"--- 第{i+1}页 ---\n{text}")
             except Exception as e:
-                print(f"[ERROR] Failed to extract text from image {i+1}: {e}")
+                log("ERROR", f"Failed to extract text from image {i+1}: {e}")
                 results.append(f"--- 第{i+1}页 ---\n[OCR识别失败]")
         
         return "\n\n".join(results)
     
+    async def extract_pages_from_pdf(self, pdf_data: bytes, pages: Optional[List[int]] = None) -> List[Tuple[int, Optional[str]]]:
+        with tempfile.NamedTemporaryFile(suffix='.pdf', delete=False) as tmp_pdf:
+            tmp_pdf.write(pdf_data)
+            tmp_pdf_path = tmp_pdf.name
+
+        try:
+            return await self.extract_pages_from_pdf_file(tmp_pdf_path, pages)
+        finally:
+            os.unlink(tmp_pdf_path)
+
+    async def extract_pages_from_pdf_file(self, pdf_path: str, pages: Optional[List[int]] = None) -> List[Tuple[int, Optional[str]]]:
+        """OCR the given pages of a PDF on disk. Pages are rendered and sent
+        one at a time so memory does not grow with the page count."""
+        results = []
+        for page_num, image_data in self._render_pdf_pages(pdf_path, pages):
+            try:
+                text = await self.extract_text(image_data)
+            except Exception as e:
+                log("ERROR", f"Failed to extract text from page {page_num}: {e}")
+                text = None
+            results.append((page_num, text))
+
+        if not results:
+            raise Exception("Failed to convert PDF to images")
+
+        return results
+
     async def extract_text_from_pdf(self, pdf_data: bytes) -> str:
-        print(f"[DEBUG] Starting PDF to image conversion, data size: {len(pdf_data)} bytes")
+        log("DEBUG", f"Starting PDF to image conversion, data size: {len(pdf_data)} bytes")
         images = await self._pdf_to_images(pdf_data)
         
         if not images:
             raise Exception("Failed to convert PDF to images")
         
-        print(f"[DEBUG] Converted PDF to {len(images)} images")
+        log("DEBUG", f"Converted PDF to {len(images)} images")
         
-        return await self.extract_text_from_images(images)
+        return await self.extract_text_from_images([image for _, image in images])
     
-    async def _pdf_to_images(self, pdf_data: bytes) -> List[bytes]:
-        import fitz
-        
-        images = []
-        
+    async def _pdf_to_images(self, pdf_data: bytes, pages: Optional[List[int]] = None) -> List[Tuple[int, bytes]]:
         with tempfile.NamedTemporaryFile(suffix='.pdf', delete=False) as tmp_pdf:
             tmp_pdf.write(pdf_data)
             tmp_pdf_path = tmp_pdf.name
         
         try:
-            doc = fitz.open(tmp_pdf_path)
-            
-            for page_num in range(len(doc)):
+            return list(self._render_pdf_pages(tmp_pdf_path, pages))
+        finally:
+            os.unlink(tmp_pdf_path)
+
+    def _render_pdf_pages(self, pdf_path: str, pages: Optional[List[int]] = None) -> Iterator[Tuple[int, bytes]]:
+        import fitz
+        
+        try:
+            doc = fitz.open(pdf_path)
+        except Exception as e:
+            log("ERROR", f"PDF to image conversion failed: {e}")
+            raise Exception(f"PDF conversion failed: {e}")
+        
+        try:
+            page_numbers = pages or range(1, len(doc) + 1)
+            for page_no in page_numbers:
+                if page_no < 1 or page_no > len(doc):
+                    continue
+                page_num = page_no - 1
                 page = doc[page_num]
                 
                 mat = fitz.Matrix(1.5, 1.5)
                 pix = page.get_pixmap(matrix=mat)
                 
                 img_data = pix.tobytes("jpeg")
-                images.append(img_data)
                 
-                print(f"[DEBUG] Converted page {page_num + 1} to image, size: {len(img_data)} bytes")
-            
-            doc.close()
-        except Exception as e:
-            print(f"[ERROR] PDF to image conversion failed: {e}")
-            raise Exception(f"PDF conversion failed: {e}")
+                log("DEBUG", f"Converted page {page_num + 1} to image, size: {len(img_data)} bytes")
+                yield page_no, img_data
         finally:
-            os.unlink(tmp_pdf_path)
-        
-        return images
+            doc.close()
@@ -0,0 +1,11 @@
+"""Request-scoped logging: each line carries the X-Request-ID of the call
+being served, so it can be matched with the Go backend's logs."""
+import contextvars
+
+REQUEST_ID_HEADER = "X-Request-ID"
+
+request_id: contextvars.ContextVar[str] = contextvars.ContextVar("request_id", default="-")
+
+
+def log(level: str, message: str) -> None:
+    print(f"[{level}] [{request_id.get()}] {message}")
@@ -1,17 +1,23 @@
 import os
+import shutil
+import tempfile
+import time
+import uuid
 from dotenv import load_dotenv
 from pathlib import Path
 
 env_path = Path(__file__).parent / ".env"
 load_dotenv(env_path)
 
-from fastapi import FastAPI, HTTPException, UploadFile, File
+from fastapi import FastAPI, HTTPException, UploadFile, File, Form, Request
+from typing import Optional
 from fastapi.middleware.cors import CORSMiddleware
 from contextlib import asynccontextmanager
 
-from models.schemas import ExtractionRequest, ExtractionResponse, OCRResponse
+from models.schemas import ExtractionRequest, ExtractionResponse, OCRResponse, OCRPage, OCRContractResponse
 from core.extractor import GLMExtractor
-from core.ocr import GLMOCR
+from core.ocr import GLMOCR, estimate_confidence
+from core.request_log import REQUEST_ID_HEADER, log, request_id
 
 
 @asynccontextmanager
@@ -39,6 +45,22 @@ app.add_middleware(
 )
 
 
+@app.middleware("http")
+async def tag_request_id(request: Request, call_next):
+    # The Go backend sends an ID per call (retries reuse it); every log line
+    # written while serving the request carries it.
+    rid = request.headers.get(REQUEST_ID_HEADER) or uuid.uuid4().hex
+    token = request_id.set(rid)
+    start = time.monotonic()
+    try:
+        response = await call_next(request)
+        response.headers[REQUEST_ID_HEADER] = rid
+        log("INFO", f"{request.method} {request.url.path} {response.status_code} {(time.monotonic() - start) * 1000:.0f}ms")
+        return response
+    finally:
+        request_id.reset(token)
+
+
 @app.get("/health")
 async def health_check():
     return {"status": "healthy", "service": "ai-service"}
@@ -54,39 +76,61 @@ async def extract_contract_info(request: ExtractionRequest):
         raise HTTPException(status_code=500, detail=str(e))
 
 
+@app.get("/api/v1/ocr/contract", response_model=OCRContractResponse)
+async def ocr_contract():
+    return OCRContractResponse()
+
+
+async def _ocr_image(image_data: bytes) -> OCRResponse:
+    ocr: GLMOCR = app.state.ocr
+    text = await ocr.extract_text(image_data)
+    return OCRResponse(text=text, pages=[OCRPage(page=1, text=text, confidence=estimate_confidence(text))])
+
+
 @app.post("/api/v1/ocr", response_model=OCRResponse)
 async def perform_ocr(file: UploadFile = File(...)):
     try:
-        image_data = await file.read()
-        ocr: GLMOCR = app.state.ocr
-        text = await ocr.extract_text(image_data)
-        return OCRResponse(text=text)
+        if file.content_type and not file.content_type.startswith("image/") and file.content_type != "application/octet-stream":
+            raise HTTPException(status_code=415, detail=f"unsupported content type: {file.content_type}")
+        log("DEBUG", f"Received image {file.filename} ({file.content_type})")
+        return await _ocr_image(await file.read())
+    except HTTPException:
+        raise
     except Exception as e:
         raise HTTPException(status_code=500, detail=str(e))
 
 
 @app.post("/api/v1/ocr/raw", response_model=OCRResponse)
-async def perform_ocr_raw(file: UploadFile = File(...)):
+async def perform_ocr_raw(request: Request):
     try:
-        image_data = await file.read()
-        ocr: GLMOCR = app.state.ocr
-        text = await ocr.extract_text(image_data)
-        return OCRResponse(text=text)
+        return await _ocr_image(await request.body())
     except Exception as e:
         raise HTTPException(status_code=500, detail=str(e))
 
 
 @app.post("/api/v1/ocr/pdf", response_model=OCRResponse)
-async def perform_pdf_ocr(file: UploadFile = File(...)):
+async def perform_pdf_ocr(file: UploadFile = File(...), pages: Optional[str] = Form(None)):
     try:
-        pdf_data = await file.read()
-        print(f"[DEBUG] Received PDF data, size: {len(pdf_data)} bytes")
+        # Spool the upload to disk instead of reading it into memory; scanned
+        # contracts can run to hundreds of megabytes.
+        with tempfile.NamedTemporaryFile(suffix=".pdf", delete=False) as tmp_pdf:
+            shutil.copyfileobj(file.file, tmp_pdf)
+            tmp_pdf_path = tmp_pdf.name
+        log("DEBUG", f"Received PDF data, size: {os.path.getsize(tmp_pdf_path)} bytes")
         ocr: GLMOCR = app.state.ocr
-        text = await ocr.extract_text_from_pdf(pdf_data)
-        print(f"[DEBUG] OCR result length: {len(text)} chars")
-        return OCRResponse(text=text)
+        page_numbers = [int(p) for p in pages.split(",") if p.strip()] if pages else None
+        try:
+            page_results = await ocr.extract_pages_from_pdf_file(tmp_pdf_path, page_numbers)
+        finally:
+            os.unlink(tmp_pdf_path)
+        text = "\n\n".join(f"--- 第{p}页 ---\n{t if t is not None else '[OCR识别失败]'}" for p, t in page_results)
+        log("DEBUG", f"OCR result length: {len(text)} chars")
+        return OCRResponse(text=text, pages=[
+            OCRPage(page=p, text=t, confidence=estimate_confidence(t)) if t is not None else OCRPage(page=p, failed=True)
+            for p, t in page_results
+        ])
     except Exception as e:
-        print(f"[ERROR] PDF OCR failed: {e}")
+        log("ERROR", f"PDF OCR failed: {e}")
         raise HTTPException(status_code=500, detail=str(e))
 
 
@@ -3,6 +3,12 @@ from typing import List, Optional
 from enum import Enum
 
 
+# Version of the OCR request/response contract shared with the Go client
+# (model.OCRContractVersion). Bump both together when the multipart fields or
+# the response shape change.
+OCR_CONTRACT_VERSION = "1"
+
+
 class ContractType(str, Enum):
     PURCHASE = "purchase"
     LEASE = "lease"
@@ -13,8 +19,8 @@ class ContractType(str, Enum):
 
 
 class SourceRef(BaseModel):
-    page: int
-    paragraph: int
+    page: int = 0
+    paragraph: int = 0
     text: str
 
 
@@ -212,5 +218,18 @@ class OCRRequest(BaseModel):
     pass
 
 
+class OCRPage(BaseModel):
+    page: int
+    text: str = ""
+    confidence: float = 0.0
+    failed: bool = False
+
+
 class OCRResponse(BaseModel):
+    contract_version: str = OCR_CONTRACT_VERSION
     text: str
+    pages: List[OCRPage] = []
+
+
+class OCRContractResponse(BaseModel):
+    version: str = OCR_CONTRACT_VERSION
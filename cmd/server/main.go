@@ -1,18 +1,28 @@
 package main
 
 import (
+	"context"
 	"contract-key-extractor/internal/config"
 	"contract-key-extractor/internal/handler"
 	"contract-key-extractor/internal/parser"
 	"contract-key-extractor/internal/service"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// shutdownTimeout bounds how long open requests, such as event streams,
+// may delay shutdown.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	cfg, err := config.Load("./configs/config.yaml")
 	if err != nil {
@@ -26,11 +36,30 @@ func main() {
 		os.Exit(1)
 	}
 
-	parserManager := parser.NewParserManager(logger)
+	parserManager := parser.NewParserManager(&cfg.Parser, logger)
 
 	aiClient := service.NewAIServiceClient(&cfg.AIService, logger)
+	if !aiClient.Enabled() {
+		logger.Warn("no AI service configured, OCR is disabled")
+	} else if err := aiClient.CheckOCRContract(context.Background()); err != nil {
+		logger.Warn("OCR contract check failed", zap.Error(err))
+	}
 
-	extractionService := service.NewExtractionService(parserManager, aiClient, cfg, logger)
+	extractor, err := service.NewExtractor(cfg, aiClient, logger)
+	if err != nil {
+		logger.Fatal("failed to set up extractor", zap.Error(err))
+	}
+
+	taskStore, err := service.NewTaskStore(&cfg.Storage)
+	if err != nil {
+		logger.Fatal("failed to open task store", zap.Error(err))
+	}
+	defer taskStore.Close()
+
+	extractionService := service.NewExtractionService(parserManager, aiClient, extractor, taskStore, cfg, logger)
+	if err := extractionService.RecoverTasks(); err != nil {
+		logger.Error("failed to recover tasks", zap.Error(err))
+	}
 
 	h := handler.NewHandler(extractionService, cfg.Upload.Path, logger)
 
@@ -49,7 +78,13 @@ func main() {
 	{
 		api.POST("/upload", h.UploadFiles)
 		api.GET("/task/:task_id", h.GetTaskStatus)
+		api.DELETE("/task/:task_id", h.CancelTask)
+		api.POST("/task/:task_id/pause", h.PauseTask)
+		api.POST("/task/:task_id/resume", h.ResumeTask)
 		api.GET("/task/:task_id/results", h.GetTaskResults)
+		api.GET("/task/:task_id/files", h.GetTaskFiles)
+		api.GET("/task/:task_id/events", h.StreamTaskEvents)
+		api.GET("/task/:task_id/webhooks", h.GetTaskWebhooks)
 		api.GET("/task/:task_id/download", h.DownloadResult)
 	}
 
@@ -63,7 +98,24 @@ func main() {
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 	logger.Info("starting server", zap.String("address", addr))
 
-	if err := router.Run(addr); err != nil {
-		logger.Fatal("failed to start server", zap.Error(err))
+	server := &http.Server{Addr: addr, Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("failed to start server", zap.Error(err))
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	// Pending webhook deliveries are stored before the task store closes,
+	// and resumed on the next start.
+	logger.Info("shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("failed to shut down server", zap.Error(err))
 	}
+	extractionService.Close()
 }
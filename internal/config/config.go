@@ -15,6 +15,10 @@ type Config struct {
 	Output    OutputConfig    `yaml:"output"`
 	LLM       LLMConfig       `yaml:"llm"`
 	Logging   LoggingConfig   `yaml:"logging"`
+	Parser    ParserConfig    `yaml:"parser"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Worker    WorkerConfig    `yaml:"worker"`
+	Webhook   WebhookConfig   `yaml:"webhook"`
 }
 
 type ServerConfig struct {
@@ -23,26 +27,76 @@ type ServerConfig struct {
 	Mode string `yaml:"mode"`
 }
 
+// AIServiceConfig locates the AI service. Timeout is the base time allowed
+// for a request, in seconds; OCR requests get PageTimeout more seconds per
+// page, and extraction more time for longer documents. Failed requests are
+// retried up to MaxRetries times, 3 when it is not set and none when it is 0;
+// after BreakerThreshold consecutive failures extraction pauses, and the
+// service is probed again every BreakerCooldown seconds.
 type AIServiceConfig struct {
-	Host    string `yaml:"host"`
-	Port    int    `yaml:"port"`
-	Timeout int    `yaml:"timeout"`
+	Host             string `yaml:"host"`
+	Port             int    `yaml:"port"`
+	Timeout          int    `yaml:"timeout"`
+	PageTimeout      int    `yaml:"page_timeout"`
+	MaxRetries       *int   `yaml:"max_retries"`
+	BreakerThreshold int    `yaml:"breaker_threshold"`
+	BreakerCooldown  int    `yaml:"breaker_cooldown"`
 }
 
 type UploadConfig struct {
-	Path    string `yaml:"path"`
-	MaxSize int64  `yaml:"max_size"`
+	Path              string `yaml:"path"`
+	MaxSize           int64  `yaml:"max_size"`
+	MaxArchiveEntries int    `yaml:"max_archive_entries"`
+	MaxExtractedSize  int64  `yaml:"max_extracted_size"`
 }
 
 type OutputConfig struct {
 	Path string `yaml:"path"`
 }
 
+// LLMConfig describes the language model. Backend selects who talks to it:
+// "ai_service" (the default) leaves extraction to the AI service, "direct"
+// has the server call the OpenAI-compatible chat endpoint at BaseURL itself.
+// BaseURL may be left empty for known providers. Timeout is in seconds.
 type LLMConfig struct {
 	Provider string `yaml:"provider"`
 	APIKey   string `yaml:"api_key"`
 	Model    string `yaml:"model"`
 	OCRModel string `yaml:"ocr_model"`
+	Backend  string `yaml:"backend"`
+	BaseURL  string `yaml:"base_url"`
+	Timeout  int    `yaml:"timeout"`
+}
+
+type ParserConfig struct {
+	WordRevisionMode  string `yaml:"word_revision_mode"`
+	PDFMaxDecodedSize int64  `yaml:"pdf_max_decoded_size"`
+}
+
+// StorageConfig selects where tasks and their results are kept. An empty
+// Path keeps them in memory, and they are lost on restart.
+type StorageConfig struct {
+	Path string `yaml:"path"`
+}
+
+// WorkerConfig bounds extraction work. Workers is the number of files
+// processed at once across all tasks; MaxQueuedFiles is the number of files
+// that may wait for a worker before new uploads are refused.
+type WorkerConfig struct {
+	Workers        int `yaml:"workers"`
+	MaxQueuedFiles int `yaml:"max_queued_files"`
+}
+
+// WebhookConfig sets the webhooks notified about every task, in addition to
+// the callback URL given with an upload. Payloads are signed with Secret when
+// it is set. Results whose overall confidence is below LowConfidence are
+// reported. Timeout is in seconds.
+type WebhookConfig struct {
+	URLs          []string `yaml:"urls"`
+	Secret        string   `yaml:"secret"`
+	MaxAttempts   int      `yaml:"max_attempts"`
+	Timeout       int      `yaml:"timeout"`
+	LowConfidence float64  `yaml:"low_confidence"`
 }
 
 type LoggingConfig struct {
@@ -79,10 +133,17 @@ func Load(configPath string) (*Config, error) {
 }
 
 func (c *Config) expandEnvVars() {
-	if c.LLM.APIKey != "" && len(c.LLM.APIKey) > 3 && c.LLM.APIKey[0] == '$' {
-		envName := c.LLM.APIKey[2 : len(c.LLM.APIKey)-1]
-		c.LLM.APIKey = os.Getenv(envName)
+	c.LLM.APIKey = expandEnv(c.LLM.APIKey)
+	c.Webhook.Secret = expandEnv(c.Webhook.Secret)
+}
+
+// expandEnv replaces a value of the form "${NAME}" with the environment
+// variable NAME.
+func expandEnv(value string) string {
+	if value != "" && len(value) > 3 && value[0] == '$' {
+		return os.Getenv(value[2 : len(value)-1])
 	}
+	return value
 }
 
 func Get() *Config {
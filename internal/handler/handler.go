@@ -3,14 +3,24 @@ package handler
 import (
 	"contract-key-extractor/internal/model"
 	"contract-key-extractor/internal/service"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// sseHeartbeatInterval keeps idle event streams from being closed by
+// proxies.
+const sseHeartbeatInterval = 15 * time.Second
+
 type Handler struct {
 	extractionService *service.ExtractionService
 	uploadPath        string
@@ -38,14 +48,27 @@ func (h *Handler) UploadFiles(c *gin.Context) {
 		return
 	}
 
-	if err := os.MkdirAll(h.uploadPath, 0755); err != nil {
+	callbackURL := c.PostForm("callback_url")
+	if callbackURL != "" {
+		if err := service.CheckCallbackURL(c.Request.Context(), callbackURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Every upload gets its own directory, so files with the same name never
+	// replace each other, including the file of a task that is recovered
+	// after a restart.
+	uploadDir := filepath.Join(h.uploadPath, uuid.New().String())
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create upload directory"})
 		return
 	}
 
 	var filePaths []string
+	used := map[string]bool{}
 	for _, file := range files {
-		dst := filepath.Join(h.uploadPath, file.Filename)
+		dst := filepath.Join(uploadDir, uniqueUploadName(used, file.Filename))
 		if err := c.SaveUploadedFile(file, dst); err != nil {
 			h.logger.Error("failed to save file", zap.String("file", file.Filename), zap.Error(err))
 			continue
@@ -53,7 +76,17 @@ func (h *Handler) UploadFiles(c *gin.Context) {
 		filePaths = append(filePaths, dst)
 	}
 
-	task, err := h.extractionService.ProcessFiles(filePaths)
+	imageSet := c.PostForm("image_set") == "true"
+
+	task, err := h.extractionService.ProcessFiles(filePaths, imageSet, callbackURL)
+	if err != nil {
+		os.RemoveAll(uploadDir)
+	}
+	if errors.Is(err, service.ErrQueueFull) {
+		c.Header("Retry-After", "60")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -67,6 +100,19 @@ func (h *Handler) UploadFiles(c *gin.Context) {
 	})
 }
 
+// uniqueUploadName returns the base name of an uploaded file, numbered when
+// the same request already contained a file of that name.
+func uniqueUploadName(used map[string]bool, filename string) string {
+	base := filepath.Base(filename)
+	ext := filepath.Ext(base)
+	name := base
+	for n := 2; used[name]; n++ {
+		name = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(base, ext), n, ext)
+	}
+	used[name] = true
+	return name
+}
+
 func (h *Handler) GetTaskStatus(c *gin.Context) {
 	taskID := c.Param("task_id")
 
@@ -77,15 +123,16 @@ func (h *Handler) GetTaskStatus(c *gin.Context) {
 	}
 
 	response := model.TaskStatus{
-		TaskID:     task.ID,
-		Status:     task.Status,
-		Progress:   task.Progress,
-		TotalFiles: task.TotalFiles,
-		Processed:  task.Processed,
-		Failed:     task.Failed,
-		ResultPath: task.ResultPath,
-		Error:      task.Error,
-		CreatedAt:  task.CreatedAt.Format("2006-01-02 15:04:05"),
+		TaskID:        task.ID,
+		Status:        task.Status,
+		Progress:      task.Progress,
+		TotalFiles:    task.TotalFiles,
+		Processed:     task.Processed,
+		Failed:        task.Failed,
+		QueuePosition: task.QueuePosition,
+		ResultPath:    task.ResultPath,
+		Error:         task.Error,
+		CreatedAt:     task.CreatedAt.Format("2006-01-02 15:04:05"),
 	}
 
 	if !task.CompletedAt.IsZero() {
@@ -95,6 +142,143 @@ func (h *Handler) GetTaskStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GetTaskFiles lists every file of a task with its status, including the
+// files that failed and have no result.
+func (h *Handler) GetTaskFiles(c *gin.Context) {
+	taskID := c.Param("task_id")
+
+	task, err := h.extractionService.GetTaskStatus(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	files := make([]model.FileStatus, len(task.Files))
+	for i, file := range task.Files {
+		files[i] = model.FileStatus{
+			Index:         i,
+			FileName:      filepath.Base(file.Paths[0]),
+			Paths:         file.Paths,
+			Archive:       file.Archive,
+			Group:         file.Group,
+			Status:        file.Status,
+			Parser:        file.Parser,
+			OCRUsed:       file.OCRUsed,
+			ErrorCategory: file.ErrorCategory,
+			Error:         file.Error,
+			Durations:     file.Durations,
+		}
+		if !file.StartedAt.IsZero() {
+			files[i].StartedAt = file.StartedAt.Format("2006-01-02 15:04:05")
+		}
+		if !file.CompletedAt.IsZero() {
+			files[i].CompletedAt = file.CompletedAt.Format("2006-01-02 15:04:05")
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"task_id": task.ID,
+		"files":   files,
+	})
+}
+
+// GetTaskWebhooks lists the webhook deliveries made for a task.
+func (h *Handler) GetTaskWebhooks(c *gin.Context) {
+	taskID := c.Param("task_id")
+
+	deliveries, err := h.extractionService.GetWebhookDeliveries(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":    taskID,
+		"deliveries": deliveries,
+	})
+}
+
+// StreamTaskEvents streams a task's progress as server-sent events. The
+// first event is the task's current status; the stream ends once the task
+// has finished, or with a dropped event if the client reads too slowly.
+func (h *Handler) StreamTaskEvents(c *gin.Context) {
+	taskID := c.Param("task_id")
+
+	events, unsubscribe, err := h.extractionService.Subscribe(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	defer unsubscribe()
+
+	task, err := h.extractionService.GetTaskStatus(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	current := service.StatusEvent(task)
+	c.SSEvent(current.Type, current)
+	c.Writer.Flush()
+	if service.IsFinalEvent(current) {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return !service.IsFinalEvent(event)
+		case <-heartbeat.C:
+			io.WriteString(w, ": ping\n\n")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// CancelTask stops a task and replies with its status.
+func (h *Handler) CancelTask(c *gin.Context) {
+	h.controlTask(c, h.extractionService.CancelTask)
+}
+
+// PauseTask pauses a task and replies with its status.
+func (h *Handler) PauseTask(c *gin.Context) {
+	h.controlTask(c, h.extractionService.PauseTask)
+}
+
+// ResumeTask resumes a paused task and replies with its status.
+func (h *Handler) ResumeTask(c *gin.Context) {
+	h.controlTask(c, h.extractionService.ResumeTask)
+}
+
+func (h *Handler) controlTask(c *gin.Context, action func(taskID string) error) {
+	err := action(c.Param("task_id"))
+	switch {
+	case errors.Is(err, service.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrTaskFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.GetTaskStatus(c)
+}
+
 func (h *Handler) GetTaskResults(c *gin.Context) {
 	taskID := c.Param("task_id")
 
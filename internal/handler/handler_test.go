@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"contract-key-extractor/internal/config"
+	"contract-key-extractor/internal/model"
+	"contract-key-extractor/internal/parser"
+	"contract-key-extractor/internal/service"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// newTestServer serves the API with a single worker, backed by an AI
+// service stub whose OCR requests block until they are cancelled or the
+// test ends. Each OCR request is announced on the returned channel.
+func newTestServer(t *testing.T, maxQueuedFiles int) (*httptest.Server, *service.ExtractionService, <-chan struct{}) {
+	t.Helper()
+	ocrStarted := make(chan struct{}, 10)
+	stop := make(chan struct{})
+	ai := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices a cancelled request once the body is read.
+		io.Copy(io.Discard, r.Body)
+		ocrStarted <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-stop:
+		}
+	}))
+	t.Cleanup(ai.Close)
+	t.Cleanup(func() { close(stop) })
+
+	host, port, _ := net.SplitHostPort(ai.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	cfg := &config.Config{
+		AIService: config.AIServiceConfig{Host: host, Port: portNumber, Timeout: 5},
+		Output:    config.OutputConfig{Path: t.TempDir()},
+		Worker:    config.WorkerConfig{Workers: 1, MaxQueuedFiles: maxQueuedFiles},
+	}
+	logger := zap.NewNop()
+	client := service.NewAIServiceClient(&cfg.AIService, logger)
+	s := service.NewExtractionService(parser.NewParserManager(&cfg.Parser, logger), client, client, service.NewMemoryTaskStore(), cfg, logger)
+	t.Cleanup(s.Close)
+
+	h := NewHandler(s, t.TempDir(), logger)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/upload", h.UploadFiles)
+	router.GET("/api/v1/task/:task_id/events", h.StreamTaskEvents)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, s, ocrStarted
+}
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func waitForOCR(t *testing.T, ocrStarted <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ocrStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OCR was not called")
+	}
+}
+
+// readTestEvents reads server-sent events until the stream ends, passing
+// each to handle.
+func readTestEvents(t *testing.T, body io.Reader, handle func(model.TaskEvent)) {
+	t.Helper()
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event model.TaskEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("bad event %q: %v", data, err)
+		}
+		handle(event)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func uploadTestPNG(t *testing.T, server *httptest.Server) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("files", "scan.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(testPNG(t))
+	form.Close()
+
+	resp, err := http.Post(server.URL+"/api/v1/upload", form.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestUploadFilesQueueFull(t *testing.T) {
+	server, _, ocrStarted := newTestServer(t, 1)
+
+	// The first file keeps the only worker busy and the second waits in the
+	// queue, which leaves no room for a third.
+	if resp := uploadTestPNG(t, server); resp.StatusCode != http.StatusOK {
+		t.Fatalf("first upload: %s", resp.Status)
+	}
+	waitForOCR(t, ocrStarted)
+	if resp := uploadTestPNG(t, server); resp.StatusCode != http.StatusOK {
+		t.Fatalf("second upload: %s", resp.Status)
+	}
+
+	resp := uploadTestPNG(t, server)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("third upload: %s, want 503", resp.Status)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+}
+
+func TestStreamTaskEventsEndsOnFinalEvent(t *testing.T) {
+	server, s, ocrStarted := newTestServer(t, 10)
+
+	path := filepath.Join(t.TempDir(), "scan.png")
+	if err := os.WriteFile(path, testPNG(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	task, err := s.ProcessFiles([]string{path}, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForOCR(t, ocrStarted)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(server.URL + "/api/v1/task/" + task.ID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var statuses []string
+	readTestEvents(t, resp.Body, func(event model.TaskEvent) {
+		if event.Type != "status" {
+			return
+		}
+		statuses = append(statuses, event.Status)
+		if len(statuses) == 1 {
+			if err := s.CancelTask(task.ID); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	if got := strings.Join(statuses, " "); got != "processing cancelled" {
+		t.Errorf("statuses = %s, want processing cancelled", got)
+	}
+}
@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type ContractType string
 
@@ -182,10 +185,20 @@ type PurchaseFields struct {
 	Confidence       float64 `json:"confidence"`
 }
 
+// SourceRef is a passage quoted as evidence for an extracted section. Page
+// and Paragraph come from the model and are replaced by the real location
+// once the quote is found in the document; Start and End are then character
+// offsets into ParsedDocument.Content. Verified is false for quotes that
+// could not be found, which are likely hallucinated.
 type SourceRef struct {
-	Page      int    `json:"page"`
-	Paragraph int    `json:"paragraph"`
-	Text      string `json:"text"`
+	Page       int          `json:"page"`
+	Paragraph  int          `json:"paragraph"`
+	Text       string       `json:"text"`
+	Start      int          `json:"start"`
+	End        int          `json:"end"`
+	BBox       *BoundingBox `json:"bbox,omitempty"`
+	Verified   bool         `json:"verified"`
+	Similarity float64      `json:"similarity"`
 }
 
 type ExtractionResult struct {
@@ -207,13 +220,20 @@ type ExtractionResult struct {
 }
 
 type Metadata struct {
-	SourceFile          string    `json:"source_file"`
-	PageCount           int       `json:"page_count"`
-	ExtractionTime      time.Time `json:"extraction_time"`
-	ProcessingDuration  float64   `json:"processing_duration"`
-	OverallConfidence   float64   `json:"overall_confidence"`
-	OCRRequired         bool      `json:"ocr_required"`
-	ContractTypeChinese string    `json:"contract_type_chinese"`
+	SourceFile          string            `json:"source_file"`
+	PageCount           int               `json:"page_count"`
+	ExtractionTime      time.Time         `json:"extraction_time"`
+	ProcessingDuration  float64           `json:"processing_duration"`
+	OverallConfidence   float64           `json:"overall_confidence"`
+	OCRRequired         bool              `json:"ocr_required"`
+	ContractTypeChinese string            `json:"contract_type_chinese"`
+	Comments            []DocumentComment `json:"comments,omitempty"`
+	RevisionAuthors     []string          `json:"revision_authors,omitempty"`
+	Warnings            []string          `json:"warnings,omitempty"`
+	Parser              string            `json:"parser,omitempty"`
+	UnverifiedRefs      int               `json:"unverified_references,omitempty"`
+	Archive             string            `json:"archive,omitempty"`
+	Group               string            `json:"group,omitempty"`
 }
 
 type ExtractionRequest struct {
@@ -228,18 +248,125 @@ type ExtractionResponse struct {
 }
 
 type TaskStatus struct {
-	TaskID      string  `json:"task_id"`
-	Status      string  `json:"status"`
-	Progress    float64 `json:"progress"`
-	TotalFiles  int     `json:"total_files"`
-	Processed   int     `json:"processed"`
-	Failed      int     `json:"failed"`
-	ResultPath  string  `json:"result_path"`
-	Error       string  `json:"error,omitempty"`
-	CreatedAt   string  `json:"created_at"`
-	CompletedAt string  `json:"completed_at,omitempty"`
+	TaskID string `json:"task_id"`
+	// Status is one of pending, processing, paused, completed and
+	// cancelled.
+	Status     string  `json:"status"`
+	Progress   float64 `json:"progress"`
+	TotalFiles int     `json:"total_files"`
+	Processed  int     `json:"processed"`
+	Failed     int     `json:"failed"`
+	// QueuePosition is 1 when the task's next file is the next to be
+	// processed, 2 when one task is ahead of it, and so on; 0 when none of
+	// its files are waiting for a worker.
+	QueuePosition int    `json:"queue_position"`
+	ResultPath    string `json:"result_path"`
+	Error         string `json:"error,omitempty"`
+	CreatedAt     string `json:"created_at"`
+	CompletedAt   string `json:"completed_at,omitempty"`
+}
+
+// FileStatus reports how one file of a task was processed. An image set
+// counts as one file; FileName is then its first image.
+type FileStatus struct {
+	Index    int      `json:"index"`
+	FileName string   `json:"file_name"`
+	Paths    []string `json:"paths"`
+	Archive  string   `json:"archive,omitempty"`
+	Group    string   `json:"group,omitempty"`
+	// Status is one of pending, completed, failed and cancelled.
+	Status        string         `json:"status"`
+	Parser        string         `json:"parser,omitempty"`
+	OCRUsed       bool           `json:"ocr_used"`
+	ErrorCategory ErrorCategory  `json:"error_category,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	Durations     StageDurations `json:"durations"`
+	StartedAt     string         `json:"started_at,omitempty"`
+	CompletedAt   string         `json:"completed_at,omitempty"`
+}
+
+// StageDurations splits the processing time of a file by stage, in seconds.
+type StageDurations struct {
+	Parse   float64 `json:"parse"`
+	OCR     float64 `json:"ocr"`
+	Extract float64 `json:"extract"`
+	Total   float64 `json:"total"`
+}
+
+// TaskEvent reports a step of a task's progress on the event stream.
+//
+// A "status" event is sent when the task's status changes, and carries
+// ResultPath once the task has completed. A "stage" event is sent as a file
+// moves through parsing, ocr and extracting, and when it ends up exported,
+// with its result, or failed, with its error. A "dropped" event ends the
+// stream of a client that fell too far behind, which must reconnect.
+type TaskEvent struct {
+	Type          string            `json:"type"`
+	TaskID        string            `json:"task_id"`
+	Status        string            `json:"status,omitempty"`
+	Progress      float64           `json:"progress"`
+	Processed     int               `json:"processed"`
+	Failed        int               `json:"failed"`
+	FileIndex     *int              `json:"file_index,omitempty"`
+	FileName      string            `json:"file_name,omitempty"`
+	Stage         string            `json:"stage,omitempty"`
+	Result        *ExtractionResult `json:"result,omitempty"`
+	ErrorCategory ErrorCategory     `json:"error_category,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	ResultPath    string            `json:"result_path,omitempty"`
+	Time          string            `json:"time"`
+}
+
+// WebhookPayload is the body posted to webhooks. Event is task.completed,
+// task.cancelled, file.failed or file.low_confidence; the file fields are
+// set for file events, and Result for low-confidence results.
+type WebhookPayload struct {
+	Event         string            `json:"event"`
+	DeliveryID    string            `json:"delivery_id"`
+	TaskID        string            `json:"task_id"`
+	Status        string            `json:"status"`
+	TotalFiles    int               `json:"total_files"`
+	Processed     int               `json:"processed"`
+	Failed        int               `json:"failed"`
+	ResultPath    string            `json:"result_path,omitempty"`
+	FileIndex     *int              `json:"file_index,omitempty"`
+	FileName      string            `json:"file_name,omitempty"`
+	ErrorCategory ErrorCategory     `json:"error_category,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Confidence    float64           `json:"confidence,omitempty"`
+	Result        *ExtractionResult `json:"result,omitempty"`
+	Time          string            `json:"time"`
+}
+
+// WebhookDelivery records the delivery of one payload to one URL.
+// StatusCode and Error describe the last attempt. Pending is set while
+// further attempts are due; Payload is the body sent with each of them.
+type WebhookDelivery struct {
+	ID          string          `json:"id"`
+	TaskID      string          `json:"task_id"`
+	Event       string          `json:"event"`
+	URL         string          `json:"url"`
+	Attempts    int             `json:"attempts"`
+	Delivered   bool            `json:"delivered"`
+	Pending     bool            `json:"pending"`
+	StatusCode  int             `json:"status_code,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	LastAttempt time.Time       `json:"last_attempt"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
 }
 
+// ErrorCategory is the stage a file failed in.
+type ErrorCategory string
+
+const (
+	ErrorCategoryArchive    ErrorCategory = "archive"
+	ErrorCategoryRead       ErrorCategory = "read"
+	ErrorCategoryParse      ErrorCategory = "parse"
+	ErrorCategoryExtraction ErrorCategory = "extraction"
+	ErrorCategoryStorage    ErrorCategory = "storage"
+)
+
 type FileType string
 
 const (
@@ -247,15 +374,127 @@ const (
 	FileTypeExcel FileType = "excel"
 	FileTypeWord  FileType = "word"
 	FileTypeImage FileType = "image"
+	FileTypeText  FileType = "text"
+	FileTypeRTF   FileType = "rtf"
+	FileTypeODT   FileType = "odt"
+	FileTypeHTML  FileType = "html"
+	FileTypeEmail FileType = "email"
 )
 
 type ParsedDocument struct {
-	FileName   string   `json:"file_name"`
-	FileType   FileType `json:"file_type"`
-	Content    string   `json:"content"`
-	PageCount  int      `json:"page_count"`
-	IsScanned  bool     `json:"is_scanned"`
-	ImagePaths []string `json:"image_paths,omitempty"`
+	FileName   string             `json:"file_name"`
+	FileType   FileType           `json:"file_type"`
+	Content    string             `json:"content"`
+	PageCount  int                `json:"page_count"`
+	IsScanned  bool               `json:"is_scanned"`
+	ImagePaths []string           `json:"image_paths,omitempty"`
+	Pages      []PageContent      `json:"pages,omitempty"`
+	Comments   []DocumentComment  `json:"comments,omitempty"`
+	Revisions  []DocumentRevision `json:"revisions,omitempty"`
+	Tables     []SheetTable       `json:"tables,omitempty"`
+	Warnings   []string           `json:"warnings,omitempty"`
+	Parser     string             `json:"parser,omitempty"`
+	Layout     []LayoutPage       `json:"layout,omitempty"`
+}
+
+type PageContent struct {
+	Number        int        `json:"number"`
+	Content       string     `json:"content"`
+	IsScanned     bool       `json:"is_scanned"`
+	OCRFailed     bool       `json:"ocr_failed,omitempty"`
+	OCRConfidence float64    `json:"ocr_confidence,omitempty"`
+	Width         float64    `json:"width,omitempty"`
+	Height        float64    `json:"height,omitempty"`
+	Lines         []TextLine `json:"lines,omitempty"`
+}
+
+// BoundingBox is a rectangle in PDF user space: points measured from the
+// bottom-left corner of the page.
+type BoundingBox struct {
+	X0 float64 `json:"x0"`
+	Y0 float64 `json:"y0"`
+	X1 float64 `json:"x1"`
+	Y1 float64 `json:"y1"`
+}
+
+// TextLine is a line of text read from a PDF page together with its position.
+type TextLine struct {
+	Text string      `json:"text"`
+	BBox BoundingBox `json:"bbox"`
+}
+
+type BlockType string
+
+const (
+	BlockTypeParagraph BlockType = "paragraph"
+	BlockTypeTableCell BlockType = "table_cell"
+)
+
+// LayoutPage holds the blocks of one page in reading order. Width and Height
+// are the page size in points and are only known for PDFs.
+type LayoutPage struct {
+	Number int           `json:"number"`
+	Width  float64       `json:"width,omitempty"`
+	Height float64       `json:"height,omitempty"`
+	Blocks []LayoutBlock `json:"blocks"`
+}
+
+// LayoutBlock is a paragraph or table cell. Start and End are character
+// offsets into ParsedDocument.Content, End exclusive. Paragraph is the
+// 1-based position of the block's paragraph or table row on its page; cells
+// of one row share it. Table numbers tables across the whole document.
+type LayoutBlock struct {
+	Type      BlockType    `json:"type"`
+	Paragraph int          `json:"paragraph"`
+	Text      string       `json:"text"`
+	Start     int          `json:"start"`
+	End       int          `json:"end"`
+	Table     int          `json:"table,omitempty"`
+	Row       int          `json:"row,omitempty"`
+	Column    int          `json:"column,omitempty"`
+	BBox      *BoundingBox `json:"bbox,omitempty"`
+}
+
+type DocumentComment struct {
+	ID         string `json:"id"`
+	Author     string `json:"author"`
+	Date       string `json:"date,omitempty"`
+	Text       string `json:"text"`
+	AnchorText string `json:"anchor_text,omitempty"`
+}
+
+type DocumentRevision struct {
+	Type   string `json:"type"`
+	Author string `json:"author"`
+	Date   string `json:"date,omitempty"`
+	Text   string `json:"text"`
+}
+
+type SheetTable struct {
+	Name         string     `json:"name"`
+	Hidden       bool       `json:"hidden,omitempty"`
+	Rows         [][]string `json:"rows"`
+	HiddenRows   []int      `json:"hidden_rows,omitempty"`
+	MergedRanges []string   `json:"merged_ranges,omitempty"`
+}
+
+// OCRContractVersion is the version of the OCR request/response contract
+// shared with the AI service (see ai-service/models/schemas.py). Bump it on
+// both sides together whenever the multipart fields or the response shape
+// change.
+const OCRContractVersion = "1"
+
+type OCRPage struct {
+	Page       int     `json:"page"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	Failed     bool    `json:"failed,omitempty"`
+}
+
+type OCRResponse struct {
+	ContractVersion string    `json:"contract_version"`
+	Text            string    `json:"text"`
+	Pages           []OCRPage `json:"pages"`
 }
 
 type AIExtractionRequest struct {
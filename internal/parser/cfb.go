@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/msoleps"
+)
+
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+type cfbFile struct {
+	streams    map[string][]byte
+	properties map[string][]byte
+}
+
+// openCFB reads the named top-level streams and the property sets of a
+// compound file in place. Other streams, such as embedded pictures, are
+// skipped.
+func openCFB(r io.ReaderAt, size int64, names ...string) (*cfbFile, error) {
+	if !bytes.HasPrefix(readHead(r, size, len(cfbSignature)), cfbSignature) {
+		return nil, fmt.Errorf("not a compound file")
+	}
+
+	reader, err := mscfb.New(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open compound file: %w", err)
+	}
+
+	cfb := &cfbFile{
+		streams:    map[string][]byte{},
+		properties: map[string][]byte{},
+	}
+	for entry, err := reader.Next(); err == nil; entry, err = reader.Next() {
+		if len(entry.Path) > 0 || entry.Size == 0 {
+			continue
+		}
+		isProperties := msoleps.IsMSOLEPS(entry.Initial)
+		if !isProperties && !slices.Contains(names, entry.Name) {
+			continue
+		}
+		if entry.Size > maxPartSize {
+			return nil, fmt.Errorf("failed to read stream %s: %w", entry.Name, errPartTooLarge)
+		}
+		data, err := readPart(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream %s: %w", entry.Name, err)
+		}
+		if isProperties {
+			cfb.properties[entry.Name] = data
+		} else {
+			cfb.streams[entry.Name] = data
+		}
+	}
+
+	return cfb, nil
+}
+
+func (f *cfbFile) pageCount() int {
+	data, ok := f.properties["SummaryInformation"]
+	if !ok {
+		return 0
+	}
+	props, err := msoleps.NewFrom(bytes.NewReader(data))
+	if err != nil {
+		return 0
+	}
+	for _, prop := range props.Property {
+		if prop.Name == "PageCount" {
+			n, _ := strconv.Atoi(strings.TrimSpace(prop.String()))
+			return n
+		}
+	}
+	return 0
+}
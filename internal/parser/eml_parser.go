@@ -0,0 +1,242 @@
+package parser
+
+import (
+	"bytes"
+	"contract-key-extractor/internal/model"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// emlMaxDepth bounds how deeply nested multiparts are followed.
+const emlMaxDepth = 10
+
+var emlWordDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(charset)
+		if err != nil {
+			return nil, err
+		}
+		return enc.NewDecoder().Reader(input), nil
+	},
+}
+
+// EMLParser reads RFC 822 / MIME messages, typically contracts forwarded as
+// an email body or attachment. Attachments are handed back to parse, which
+// is normally ParserManager.Parse, so each one is read by its own parser.
+type EMLParser struct {
+	parse func(filePath string, fileData []byte) (*model.ParsedDocument, error)
+}
+
+func NewEMLParser(parse func(filePath string, fileData []byte) (*model.ParsedDocument, error)) *EMLParser {
+	return &EMLParser{parse: parse}
+}
+
+type emlAttachment struct {
+	name string
+	data []byte
+}
+
+type emlMessage struct {
+	body        []string
+	plain       bool
+	attachments []emlAttachment
+}
+
+func (p *EMLParser) Parse(filePath string, fileData []byte) (*model.ParsedDocument, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(fileData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read email: %w", err)
+	}
+
+	var message emlMessage
+	if err := message.walk(textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return nil, fmt.Errorf("failed to read email body: %w", err)
+	}
+
+	var content strings.Builder
+	content.WriteString("=== Email ===\n")
+	for _, name := range []string{"Subject", "From", "To", "Cc", "Date"} {
+		if value := msg.Header.Get(name); value != "" {
+			if decoded, err := emlWordDecoder.DecodeHeader(value); err == nil {
+				value = decoded
+			}
+			content.WriteString(name + ": " + value + "\n")
+		}
+	}
+	content.WriteString("\n")
+	content.WriteString(strings.TrimSpace(strings.Join(message.body, "\n\n")))
+
+	doc := &model.ParsedDocument{
+		FileName:   filepath.Base(filePath),
+		FileType:   model.FileTypeEmail,
+		PageCount:  1,
+		IsScanned:  false,
+		ImagePaths: nil,
+	}
+
+	for _, attachment := range message.attachments {
+		content.WriteString(fmt.Sprintf("\n\n=== Attachment: %s ===\n", attachment.name))
+
+		if p.parse == nil {
+			content.WriteString("[attachment not parsed]")
+			continue
+		}
+		parsed, err := p.parse(attachment.name, attachment.data)
+		if err != nil {
+			content.WriteString("[attachment not parsed]")
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("attachment %s: %v", attachment.name, err))
+			continue
+		}
+
+		content.WriteString(parsed.Content)
+		doc.PageCount += parsed.PageCount
+		doc.Tables = append(doc.Tables, parsed.Tables...)
+		doc.Comments = append(doc.Comments, parsed.Comments...)
+		doc.Revisions = append(doc.Revisions, parsed.Revisions...)
+		for _, warning := range parsed.Warnings {
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("attachment %s: %s", attachment.name, warning))
+		}
+		if parsed.IsScanned || len(ScannedPages(parsed)) > 0 {
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("attachment %s contains scanned pages that were not OCRed", attachment.name))
+		}
+	}
+
+	doc.Content = content.String()
+	return doc, nil
+}
+
+func (p *EMLParser) Supports(filePath string) bool {
+	return hasExtension(filePath, p.Extensions())
+}
+
+func (p *EMLParser) Extensions() []string {
+	return []string{".eml"}
+}
+
+func (p *EMLParser) Name() string {
+	return "email"
+}
+
+func (p *EMLParser) Capabilities() Capabilities {
+	return Capabilities{
+		MIMETypes: []string{"message/rfc822"},
+		Tables:    true,
+		Comments:  true,
+	}
+}
+
+func (m *emlMessage) walk(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	if depth > emlMaxDepth {
+		return nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	// Keep whatever decoded cleanly; mail in the wild often has trailing
+	// garbage after base64 or quoted-printable bodies.
+	data, _ := io.ReadAll(emlTransferDecoder(header.Get("Content-Transfer-Encoding"), body))
+
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	name := dispParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if decoded, err := emlWordDecoder.DecodeHeader(name); err == nil {
+		name = decoded
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		return m.walkMultipart(mediaType, params["boundary"], data, depth)
+	case mediaType == "message/rfc822":
+		if name == "" {
+			name = "message.eml"
+		}
+		m.attachments = append(m.attachments, emlAttachment{name: name, data: data})
+	case disposition == "attachment" || (name != "" && !strings.HasPrefix(mediaType, "text/")):
+		if name == "" {
+			name = "attachment"
+		}
+		m.attachments = append(m.attachments, emlAttachment{name: filepath.Base(name), data: data})
+	case mediaType == "text/plain":
+		m.body = append(m.body, normalizeNewlines(decodeCharset(data, params["charset"])))
+	case mediaType == "text/html":
+		text, err := extractHTMLText(data, header.Get("Content-Type"))
+		if err == nil {
+			m.body = append(m.body, text)
+		}
+	}
+	return nil
+}
+
+// walkMultipart reads each part of a multipart body. For
+// multipart/alternative only one rendering of the body is kept, preferring
+// plain text over HTML.
+func (m *emlMessage) walkMultipart(mediaType, boundary string, data []byte, depth int) error {
+	if boundary == "" {
+		return nil
+	}
+
+	var alternatives []emlMessage
+	reader := multipart.NewReader(bytes.NewReader(data), boundary)
+	for {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			// io.EOF, or a truncated message: keep the parts read so far.
+			break
+		}
+
+		if mediaType != "multipart/alternative" {
+			if err := m.walk(part.Header, part, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var alternative emlMessage
+		if err := alternative.walk(part.Header, part, depth+1); err != nil {
+			return err
+		}
+		if ct, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); ct == "text/plain" {
+			alternative.plain = true
+		}
+		alternatives = append(alternatives, alternative)
+	}
+
+	if len(alternatives) == 0 {
+		return nil
+	}
+	chosen := alternatives[len(alternatives)-1]
+	for _, alternative := range alternatives {
+		if alternative.plain && strings.TrimSpace(strings.Join(alternative.body, "")) != "" {
+			chosen = alternative
+			break
+		}
+	}
+	m.body = append(m.body, chosen.body...)
+	m.attachments = append(m.attachments, chosen.attachments...)
+	return nil
+}
+
+func emlTransferDecoder(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"contract-key-extractor/internal/config"
+	"contract-key-extractor/internal/model"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// testEML is a forwarded contract: a plain and HTML alternative body, a
+// text attachment and the original message, which carries an attachment of
+// its own, nested in a multipart/mixed message.
+var testEML = strings.ReplaceAll(`From: =?UTF-8?B?5byg5LiJ?= <zhang@example.com>
+To: legal@example.com
+Subject: =?UTF-8?B?6YeH6LSt5ZCI5ZCM?=
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: multipart/alternative; boundary="alt"
+
+--alt
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+=E8=AF=B7=E5=AE=A1=E9=98=85=E9=99=84=E4=BB=B6=E3=80=82
+--alt
+Content-Type: text/html; charset=utf-8
+
+<p>HTML 版本</p>
+--alt--
+--outer
+Content-Type: text/plain; charset=utf-8; name="terms.txt"
+Content-Disposition: attachment; filename="terms.txt"
+Content-Transfer-Encoding: base64
+
+55Sy5pa577ya5rWL6K+V5YWs5Y+4
+--outer
+Content-Type: message/rfc822
+
+Subject: original
+Content-Type: multipart/mixed; boundary="inner"
+
+--inner
+Content-Type: text/plain
+
+Original body
+--inner
+Content-Type: application/octet-stream; name="note.txt"
+
+Inner attachment
+--inner--
+--outer--
+`, "\n", "\r\n")
+
+func TestEMLParserNestedMultipart(t *testing.T) {
+	manager := NewParserManager(&config.ParserConfig{}, zap.NewNop())
+	doc, err := manager.Parse("forward.eml", []byte(testEML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"=== Email ===",
+		"Subject: 采购合同",
+		"From: 张三 <zhang@example.com>",
+		"To: legal@example.com",
+		"",
+		"请审阅附件。",
+		"",
+		"=== Attachment: terms.txt ===",
+		"甲方：测试公司",
+		"",
+		"=== Attachment: message.eml ===",
+		"=== Email ===",
+		"Subject: original",
+		"",
+		"Original body",
+		"",
+		"=== Attachment: note.txt ===",
+		"Inner attachment",
+	}, "\n")
+	if doc.Content != want {
+		t.Errorf("content = %q, want %q", doc.Content, want)
+	}
+	if doc.FileType != model.FileTypeEmail {
+		t.Errorf("file type = %s", doc.FileType)
+	}
+}
+
+func TestEMLParserWithoutParse(t *testing.T) {
+	doc, err := NewEMLParser(nil).Parse("forward.eml", []byte(testEML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(doc.Content, "=== Attachment: terms.txt ===\n[attachment not parsed]") {
+		t.Errorf("content = %q", doc.Content)
+	}
+}
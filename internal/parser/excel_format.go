@@ -0,0 +1,226 @@
+package parser
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// builtinNumFmts holds the non-date built-in number formats that carry
+// display information (grouping, decimals, percent, currency).
+var builtinNumFmts = map[int]string{
+	1:  "0",
+	2:  "0.00",
+	3:  "#,##0",
+	4:  "#,##0.00",
+	5:  "$#,##0_);($#,##0)",
+	6:  "$#,##0_);[Red]($#,##0)",
+	7:  "$#,##0.00_);($#,##0.00)",
+	8:  "$#,##0.00_);[Red]($#,##0.00)",
+	9:  "0%",
+	10: "0.00%",
+	37: "#,##0_);(#,##0)",
+	38: "#,##0_);[Red](#,##0)",
+	39: "#,##0.00_);(#,##0.00)",
+	40: "#,##0.00_);[Red](#,##0.00)",
+}
+
+// excelNumberFormats maps cell XF indexes to number formats so numeric cells
+// can be rendered the way Excel displays them rather than as raw serials.
+type excelNumberFormats struct {
+	xfFormat []int
+	formats  map[int]string
+	date1904 bool
+}
+
+func (f *excelNumberFormats) formatNumber(v float64, xf int) string {
+	if s, ok := f.displayNumber(v, xf); ok {
+		return s
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// displayNumber formats v with the number format of the given XF. It reports
+// false for General and for formats it does not understand.
+func (f *excelNumberFormats) displayNumber(v float64, xf int) (string, bool) {
+	if xf < 0 || xf >= len(f.xfFormat) {
+		return "", false
+	}
+	id := f.xfFormat[xf]
+	code, ok := f.formats[id]
+	if !ok {
+		code = builtinNumFmts[id]
+	}
+	if isDateFormat(id, code) {
+		return excelSerialToTime(v, f.date1904).Format(excelDateLayout(v)), true
+	}
+	return applyNumberFormat(v, code)
+}
+
+// applyNumberFormat renders v using the first applicable section of an Excel
+// number format code, honouring digit grouping, fixed decimals, percent and
+// literal prefixes/suffixes such as currency symbols.
+func applyNumberFormat(v float64, code string) (string, bool) {
+	sections := splitFormatSections(code)
+	if len(sections) == 0 {
+		return "", false
+	}
+	section := sections[0]
+	negative := v < 0
+	if negative && len(sections) > 1 && sections[1] != "" {
+		section = sections[1]
+		negative = false
+		v = -v
+	}
+
+	var prefix, suffix strings.Builder
+	decimals, seenDigit, inDecimals, grouping, percent := 0, false, false, false, false
+	literal := func(s string) {
+		if seenDigit {
+			suffix.WriteString(s)
+		} else {
+			prefix.WriteString(s)
+		}
+	}
+
+	runes := []rune(section)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			literal(string(runes[i+1 : min(end, len(runes))]))
+			i = end
+		case r == '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if tag := string(runes[i+1 : min(end, len(runes))]); strings.HasPrefix(tag, "$") {
+				if symbol, _, _ := strings.Cut(tag[1:], "-"); symbol != "" {
+					literal(symbol)
+				}
+			}
+			i = end
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				literal(string(runes[i]))
+			}
+		case r == '_' || r == '*':
+			i++
+		case r == '0' || r == '#' || r == '?':
+			seenDigit = true
+			if inDecimals {
+				decimals++
+			}
+		case r == '.':
+			inDecimals = true
+		case r == ',':
+			if seenDigit && !inDecimals {
+				grouping = true
+			}
+		case r == '%':
+			percent = true
+			literal("%")
+		case r == '@' || r == 'E' || r == 'e' || r == '/':
+			return "", false
+		default:
+			literal(string(r))
+		}
+	}
+	if !seenDigit {
+		return "", false
+	}
+
+	if percent {
+		v *= 100
+	}
+	digits := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	if grouping {
+		digits = groupThousands(digits)
+	}
+	sign := ""
+	if negative && strings.Trim(digits, "0.,") != "" {
+		sign = "-"
+	}
+	return sign + prefix.String() + digits + strings.TrimRight(suffix.String(), " "), true
+}
+
+func splitFormatSections(code string) []string {
+	var sections []string
+	inQuote, start := false, 0
+	for i, r := range code {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case r == ';' && !inQuote:
+			sections = append(sections, code[start:i])
+			start = i + 1
+		}
+	}
+	return append(sections, code[start:])
+}
+
+func groupThousands(digits string) string {
+	intPart, fracPart, hasFrac := strings.Cut(digits, ".")
+	var b strings.Builder
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	if hasFrac {
+		b.WriteString("." + fracPart)
+	}
+	return b.String()
+}
+
+func isDateFormat(id int, code string) bool {
+	switch {
+	case id >= 14 && id <= 22, id >= 27 && id <= 36, id >= 45 && id <= 47, id >= 50 && id <= 58:
+		return true
+	case code == "":
+		return false
+	}
+
+	inQuote, inBracket := false, false
+	for _, r := range strings.ToLower(code) {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case r == '[':
+			inBracket = true
+		case r == ']':
+			inBracket = false
+		case inBracket:
+		case r == 'y' || r == 'd' || r == 'h' || r == 's' || r == 'm' || r == '年' || r == '月' || r == '日':
+			return true
+		}
+	}
+	return false
+}
+
+func excelSerialToTime(v float64, date1904 bool) time.Time {
+	base := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		base = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return base.Add(time.Duration(math.Round(v*86400)) * time.Second)
+}
+
+func excelDateLayout(v float64) string {
+	switch {
+	case v < 1:
+		return "15:04:05"
+	case v != math.Floor(v):
+		return "2006-01-02 15:04:05"
+	}
+	return "2006-01-02"
+}
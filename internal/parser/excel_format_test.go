@@ -0,0 +1,56 @@
+package parser
+
+import "testing"
+
+func TestFormatNumber(t *testing.T) {
+	formats := &excelNumberFormats{
+		formats: map[int]string{
+			164: "yyyy年m月d日",
+			165: "#,##0;(#,##0)",
+			166: `0.00"元"`,
+			167: "[$¥-804]#,##0.00",
+			168: "@",
+			169: "0.00E+00",
+		},
+	}
+	// Each XF uses the number format of the same index in this list.
+	for _, id := range []int{0, 2, 4, 9, 10, 14, 22, 21, 164, 165, 166, 167, 168, 169} {
+		formats.xfFormat = append(formats.xfFormat, id)
+	}
+
+	for _, tc := range []struct {
+		name string
+		v    float64
+		xf   int
+		want string
+	}{
+		{"general integer", 42, 0, "42"},
+		{"general fraction", 0.1, 0, "0.1"},
+		{"fixed decimals", 3.14159, 1, "3.14"},
+		{"thousands", 1234567.891, 2, "1,234,567.89"},
+		{"negative thousands", -1234.5, 2, "-1,234.50"},
+		{"percent", 0.5, 3, "50%"},
+		{"percent decimals", 0.12345, 4, "12.35%"},
+		{"builtin date", 45292, 5, "2024-01-01"},
+		{"date and time", 45292.5, 6, "2024-01-01 12:00:00"},
+		{"time only", 0.75, 7, "18:00:00"},
+		{"custom date", 45292, 8, "2024-01-01"},
+		{"negative section", -1234, 9, "(1,234)"},
+		{"quoted suffix", 12.5, 10, "12.50元"},
+		{"currency tag", 1234.5, 11, "¥1,234.50"},
+		{"text format", 42, 12, "42"},
+		{"scientific", 1234.5, 13, "1234.5"},
+		{"unknown xf", 7.25, 99, "7.25"},
+	} {
+		if got := formats.formatNumber(tc.v, tc.xf); got != tc.want {
+			t.Errorf("%s: formatNumber(%v) = %q, want %q", tc.name, tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestFormatNumberDate1904(t *testing.T) {
+	formats := &excelNumberFormats{xfFormat: []int{14}, date1904: true}
+	if got := formats.formatNumber(1, 0); got != "1904-01-02" {
+		t.Errorf("formatNumber = %q, want 1904-01-02", got)
+	}
+}
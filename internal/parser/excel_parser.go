@@ -4,12 +4,22 @@ import (
 	"bytes"
 	"contract-key-extractor/internal/model"
 	"fmt"
+	"io"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/xuri/excelize/v2"
 )
 
+// maxMergePropagation caps how many cells a single merged range may fill so
+// that a sheet-wide merge cannot blow up the output.
+const maxMergePropagation = 10000
+
+// maxXlsxUnzipSize caps the total size of the parts excelize unpacks from
+// an .xlsx file.
+const maxXlsxUnzipSize = 1 << 30
+
 type ExcelParser struct{}
 
 func NewExcelParser() *ExcelParser {
@@ -17,45 +27,270 @@ func NewExcelParser() *ExcelParser {
 }
 
 func (p *ExcelParser) Parse(filePath string, fileData []byte) (*model.ParsedDocument, error) {
-	reader, err := excelize.OpenReader(bytes.NewReader(fileData))
+	return p.ParseReaderAt(filePath, bytes.NewReader(fileData), int64(len(fileData)))
+}
+
+// ParseReaderAt reads an .xls in place, loading only its Workbook stream.
+// excelize needs the whole of an .xlsx file in memory, so only the size of
+// what it unpacks is capped.
+func (p *ExcelParser) ParseReaderAt(filePath string, r io.ReaderAt, size int64) (*model.ParsedDocument, error) {
+	if bytes.HasPrefix(readHead(r, size, len(cfbSignature)), cfbSignature) {
+		return p.parseXls(filePath, r, size)
+	}
+
+	reader, err := excelize.OpenReader(io.NewSectionReader(r, 0, size), excelize.Options{UnzipSizeLimit: maxXlsxUnzipSize})
 	if err != nil {
 		return nil, fmt.Errorf("failed to open excel file: %w", err)
 	}
 	defer reader.Close()
 
-	var contentBuilder strings.Builder
-	sheets := reader.GetSheetList()
-	pageCount := len(sheets)
-
-	for sheetIdx, sheetName := range sheets {
-		rows, err := reader.GetRows(sheetName)
+	formats := xlsxNumberFormats(reader)
+	var tables []model.SheetTable
+	for _, sheetName := range reader.GetSheetList() {
+		table, err := readXlsxSheet(reader, sheetName, formats)
 		if err != nil {
 			continue
 		}
+		tables = append(tables, table)
+	}
+
+	doc := &model.ParsedDocument{
+		FileName:   filepath.Base(filePath),
+		FileType:   model.FileTypeExcel,
+		PageCount:  len(reader.GetSheetList()),
+		IsScanned:  false,
+		ImagePaths: nil,
+		Tables:     tables,
+	}
+	renderSheetTables(doc)
+	return doc, nil
+}
 
-		contentBuilder.WriteString(fmt.Sprintf("=== Sheet: %s ===\n", sheetName))
+func (p *ExcelParser) parseXls(filePath string, r io.ReaderAt, size int64) (*model.ParsedDocument, error) {
+	workbook, err := openXlsWorkbook(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open xls file: %w", err)
+	}
 
-		for _, row := range rows {
-			rowContent := strings.Join(row, "\t")
-			contentBuilder.WriteString(rowContent + "\n")
+	var tables []model.SheetTable
+	for _, sheet := range workbook.sheets {
+		if sheet.kind != 0 {
+			continue
 		}
 
-		if sheetIdx < len(sheets)-1 {
-			contentBuilder.WriteString("\n\n")
+		data := workbook.readSheet(sheet)
+		table := model.SheetTable{Name: sheet.name, Hidden: sheet.hidden, Rows: data.rows()}
+		for r := 0; r <= data.maxRow; r++ {
+			if data.hiddenRows[r] {
+				table.HiddenRows = append(table.HiddenRows, r+1)
+			}
+		}
+		for _, m := range data.merges {
+			mergeCells(&table, m.firstRow, m.firstCol, m.lastRow, m.lastCol)
 		}
+		tables = append(tables, table)
 	}
 
-	return &model.ParsedDocument{
+	doc := &model.ParsedDocument{
 		FileName:   filepath.Base(filePath),
 		FileType:   model.FileTypeExcel,
-		Content:    contentBuilder.String(),
-		PageCount:  pageCount,
+		PageCount:  len(tables),
 		IsScanned:  false,
 		ImagePaths: nil,
-	}, nil
+		Tables:     tables,
+	}
+	renderSheetTables(doc)
+	return doc, nil
 }
 
 func (p *ExcelParser) Supports(filePath string) bool {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	return ext == ".xlsx" || ext == ".xls"
+	return hasExtension(filePath, p.Extensions())
+}
+
+func (p *ExcelParser) Extensions() []string {
+	return []string{".xlsx", ".xls"}
+}
+
+func (p *ExcelParser) Name() string {
+	return "excel"
+}
+
+func (p *ExcelParser) Capabilities() Capabilities {
+	return Capabilities{
+		MIMETypes: []string{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "application/vnd.ms-excel"},
+		Tables:    true,
+	}
+}
+
+func xlsxNumberFormats(reader *excelize.File) *excelNumberFormats {
+	formats := &excelNumberFormats{formats: map[int]string{}}
+	if props, err := reader.GetWorkbookProps(); err == nil && props.Date1904 != nil {
+		formats.date1904 = *props.Date1904
+	}
+
+	styles := reader.Styles
+	if styles == nil {
+		return formats
+	}
+	if styles.NumFmts != nil {
+		for _, numFmt := range styles.NumFmts.NumFmt {
+			formats.formats[numFmt.NumFmtID] = numFmt.FormatCode
+		}
+	}
+	if styles.CellXfs != nil {
+		for _, xf := range styles.CellXfs.Xf {
+			id := 0
+			if xf.NumFmtID != nil {
+				id = *xf.NumFmtID
+			}
+			formats.xfFormat = append(formats.xfFormat, id)
+		}
+	}
+	return formats
+}
+
+// readXlsxSheet reads a worksheet using display values: excelize's formatted
+// text by default, with numeric cells re-rendered through the workbook's
+// number formats so dates come out as ISO dates and amounts keep their
+// grouping and currency symbol.
+func readXlsxSheet(reader *excelize.File, sheetName string, formats *excelNumberFormats) (model.SheetTable, error) {
+	table := model.SheetTable{Name: sheetName}
+
+	rows, err := reader.GetRows(sheetName)
+	if err != nil {
+		return table, err
+	}
+	raw, err := reader.GetRows(sheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return table, err
+	}
+
+	if visible, err := reader.GetSheetVisible(sheetName); err == nil {
+		table.Hidden = !visible
+	}
+
+	for r, row := range rows {
+		for c := range row {
+			if r >= len(raw) || c >= len(raw[r]) {
+				continue
+			}
+			v, err := strconv.ParseFloat(raw[r][c], 64)
+			if err != nil {
+				continue
+			}
+			cell, err := excelize.CoordinatesToCellName(c+1, r+1)
+			if err != nil {
+				continue
+			}
+			switch cellType, _ := reader.GetCellType(sheetName, cell); cellType {
+			case excelize.CellTypeUnset, excelize.CellTypeNumber, excelize.CellTypeDate:
+			default:
+				continue
+			}
+			style, err := reader.GetCellStyle(sheetName, cell)
+			if err != nil {
+				continue
+			}
+			if display, ok := formats.displayNumber(v, style); ok {
+				row[c] = display
+			}
+		}
+
+		if visible, err := reader.GetRowVisible(sheetName, r+1); err == nil && !visible {
+			table.HiddenRows = append(table.HiddenRows, r+1)
+		}
+	}
+	table.Rows = rows
+
+	merges, err := reader.GetMergeCells(sheetName)
+	if err != nil {
+		return table, nil
+	}
+	for _, merge := range merges {
+		firstCol, firstRow, err := excelize.CellNameToCoordinates(merge.GetStartAxis())
+		if err != nil {
+			continue
+		}
+		lastCol, lastRow, err := excelize.CellNameToCoordinates(merge.GetEndAxis())
+		if err != nil {
+			continue
+		}
+		mergeCells(&table, firstRow-1, firstCol-1, lastRow-1, lastCol-1)
+	}
+
+	return table, nil
+}
+
+// mergeCells records a merged range (zero-based, inclusive) on the table and
+// copies the top-left value into every cell it covers, so that a merged
+// header reads the same from each column it spans.
+func mergeCells(table *model.SheetTable, firstRow, firstCol, lastRow, lastCol int) {
+	start, err := excelize.CoordinatesToCellName(firstCol+1, firstRow+1)
+	if err != nil {
+		return
+	}
+	end, err := excelize.CoordinatesToCellName(lastCol+1, lastRow+1)
+	if err != nil {
+		return
+	}
+	table.MergedRanges = append(table.MergedRanges, start+":"+end)
+
+	rows := table.Rows
+	if firstRow >= len(rows) || firstCol >= len(rows[firstRow]) {
+		return
+	}
+	value := rows[firstRow][firstCol]
+	if value == "" || (lastRow-firstRow+1)*(lastCol-firstCol+1) > maxMergePropagation {
+		return
+	}
+
+	for len(rows) <= lastRow {
+		rows = append(rows, nil)
+	}
+	for r := firstRow; r <= lastRow; r++ {
+		for len(rows[r]) <= lastCol {
+			rows[r] = append(rows[r], "")
+		}
+		for c := firstCol; c <= lastCol; c++ {
+			rows[r][c] = value
+		}
+	}
+	table.Rows = rows
+}
+
+// renderSheetTables builds the plain-text form of doc.Tables sent to the AI
+// service, with a table of cells per sheet in the layout. Hidden sheets and
+// rows are kept but labelled, and merged ranges are listed after each
+// sheet.
+func renderSheetTables(doc *model.ParsedDocument) {
+	w := &layoutWriter{}
+	for sheetIdx, table := range doc.Tables {
+		if table.Hidden {
+			w.heading(fmt.Sprintf("=== Sheet: %s (隐藏) ===\n", table.Name))
+		} else {
+			w.heading(fmt.Sprintf("=== Sheet: %s ===\n", table.Name))
+		}
+
+		hiddenRows := make(map[int]bool, len(table.HiddenRows))
+		for _, r := range table.HiddenRows {
+			hiddenRows[r] = true
+		}
+		for r, row := range table.Rows {
+			prefix := ""
+			if hiddenRows[r+1] {
+				prefix = hiddenRowPrefix
+			}
+			w.addRow(prefix, row, "\t", "\n")
+		}
+
+		if len(table.MergedRanges) > 0 {
+			w.addParagraph(mergedCellsPrefix+strings.Join(table.MergedRanges, ", "), nil)
+			w.text("\n")
+		}
+
+		if sheetIdx < len(doc.Tables)-1 {
+			w.text("\n\n")
+		}
+	}
+	w.apply(doc)
 }
@@ -0,0 +1,404 @@
+package parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf16"
+)
+
+const (
+	xlsRecordFormula     = 0x0006
+	xlsRecordEOF         = 0x000A
+	xlsRecordDateMode    = 0x0022
+	xlsRecordFilePass    = 0x002F
+	xlsRecordContinue    = 0x003C
+	xlsRecordBoundSheet  = 0x0085
+	xlsRecordMulRK       = 0x00BD
+	xlsRecordXF          = 0x00E0
+	xlsRecordMergedCells = 0x00E5
+	xlsRecordSST         = 0x00FC
+	xlsRecordLabelSST    = 0x00FD
+	xlsRecordNumber      = 0x0203
+	xlsRecordLabel       = 0x0204
+	xlsRecordBoolErr     = 0x0205
+	xlsRecordString      = 0x0207
+	xlsRecordRow         = 0x0208
+	xlsRecordRK          = 0x027E
+	xlsRecordFormat      = 0x041E
+	xlsRecordBOF         = 0x0809
+)
+
+var errXlsEncrypted = errors.New("encrypted .xls is not supported")
+
+type xlsRecord struct {
+	kind uint16
+	data []byte
+}
+
+type xlsSheet struct {
+	name   string
+	offset int
+	hidden bool
+	kind   byte
+}
+
+type xlsMerge struct {
+	firstRow int
+	lastRow  int
+	firstCol int
+	lastCol  int
+}
+
+type xlsSheetData struct {
+	cells      map[int]map[int]string
+	hiddenRows map[int]bool
+	merges     []xlsMerge
+	maxRow     int
+	maxCol     int
+}
+
+type xlsWorkbook struct {
+	stream []byte
+	sheets []xlsSheet
+	sst    []string
+	excelNumberFormats
+}
+
+func readXlsRecords(stream []byte, offset int, stopAtEOF bool) []xlsRecord {
+	var records []xlsRecord
+	for pos := offset; pos+4 <= len(stream); {
+		kind := binary.LittleEndian.Uint16(stream[pos:])
+		length := int(binary.LittleEndian.Uint16(stream[pos+2:]))
+		pos += 4
+		if pos+length > len(stream) {
+			break
+		}
+		records = append(records, xlsRecord{kind: kind, data: stream[pos : pos+length]})
+		pos += length
+		if stopAtEOF && kind == xlsRecordEOF {
+			break
+		}
+	}
+	return records
+}
+
+func openXlsWorkbook(r io.ReaderAt, size int64) (*xlsWorkbook, error) {
+	cfb, err := openCFB(r, size, "Workbook", "Book")
+	if err != nil {
+		return nil, err
+	}
+
+	stream, ok := cfb.streams["Workbook"]
+	if !ok {
+		if _, isBIFF5 := cfb.streams["Book"]; isBIFF5 {
+			return nil, fmt.Errorf("excel 5.0/95 workbooks are not supported")
+		}
+		return nil, fmt.Errorf("Workbook stream not found in xls")
+	}
+
+	wb := &xlsWorkbook{stream: stream, excelNumberFormats: excelNumberFormats{formats: map[int]string{}}}
+	records := readXlsRecords(stream, 0, true)
+	if len(records) == 0 || records[0].kind != xlsRecordBOF {
+		return nil, fmt.Errorf("invalid BIFF workbook stream")
+	}
+	if len(records[0].data) >= 2 && binary.LittleEndian.Uint16(records[0].data) != 0x0600 {
+		return nil, fmt.Errorf("only BIFF8 workbooks are supported")
+	}
+
+	for i := 0; i < len(records); i++ {
+		rec := records[i]
+		switch rec.kind {
+		case xlsRecordFilePass:
+			return nil, errXlsEncrypted
+		case xlsRecordDateMode:
+			wb.date1904 = len(rec.data) >= 2 && binary.LittleEndian.Uint16(rec.data) == 1
+		case xlsRecordBoundSheet:
+			if len(rec.data) < 8 {
+				continue
+			}
+			r := &xlsStringReader{segments: [][]byte{rec.data[6:]}}
+			wb.sheets = append(wb.sheets, xlsSheet{
+				name:   r.shortString(),
+				offset: int(binary.LittleEndian.Uint32(rec.data)),
+				hidden: rec.data[4]&0x03 != 0,
+				kind:   rec.data[5],
+			})
+		case xlsRecordFormat:
+			if len(rec.data) < 4 {
+				continue
+			}
+			r := &xlsStringReader{segments: [][]byte{rec.data[2:]}}
+			wb.formats[int(binary.LittleEndian.Uint16(rec.data))] = r.unicodeString()
+		case xlsRecordXF:
+			if len(rec.data) >= 4 {
+				wb.xfFormat = append(wb.xfFormat, int(binary.LittleEndian.Uint16(rec.data[2:])))
+			}
+		case xlsRecordSST:
+			segments := [][]byte{rec.data}
+			for i+1 < len(records) && records[i+1].kind == xlsRecordContinue {
+				i++
+				segments = append(segments, records[i].data)
+			}
+			wb.sst = parseXlsSST(segments)
+		}
+	}
+
+	return wb, nil
+}
+
+func parseXlsSST(segments [][]byte) []string {
+	r := &xlsStringReader{segments: segments}
+	r.skip(4)
+	unique := int(r.u32())
+	if unique > 1<<20 {
+		unique = 1 << 20
+	}
+	strs := make([]string, 0, unique)
+	for i := 0; i < unique && !r.eof(); i++ {
+		strs = append(strs, r.unicodeString())
+	}
+	return strs
+}
+
+type xlsStringReader struct {
+	segments [][]byte
+	seg      int
+	pos      int
+}
+
+func (r *xlsStringReader) eof() bool {
+	for r.seg < len(r.segments) && r.pos >= len(r.segments[r.seg]) {
+		r.seg++
+		r.pos = 0
+	}
+	return r.seg >= len(r.segments)
+}
+
+func (r *xlsStringReader) byte() byte {
+	if r.eof() {
+		return 0
+	}
+	b := r.segments[r.seg][r.pos]
+	r.pos++
+	return b
+}
+
+func (r *xlsStringReader) u16() uint16 {
+	return uint16(r.byte()) | uint16(r.byte())<<8
+}
+
+func (r *xlsStringReader) u32() uint32 {
+	return uint32(r.u16()) | uint32(r.u16())<<16
+}
+
+func (r *xlsStringReader) skip(n int) {
+	for i := 0; i < n; i++ {
+		r.byte()
+	}
+}
+
+func (r *xlsStringReader) chars(count int, high bool) string {
+	units := make([]uint16, 0, count)
+	for i := 0; i < count; i++ {
+		if r.seg < len(r.segments) && r.pos >= len(r.segments[r.seg]) {
+			if r.eof() {
+				break
+			}
+			high = r.byte()&0x01 != 0
+		}
+		if high {
+			units = append(units, r.u16())
+		} else {
+			units = append(units, uint16(r.byte()))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+func (r *xlsStringReader) shortString() string {
+	count := int(r.byte())
+	flags := r.byte()
+	return r.chars(count, flags&0x01 != 0)
+}
+
+func (r *xlsStringReader) unicodeString() string {
+	count := int(r.u16())
+	flags := r.byte()
+	runs, ext := 0, 0
+	if flags&0x08 != 0 {
+		runs = int(r.u16())
+	}
+	if flags&0x04 != 0 {
+		ext = int(r.u32())
+	}
+	s := r.chars(count, flags&0x01 != 0)
+	r.skip(runs*4 + ext)
+	return s
+}
+
+func (wb *xlsWorkbook) readSheet(sheet xlsSheet) *xlsSheetData {
+	data := &xlsSheetData{
+		cells:      map[int]map[int]string{},
+		hiddenRows: map[int]bool{},
+		maxRow:     -1,
+		maxCol:     -1,
+	}
+
+	records := readXlsRecords(wb.stream, sheet.offset, true)
+	pendingRow, pendingCol := -1, -1
+
+	for _, rec := range records {
+		d := rec.data
+		switch rec.kind {
+		case xlsRecordLabelSST:
+			if len(d) >= 10 {
+				idx := int(binary.LittleEndian.Uint32(d[6:]))
+				if idx < len(wb.sst) {
+					data.set(cellPos(d), wb.sst[idx])
+				}
+			}
+		case xlsRecordLabel:
+			if len(d) >= 8 {
+				r := &xlsStringReader{segments: [][]byte{d[6:]}}
+				data.set(cellPos(d), r.unicodeString())
+			}
+		case xlsRecordNumber:
+			if len(d) >= 14 {
+				v := math.Float64frombits(binary.LittleEndian.Uint64(d[6:]))
+				data.set(cellPos(d), wb.formatNumber(v, xfIndex(d)))
+			}
+		case xlsRecordRK:
+			if len(d) >= 10 {
+				v := decodeRK(binary.LittleEndian.Uint32(d[6:]))
+				data.set(cellPos(d), wb.formatNumber(v, xfIndex(d)))
+			}
+		case xlsRecordMulRK:
+			if len(d) < 6 {
+				continue
+			}
+			row := int(binary.LittleEndian.Uint16(d))
+			col := int(binary.LittleEndian.Uint16(d[2:]))
+			for pos := 4; pos+6 <= len(d)-2; pos += 6 {
+				xf := int(binary.LittleEndian.Uint16(d[pos:]))
+				v := decodeRK(binary.LittleEndian.Uint32(d[pos+2:]))
+				data.set([2]int{row, col}, wb.formatNumber(v, xf))
+				col++
+			}
+		case xlsRecordBoolErr:
+			if len(d) >= 8 {
+				switch {
+				case d[7] != 0:
+					data.set(cellPos(d), "#ERROR")
+				case d[6] != 0:
+					data.set(cellPos(d), "TRUE")
+				default:
+					data.set(cellPos(d), "FALSE")
+				}
+			}
+		case xlsRecordFormula:
+			if len(d) < 14 {
+				continue
+			}
+			pos := cellPos(d)
+			if d[12] == 0xFF && d[13] == 0xFF {
+				switch d[6] {
+				case 0:
+					pendingRow, pendingCol = pos[0], pos[1]
+				case 1:
+					if d[8] != 0 {
+						data.set(pos, "TRUE")
+					} else {
+						data.set(pos, "FALSE")
+					}
+				}
+				continue
+			}
+			v := math.Float64frombits(binary.LittleEndian.Uint64(d[6:]))
+			data.set(pos, wb.formatNumber(v, xfIndex(d)))
+		case xlsRecordString:
+			if pendingRow >= 0 {
+				r := &xlsStringReader{segments: [][]byte{d}}
+				data.set([2]int{pendingRow, pendingCol}, r.unicodeString())
+				pendingRow, pendingCol = -1, -1
+			}
+		case xlsRecordMergedCells:
+			if len(d) < 2 {
+				continue
+			}
+			count := int(binary.LittleEndian.Uint16(d))
+			for i := 0; i < count && 2+i*8+8 <= len(d); i++ {
+				ref := d[2+i*8:]
+				data.merges = append(data.merges, xlsMerge{
+					firstRow: int(binary.LittleEndian.Uint16(ref)),
+					lastRow:  int(binary.LittleEndian.Uint16(ref[2:])),
+					firstCol: int(binary.LittleEndian.Uint16(ref[4:])),
+					lastCol:  int(binary.LittleEndian.Uint16(ref[6:])),
+				})
+			}
+		case xlsRecordRow:
+			if len(d) >= 16 && binary.LittleEndian.Uint16(d[12:])&0x0020 != 0 {
+				data.hiddenRows[int(binary.LittleEndian.Uint16(d))] = true
+			}
+		}
+	}
+
+	return data
+}
+
+func cellPos(d []byte) [2]int {
+	return [2]int{int(binary.LittleEndian.Uint16(d)), int(binary.LittleEndian.Uint16(d[2:]))}
+}
+
+func xfIndex(d []byte) int {
+	return int(binary.LittleEndian.Uint16(d[4:]))
+}
+
+func (s *xlsSheetData) set(pos [2]int, value string) {
+	row, ok := s.cells[pos[0]]
+	if !ok {
+		row = map[int]string{}
+		s.cells[pos[0]] = row
+	}
+	row[pos[1]] = value
+	if pos[0] > s.maxRow {
+		s.maxRow = pos[0]
+	}
+	if pos[1] > s.maxCol {
+		s.maxCol = pos[1]
+	}
+}
+
+func (s *xlsSheetData) rows() [][]string {
+	rows := make([][]string, 0, s.maxRow+1)
+	for r := 0; r <= s.maxRow; r++ {
+		cells := s.cells[r]
+		last := -1
+		for c := range cells {
+			if c > last {
+				last = c
+			}
+		}
+		row := make([]string, last+1)
+		for c, v := range cells {
+			row[c] = v
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func decodeRK(rk uint32) float64 {
+	var v float64
+	if rk&0x02 != 0 {
+		v = float64(int32(rk) >> 2)
+	} else {
+		v = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+	if rk&0x01 != 0 {
+		v /= 100
+	}
+	return v
+}
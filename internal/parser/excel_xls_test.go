@@ -0,0 +1,274 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+const (
+	cfbEndOfChain = 0xFFFFFFFE
+	cfbFreeSect   = 0xFFFFFFFF
+	cfbNoStream   = 0xFFFFFFFF
+)
+
+// buildTestCFB returns a version 3 compound file holding one stream. The
+// stream is padded to the mini stream cutoff so that it lives in regular
+// sectors: sector 0 is the FAT, sector 1 the directory and the stream
+// follows.
+func buildTestCFB(name string, stream []byte) []byte {
+	const sectorSize = 512
+	if len(stream) < 4096 {
+		stream = append(stream, make([]byte, 4096-len(stream))...)
+	}
+	streamSectors := (len(stream) + sectorSize - 1) / sectorSize
+
+	header := make([]byte, sectorSize)
+	copy(header, cfbSignature)
+	le := binary.LittleEndian
+	le.PutUint16(header[24:], 0x003E)
+	le.PutUint16(header[26:], 3)
+	le.PutUint16(header[28:], 0xFFFE)
+	le.PutUint16(header[30:], 9) // 512-byte sectors
+	le.PutUint16(header[32:], 6) // 64-byte mini sectors
+	le.PutUint32(header[44:], 1) // FAT sectors
+	le.PutUint32(header[48:], 1) // first directory sector
+	le.PutUint32(header[56:], 4096)
+	le.PutUint32(header[60:], cfbEndOfChain) // no mini FAT
+	le.PutUint32(header[68:], cfbEndOfChain) // no DIFAT sectors
+	le.PutUint32(header[76:], 0)             // the FAT is sector 0
+	for i := 80; i < sectorSize; i += 4 {
+		le.PutUint32(header[i:], cfbFreeSect)
+	}
+
+	fat := make([]byte, sectorSize)
+	for i := 0; i < sectorSize; i += 4 {
+		le.PutUint32(fat[i:], cfbFreeSect)
+	}
+	le.PutUint32(fat, 0xFFFFFFFD) // FAT sector
+	le.PutUint32(fat[4:], cfbEndOfChain)
+	for i := 0; i < streamSectors; i++ {
+		next := uint32(3 + i)
+		if i == streamSectors-1 {
+			next = cfbEndOfChain
+		}
+		le.PutUint32(fat[(2+i)*4:], next)
+	}
+
+	entry := func(name string, kind byte, child, start uint32, size int) []byte {
+		e := make([]byte, 128)
+		units := utf16.Encode([]rune(name))
+		for i, u := range units {
+			le.PutUint16(e[i*2:], u)
+		}
+		le.PutUint16(e[64:], uint16((len(units)+1)*2))
+		e[66] = kind
+		e[67] = 1 // black
+		le.PutUint32(e[68:], cfbNoStream)
+		le.PutUint32(e[72:], cfbNoStream)
+		le.PutUint32(e[76:], child)
+		le.PutUint32(e[116:], start)
+		le.PutUint32(e[120:], uint32(size))
+		return e
+	}
+	dir := append(entry("Root Entry", 5, 1, cfbEndOfChain, 0), entry(name, 2, cfbNoStream, 2, len(stream))...)
+	for len(dir) < sectorSize {
+		dir = append(dir, entry("", 0, cfbNoStream, 0, 0)...)
+	}
+
+	data := append(header, fat...)
+	data = append(data, dir...)
+	data = append(data, stream...)
+	return append(data, make([]byte, streamSectors*sectorSize-len(stream))...)
+}
+
+func xlsRec(kind uint16, data ...[]byte) []byte {
+	body := bytes.Join(data, nil)
+	rec := binary.LittleEndian.AppendUint16(nil, kind)
+	rec = binary.LittleEndian.AppendUint16(rec, uint16(len(body)))
+	return append(rec, body...)
+}
+
+func u16(v int) []byte { return binary.LittleEndian.AppendUint16(nil, uint16(v)) }
+
+func u32(v int) []byte { return binary.LittleEndian.AppendUint32(nil, uint32(v)) }
+
+func f64(v float64) []byte { return binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)) }
+
+// utf16LE returns s as the uncompressed characters of a BIFF8 string.
+func utf16LE(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+// xlsString returns s as a BIFF8 unicode string with a 16-bit length.
+func xlsString(s string) []byte {
+	return bytes.Join([][]byte{u16(len([]rune(s))), {0x01}, utf16LE(s)}, nil)
+}
+
+func xlsBOF(kind int) []byte {
+	return xlsRec(xlsRecordBOF, u16(0x0600), u16(kind), make([]byte, 12))
+}
+
+func xlsCell(row, col, xf int) []byte {
+	return bytes.Join([][]byte{u16(row), u16(col), u16(xf)}, nil)
+}
+
+// testXlsWorkbook returns the Workbook stream of a workbook with a visible
+// sheet 合同 and a hidden sheet 隐藏:
+//
+//	     A             B          C
+//	1    甲方：测试公司  (merged A1:C1)
+//	2    ABC甲乙        12.50%     2024-01-01
+//	3    合计           1,234.50   Total       (hidden row)
+//
+// The second shared string is split across a CONTINUE record, switching
+// from compressed to UTF-16 characters, and A3 is a formula with a string
+// result.
+func testXlsWorkbook() []byte {
+	sst := bytes.Join([][]byte{
+		u32(3), u32(3),
+		xlsString("甲方：测试公司"),
+		u16(5), {0x00}, []byte("ABC"),
+	}, nil)
+	sstContinue := bytes.Join([][]byte{
+		{0x01}, utf16LE("甲乙"),
+		u16(5), {0x00}, []byte("Total"),
+	}, nil)
+
+	xf := func(format int) []byte {
+		return xlsRec(xlsRecordXF, u16(0), u16(format), make([]byte, 16))
+	}
+	boundSheet := func(offset, state int, name string) []byte {
+		return xlsRec(xlsRecordBoundSheet, u32(offset), []byte{byte(state), 0}, []byte{byte(len([]rune(name))), 0x01}, utf16LE(name))
+	}
+
+	sheet := bytes.Join([][]byte{
+		xlsBOF(0x0010),
+		xlsRec(xlsRecordRow, u16(2), u16(0), u16(3), u16(0x00FF), u16(0), u16(0), u16(0x0020), u16(0x000F)),
+		xlsRec(xlsRecordLabelSST, xlsCell(0, 0, 0), u32(0)),
+		xlsRec(xlsRecordLabelSST, xlsCell(1, 0, 0), u32(1)),
+		xlsRec(xlsRecordNumber, xlsCell(1, 1, 1), f64(0.125)),
+		xlsRec(xlsRecordRK, xlsCell(1, 2, 2), u32(45292<<2|0x02)),
+		xlsRec(xlsRecordFormula, xlsCell(2, 0, 0), []byte{0, 0, 0, 0, 0, 0, 0xFF, 0xFF}, u16(0), u32(0), u16(0)),
+		xlsRec(xlsRecordString, xlsString("合计")),
+		xlsRec(xlsRecordFormula, xlsCell(2, 1, 3), f64(1234.5), u16(0), u32(0), u16(0)),
+		xlsRec(xlsRecordLabelSST, xlsCell(2, 2, 0), u32(2)),
+		xlsRec(xlsRecordMergedCells, u16(1), u16(0), u16(0), u16(0), u16(2)),
+		xlsRec(xlsRecordEOF),
+	}, nil)
+	hiddenSheet := bytes.Join([][]byte{
+		xlsBOF(0x0010),
+		xlsRec(xlsRecordLabel, xlsCell(0, 0, 0), xlsString("备注")),
+		xlsRec(xlsRecordEOF),
+	}, nil)
+
+	globals := func(sheetOffset, hiddenOffset int) []byte {
+		return bytes.Join([][]byte{
+			xlsBOF(0x0005),
+			xlsRec(xlsRecordDateMode, u16(0)),
+			xlsRec(xlsRecordFormat, u16(164), xlsString("yyyy/mm/dd")),
+			xf(0), xf(10), xf(164), xf(4),
+			boundSheet(sheetOffset, 0, "合同"),
+			boundSheet(hiddenOffset, 1, "隐藏"),
+			xlsRec(xlsRecordSST, sst),
+			xlsRec(xlsRecordContinue, sstContinue),
+			xlsRec(xlsRecordEOF),
+		}, nil)
+	}
+	n := len(globals(0, 0))
+	return bytes.Join([][]byte{globals(n, n+len(sheet)), sheet, hiddenSheet}, nil)
+}
+
+func TestXlsWorkbookRecords(t *testing.T) {
+	data := buildTestCFB("Workbook", testXlsWorkbook())
+	wb, err := openXlsWorkbook(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := wb.sst, []string{"甲方：测试公司", "ABC甲乙", "Total"}; !slices.Equal(got, want) {
+		t.Errorf("sst = %q, want %q", got, want)
+	}
+	if len(wb.sheets) != 2 || wb.sheets[0].name != "合同" || wb.sheets[0].hidden || !wb.sheets[1].hidden {
+		t.Fatalf("sheets = %+v", wb.sheets)
+	}
+
+	cells := wb.readSheet(wb.sheets[0]).cells
+	for _, tc := range []struct {
+		row, col int
+		want     string
+	}{
+		{1, 0, "ABC甲乙"},    // shared string split across CONTINUE
+		{2, 0, "合计"},       // formula with a STRING record
+		{2, 1, "1,234.50"}, // numeric formula
+		{2, 2, "Total"},    // shared string after the split one
+	} {
+		if got := cells[tc.row][tc.col]; got != tc.want {
+			t.Errorf("cell (%d,%d) = %q, want %q", tc.row, tc.col, got, tc.want)
+		}
+	}
+	if got := wb.readSheet(wb.sheets[1]).cells[0][0]; got != "备注" {
+		t.Errorf("hidden sheet A1 = %q, want 备注", got)
+	}
+}
+
+func TestParseXlsTables(t *testing.T) {
+	doc, err := NewExcelParser().Parse("ledger.xls", buildTestCFB("Workbook", testXlsWorkbook()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Tables) != 2 {
+		t.Fatalf("%d tables, want 2", len(doc.Tables))
+	}
+
+	table := doc.Tables[0]
+	wantRows := [][]string{
+		{"甲方：测试公司", "甲方：测试公司", "甲方：测试公司"},
+		{"ABC甲乙", "12.50%", "2024-01-01"},
+		{"合计", "1,234.50", "Total"},
+	}
+	if !reflect.DeepEqual(table.Rows, wantRows) {
+		t.Errorf("rows = %q, want %q", table.Rows, wantRows)
+	}
+	if !slices.Equal(table.MergedRanges, []string{"A1:C1"}) || !slices.Equal(table.HiddenRows, []int{3}) {
+		t.Errorf("merged %v, hidden rows %v, want [A1:C1] and [3]", table.MergedRanges, table.HiddenRows)
+	}
+	if table.Hidden || !doc.Tables[1].Hidden {
+		t.Errorf("hidden sheets = %v, %v, want false, true", table.Hidden, doc.Tables[1].Hidden)
+	}
+
+	for _, want := range []string{
+		"=== Sheet: 合同 ===",
+		hiddenRowPrefix + "合计\t1,234.50\tTotal",
+		mergedCellsPrefix + "A1:C1",
+		"=== Sheet: 隐藏 (隐藏) ===",
+	} {
+		if !strings.Contains(doc.Content, want) {
+			t.Errorf("content lacks %q:\n%s", want, doc.Content)
+		}
+	}
+}
+
+func TestXlsEncryptedWorkbook(t *testing.T) {
+	stream := bytes.Join([][]byte{
+		xlsBOF(0x0005),
+		xlsRec(xlsRecordFilePass, u16(1), make([]byte, 52)),
+		xlsRec(xlsRecordEOF),
+	}, nil)
+	data := buildTestCFB("Workbook", stream)
+
+	_, err := NewExcelParser().Parse("locked.xls", data)
+	if !errors.Is(err, errXlsEncrypted) {
+		t.Fatalf("err = %v, want errXlsEncrypted", err)
+	}
+}
@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"bytes"
+	"contract-key-extractor/internal/model"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	htmlSpaces     = regexp.MustCompile(`[ \t\f\r\n]+`)
+	htmlBlankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlBlockElements start a new line when rendered as text.
+var htmlBlockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Header: true,
+	atom.Footer: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true,
+	atom.H6: true, atom.Ul: true, atom.Ol: true, atom.Blockquote: true, atom.Pre: true,
+	atom.Address: true, atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Hr: true, atom.Center: true,
+}
+
+// HTMLParser reads saved web pages and HTML exports of contracts. Scripts,
+// styles and other non-visible content are dropped, and tables are rendered
+// in the same pipe format as Word tables.
+type HTMLParser struct{}
+
+func NewHTMLParser() *HTMLParser {
+	return &HTMLParser{}
+}
+
+func (p *HTMLParser) Parse(filePath string, fileData []byte) (*model.ParsedDocument, error) {
+	content, err := extractHTMLText(fileData, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html file: %w", err)
+	}
+
+	return &model.ParsedDocument{
+		FileName:   filepath.Base(filePath),
+		FileType:   model.FileTypeHTML,
+		Content:    content,
+		PageCount:  1,
+		IsScanned:  false,
+		ImagePaths: nil,
+	}, nil
+}
+
+func (p *HTMLParser) Supports(filePath string) bool {
+	return hasExtension(filePath, p.Extensions())
+}
+
+func (p *HTMLParser) Extensions() []string {
+	return []string{".html", ".htm"}
+}
+
+func (p *HTMLParser) Name() string {
+	return "html"
+}
+
+func (p *HTMLParser) Capabilities() Capabilities {
+	return Capabilities{
+		MIMETypes: []string{"text/html", "application/xhtml+xml"},
+	}
+}
+
+// extractHTMLText renders an HTML document as plain text. contentType may
+// carry a charset from an enclosing MIME part; otherwise the document's own
+// declaration or content sniffing decides.
+func extractHTMLText(data []byte, contentType string) (string, error) {
+	var reader io.Reader
+	if _, name, certain := charset.DetermineEncoding(data, contentType); !certain && name == "windows-1252" {
+		// Nothing declared an encoding and the first KB was plain ASCII, so
+		// charset fell back to its Latin-1 default; decide from the whole file.
+		reader = strings.NewReader(decodeText(data))
+	} else {
+		r, err := charset.NewReader(bytes.NewReader(data), contentType)
+		if err != nil {
+			return "", err
+		}
+		reader = r
+	}
+	root, err := html.Parse(reader)
+	if err != nil {
+		return "", err
+	}
+
+	r := &htmlRenderer{}
+	r.render(root)
+
+	lines := strings.Split(r.out.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(htmlBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")), nil
+}
+
+type htmlRenderer struct {
+	out strings.Builder
+	pre int
+}
+
+func (r *htmlRenderer) newline() {
+	s := r.out.String()
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		r.out.WriteString("\n")
+	}
+}
+
+func (r *htmlRenderer) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if r.pre > 0 {
+			r.out.WriteString(n.Data)
+		} else {
+			r.out.WriteString(htmlSpaces.ReplaceAllString(n.Data, " "))
+		}
+		return
+	case html.ElementNode:
+		switch n.DataAtom {
+		case atom.Script, atom.Style, atom.Head, atom.Noscript, atom.Template, atom.Svg, atom.Iframe, atom.Object:
+			return
+		case atom.Br:
+			r.out.WriteString("\n")
+			return
+		case atom.Table:
+			r.newline()
+			r.table(n)
+			r.newline()
+			return
+		case atom.Li:
+			r.newline()
+			r.out.WriteString("• ")
+			r.children(n)
+			r.newline()
+			return
+		case atom.Pre:
+			r.pre++
+			defer func() { r.pre-- }()
+		}
+		if htmlBlockElements[n.DataAtom] {
+			r.newline()
+			r.children(n)
+			r.newline()
+			return
+		}
+	}
+	r.children(n)
+}
+
+func (r *htmlRenderer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.render(c)
+	}
+}
+
+func (r *htmlRenderer) table(tbl *html.Node) {
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Tr:
+				var cells []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type != html.ElementNode || (cell.DataAtom != atom.Td && cell.DataAtom != atom.Th) {
+						continue
+					}
+					inner := &htmlRenderer{}
+					inner.children(cell)
+					cells = append(cells, strings.Join(strings.Fields(inner.out.String()), " "))
+				}
+				r.out.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(c)
+			}
+		}
+	}
+	walk(tbl)
+}
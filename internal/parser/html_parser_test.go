@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+const testHTML = `<!DOCTYPE html>
+<html><head><meta charset="gbk"><title>页面标题</title>
+<style>p { color: red }</style><script>var x = "脚本";</script></head>
+<body>
+<h1>服务合同</h1>
+<p>甲方：   测试
+公司<br>乙方：示例公司</p>
+<ul><li>付款</li><li>交付</li></ul>
+<table><thead><tr><th>项目</th><th>金额</th></tr></thead>
+<tbody><tr><td>服务费</td><td> 1,000 元 </td></tr></tbody></table>
+<pre>第一条
+  保密</pre>
+</body></html>`
+
+func TestHTMLParser(t *testing.T) {
+	data, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(testHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := NewHTMLParser().Parse("contract.html", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "服务合同\n\n甲方： 测试 公司\n乙方：示例公司\n\n• 付款\n• 交付\n\n| 项目 | 金额 |\n| 服务费 | 1,000 元 |\n\n第一条\n保密"
+	if doc.Content != want {
+		t.Errorf("content = %q, want %q", doc.Content, want)
+	}
+}
+
+// A part of an email declares its charset in the MIME header rather than
+// the document.
+func TestExtractHTMLTextCharsetFromContentType(t *testing.T) {
+	data, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte("<p>合同</p>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := extractHTMLText(data, "text/html; charset=gb2312")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "合同" {
+		t.Errorf("text = %q, want 合同", text)
+	}
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"bytes"
+	"contract-key-extractor/internal/model"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var errUnsupportedImage = errors.New("unsupported image format")
+
+var imageExtensions = []string{".jpg", ".jpeg", ".png", ".tif", ".tiff"}
+
+// ImageParser accepts photographed or scanned contract pages. It does no text
+// recognition itself: every page is returned as scanned so the extraction
+// service can send it to OCR.
+type ImageParser struct{}
+
+func NewImageParser() *ImageParser {
+	return &ImageParser{}
+}
+
+func (p *ImageParser) Parse(filePath string, fileData []byte) (*model.ParsedDocument, error) {
+	pages, err := splitImage(fileData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	return NewImageDocument(filepath.Base(filePath), len(pages)), nil
+}
+
+// ParseReaderAt maps an image file into memory to count its pages without
+// copying it.
+func (p *ImageParser) ParseReaderAt(filePath string, r io.ReaderAt, size int64) (*model.ParsedDocument, error) {
+	data, release, err := mapReaderAt(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	defer release()
+
+	return p.Parse(filePath, data)
+}
+
+func (p *ImageParser) Supports(filePath string) bool {
+	return IsImageFile(filePath)
+}
+
+func (p *ImageParser) Extensions() []string {
+	return imageExtensions
+}
+
+func (p *ImageParser) Name() string {
+	return "image"
+}
+
+func (p *ImageParser) Capabilities() Capabilities {
+	return Capabilities{
+		MIMETypes: []string{"image/jpeg", "image/png", "image/tiff"},
+		Pages:     true,
+		Scanned:   true,
+	}
+}
+
+func IsImageFile(filePath string) bool {
+	return hasExtension(filePath, imageExtensions)
+}
+
+// NewImageDocument returns an image-only document with pageCount scanned
+// pages awaiting OCR.
+func NewImageDocument(fileName string, pageCount int) *model.ParsedDocument {
+	doc := &model.ParsedDocument{
+		FileName:   fileName,
+		FileType:   model.FileTypeImage,
+		Content:    "",
+		PageCount:  pageCount,
+		IsScanned:  true,
+		ImagePaths: nil,
+		Parser:     "image",
+	}
+	for i := 1; i <= pageCount; i++ {
+		doc.Pages = append(doc.Pages, model.PageContent{Number: i, IsScanned: true})
+	}
+	return doc
+}
+
+// ImagePage returns the content of one page image. Pages of a multi-page
+// TIFF are assembled when called, so only one is held in memory at a time.
+type ImagePage func() (io.ReadSeeker, error)
+
+// OpenImagePages returns the individual page images of an image file: each
+// frame of a multi-page TIFF, or the file itself for JPEG and PNG. The file
+// is memory-mapped where supported rather than read onto the heap. release
+// closes it; the pages must not be used afterwards.
+func OpenImagePages(filePath string) (pages []ImagePage, release func(), err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	data, unmap, err := mapReaderAt(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	release = func() {
+		unmap()
+		file.Close()
+	}
+
+	if pages, err = splitImage(data); err != nil {
+		release()
+		return nil, nil, err
+	}
+	return pages, release, nil
+}
+
+func splitImage(data []byte) ([]ImagePage, error) {
+	if isTIFF(data) {
+		return splitTIFF(data)
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return nil, errUnsupportedImage
+	}
+	return []ImagePage{wholeImage(data)}, nil
+}
+
+func wholeImage(data []byte) ImagePage {
+	return func() (io.ReadSeeker, error) {
+		return bytes.NewReader(data), nil
+	}
+}
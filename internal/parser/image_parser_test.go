@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildTestTIFF returns a little-endian TIFF with one IFD per strip. Each IFD
+// has just its strip offset (LONG) and byte count (BYTE).
+func buildTestTIFF(strips ...string) []byte {
+	const ifdSize = 2 + 2*12 + 4
+	data := []byte("II*\x00")
+	data = binary.LittleEndian.AppendUint32(data, 8)
+
+	stripAt := 8 + ifdSize*len(strips)
+	for i, strip := range strips {
+		next := uint32(0)
+		if i < len(strips)-1 {
+			next = uint32(8 + ifdSize*(i+1))
+		}
+		data = binary.LittleEndian.AppendUint16(data, 2)
+		data = binary.LittleEndian.AppendUint16(data, tiffTagStripOffsets)
+		data = binary.LittleEndian.AppendUint16(data, tiffTypeLong)
+		data = binary.LittleEndian.AppendUint32(data, 1)
+		data = binary.LittleEndian.AppendUint32(data, uint32(stripAt))
+		data = binary.LittleEndian.AppendUint16(data, tiffTagStripByteCounts)
+		data = binary.LittleEndian.AppendUint16(data, tiffTypeByte)
+		data = binary.LittleEndian.AppendUint32(data, 1)
+		data = append(data, byte(len(strip)), 0, 0, 0)
+		data = binary.LittleEndian.AppendUint32(data, next)
+		stripAt += len(strip)
+	}
+	for _, strip := range strips {
+		data = append(data, strip...)
+	}
+	return data
+}
+
+func TestOpenImagePagesSplitsTIFF(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "scan.tif")
+	if err := os.WriteFile(filePath, buildTestTIFF("page one", "page two"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, release, err := OpenImagePages(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if len(pages) != 2 {
+		t.Fatalf("%d pages, want 2", len(pages))
+	}
+
+	strips := []string{"page one", "page two"}
+	for i, want := range strips {
+		r, err := pages[i]()
+		if err != nil {
+			t.Fatalf("page %d: %v", i+1, err)
+		}
+		page, _ := io.ReadAll(r)
+		if !isTIFF(page) || !bytes.Contains(page, []byte(want)) {
+			t.Errorf("page %d = %q, want a TIFF holding %q", i+1, page, want)
+		}
+		if bytes.Contains(page, []byte(strips[1-i])) {
+			t.Errorf("page %d holds the other page's strip", i+1)
+		}
+	}
+}
+
+func TestOpenImagePagesRejectsTruncatedTIFF(t *testing.T) {
+	data := buildTestTIFF("page one", "page two")
+	filePath := filepath.Join(t.TempDir(), "scan.tif")
+	if err := os.WriteFile(filePath, data[:len(data)-4], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, release, err := OpenImagePages(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if _, err := pages[1](); err == nil {
+		t.Error("expected an error for the truncated strip")
+	}
+}
@@ -0,0 +1,219 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	tiffTagStripOffsets    = 273
+	tiffTagStripByteCounts = 279
+	tiffTagTileOffsets     = 324
+	tiffTagTileByteCounts  = 325
+	tiffTagSubIFDs         = 330
+
+	tiffTypeByte  = 1
+	tiffTypeShort = 3
+	tiffTypeLong  = 4
+
+	tiffMaxPages = 1000
+)
+
+var errTIFFMalformed = errors.New("malformed tiff")
+
+var tiffTypeSizes = map[uint16]int{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4,
+}
+
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func isTIFF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*"))
+}
+
+func tiffByteOrder(data []byte) binary.ByteOrder {
+	if data[0] == 'M' {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// splitTIFF returns one standalone single-page TIFF per IFD in a classic
+// (non-BigTIFF) file. Strip and tile data is copied when a page is opened so
+// each page can be sent to OCR on its own; sub-IFDs such as thumbnails are
+// dropped.
+func splitTIFF(data []byte) ([]ImagePage, error) {
+	if len(data) < 8 || !isTIFF(data) {
+		return nil, errTIFFMalformed
+	}
+	order := tiffByteOrder(data)
+
+	var ifds [][]tiffEntry
+	seen := map[uint32]bool{}
+	offset := order.Uint32(data[4:])
+	for offset != 0 && len(ifds) < tiffMaxPages {
+		if seen[offset] {
+			break
+		}
+		seen[offset] = true
+
+		entries, next, err := readTIFFIFD(data, order, offset)
+		if err != nil {
+			if len(ifds) > 0 {
+				break
+			}
+			return nil, err
+		}
+		ifds = append(ifds, entries)
+		offset = next
+	}
+	if len(ifds) == 0 {
+		return nil, errTIFFMalformed
+	}
+	if len(ifds) == 1 {
+		return []ImagePage{wholeImage(data)}, nil
+	}
+
+	pages := make([]ImagePage, 0, len(ifds))
+	for _, entries := range ifds {
+		entries := entries
+		pages = append(pages, func() (io.ReadSeeker, error) {
+			page, err := writeTIFFPage(data, order, entries)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(page), nil
+		})
+	}
+	return pages, nil
+}
+
+func readTIFFIFD(data []byte, order binary.ByteOrder, offset uint32) ([]tiffEntry, uint32, error) {
+	if int(offset)+2 > len(data) {
+		return nil, 0, errTIFFMalformed
+	}
+	count := int(order.Uint16(data[offset:]))
+	end := int(offset) + 2 + count*12
+	if end+4 > len(data) {
+		return nil, 0, errTIFFMalformed
+	}
+
+	entries := make([]tiffEntry, 0, count)
+	for i := 0; i < count; i++ {
+		raw := data[int(offset)+2+i*12:]
+		entry := tiffEntry{
+			tag:   order.Uint16(raw),
+			typ:   order.Uint16(raw[2:]),
+			count: order.Uint32(raw[4:]),
+		}
+		size, ok := tiffTypeSizes[entry.typ]
+		if !ok || entry.tag == tiffTagSubIFDs {
+			continue
+		}
+		total := size * int(entry.count)
+		if total <= 4 {
+			entry.value = append([]byte(nil), raw[8:8+total]...)
+		} else {
+			at := int(order.Uint32(raw[8:]))
+			if at < 0 || total < 0 || at+total > len(data) {
+				return nil, 0, errTIFFMalformed
+			}
+			entry.value = append([]byte(nil), data[at:at+total]...)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, order.Uint32(data[end:]), nil
+}
+
+// uints decodes an unsigned integer field. Other types yield no values.
+func (e tiffEntry) uints(order binary.ByteOrder) []uint32 {
+	values := make([]uint32, 0, e.count)
+	for i := 0; i < int(e.count); i++ {
+		switch e.typ {
+		case tiffTypeByte:
+			values = append(values, uint32(e.value[i]))
+		case tiffTypeShort:
+			values = append(values, uint32(order.Uint16(e.value[i*2:])))
+		case tiffTypeLong:
+			values = append(values, order.Uint32(e.value[i*4:]))
+		}
+	}
+	return values
+}
+
+func writeTIFFPage(data []byte, order binary.ByteOrder, entries []tiffEntry) ([]byte, error) {
+	// The offsets are rewritten below; keep the IFD intact for the next call.
+	entries = append([]tiffEntry(nil), entries...)
+	find := func(tag uint16) *tiffEntry {
+		for i := range entries {
+			if entries[i].tag == tag {
+				return &entries[i]
+			}
+		}
+		return nil
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:4])
+	binary.Write(&out, order, uint32(8))
+	ifdSize := 2 + len(entries)*12 + 4
+	out.Write(make([]byte, ifdSize))
+
+	align := func() {
+		if out.Len()%2 != 0 {
+			out.WriteByte(0)
+		}
+	}
+
+	// Copy the image data blocks first and rewrite their offsets as LONGs.
+	for _, pair := range [][2]uint16{{tiffTagStripOffsets, tiffTagStripByteCounts}, {tiffTagTileOffsets, tiffTagTileByteCounts}} {
+		offsets, counts := find(pair[0]), find(pair[1])
+		if offsets == nil {
+			continue
+		}
+		if counts == nil || counts.count != offsets.count {
+			return nil, errTIFFMalformed
+		}
+		starts, lengths := offsets.uints(order), counts.uints(order)
+		if len(starts) != len(lengths) || len(starts) != int(offsets.count) {
+			return nil, errTIFFMalformed
+		}
+		newOffsets := make([]byte, 4*len(lengths))
+		for i, at := range starts {
+			if int64(at)+int64(lengths[i]) > int64(len(data)) {
+				return nil, errTIFFMalformed
+			}
+			align()
+			order.PutUint32(newOffsets[i*4:], uint32(out.Len()))
+			out.Write(data[at : at+lengths[i]])
+		}
+		offsets.typ = tiffTypeLong
+		offsets.value = newOffsets
+	}
+
+	header := make([]byte, ifdSize)
+	order.PutUint16(header, uint16(len(entries)))
+	for i, entry := range entries {
+		raw := header[2+i*12:]
+		order.PutUint16(raw, entry.tag)
+		order.PutUint16(raw[2:], entry.typ)
+		order.PutUint32(raw[4:], entry.count)
+		if len(entry.value) <= 4 {
+			copy(raw[8:12], entry.value)
+			continue
+		}
+		align()
+		order.PutUint32(raw[8:], uint32(out.Len()))
+		out.Write(entry.value)
+	}
+	copy(out.Bytes()[8:], header)
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,271 @@
+package parser
+
+import (
+	"contract-key-extractor/internal/model"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	pageMarkerLine     = regexp.MustCompile(`^--- 第(\d+)页 ---$`)
+	sectionHeadingLine = regexp.MustCompile(`^=== .+ ===$`)
+)
+
+const (
+	sheetHeadingPrefix = "=== Sheet: "
+	hiddenRowPrefix    = "[隐藏行] "
+	mergedCellsPrefix  = "[合并单元格] "
+)
+
+// BuildLayout derives doc.Layout from doc.Content, for documents whose
+// parser does not lay out its content while writing it and for content
+// replaced by whole-document OCR. Page markers start a new page; every other
+// non-empty line is a paragraph, except pipe-table rows and Excel sheet
+// rows, which are split into cells. Section headings such as
+// "=== Footnotes ===" are not blocks.
+//
+// It must be called again whenever Content is replaced.
+func BuildLayout(doc *model.ParsedDocument) {
+	pages := make(map[int]*model.PageContent, len(doc.Pages))
+	for i := range doc.Pages {
+		pages[doc.Pages[i].Number] = &doc.Pages[i]
+	}
+
+	b := &layoutBuilder{pages: pages}
+	b.startPage(1, false)
+
+	offset := 0
+	for _, line := range strings.SplitAfter(doc.Content, "\n") {
+		b.line(strings.TrimSuffix(line, "\n"), offset)
+		offset += utf8.RuneCountInString(line)
+	}
+	b.finishPage()
+
+	doc.Layout = b.layout
+}
+
+type layoutBuilder struct {
+	pages  map[int]*model.PageContent
+	layout []model.LayoutPage
+
+	page      model.LayoutPage
+	explicit  bool
+	paragraph int
+
+	tables  int
+	inTable bool
+	row     int
+	inSheet bool
+}
+
+// startPage begins a new page. explicit is set for pages opened by a page
+// marker, which are kept even when empty.
+func (b *layoutBuilder) startPage(number int, explicit bool) {
+	b.page = model.LayoutPage{Number: number, Blocks: []model.LayoutBlock{}}
+	b.explicit = explicit
+	b.paragraph = 0
+	b.inTable = false
+	if page, ok := b.pages[number]; ok {
+		b.page.Width = page.Width
+		b.page.Height = page.Height
+	}
+}
+
+func (b *layoutBuilder) finishPage() {
+	if b.explicit || len(b.page.Blocks) > 0 {
+		b.layout = append(b.layout, b.page)
+	}
+}
+
+func (b *layoutBuilder) line(text string, offset int) {
+	trimmed := strings.TrimSpace(text)
+	lead := leadingSpaceRunes(text)
+
+	if m := pageMarkerLine.FindStringSubmatch(trimmed); m != nil {
+		number, _ := strconv.Atoi(m[1])
+		b.finishPage()
+		b.startPage(number, true)
+		return
+	}
+	if trimmed == "" {
+		b.inTable = false
+		return
+	}
+	if sectionHeadingLine.MatchString(trimmed) {
+		b.inTable = false
+		b.inSheet = strings.HasPrefix(trimmed, sheetHeadingPrefix)
+		return
+	}
+
+	b.paragraph++
+	switch {
+	case b.inSheet && !strings.HasPrefix(trimmed, mergedCellsPrefix):
+		b.cells(text, offset, "\t", strings.HasPrefix(text, hiddenRowPrefix))
+	case len(trimmed) > 1 && strings.HasPrefix(trimmed, "|") && strings.HasSuffix(trimmed, "|"):
+		b.cells(trimmed[1:len(trimmed)-1], offset+lead+1, "|", false)
+	default:
+		b.inTable = false
+		b.page.Blocks = append(b.page.Blocks, model.LayoutBlock{
+			Type:      model.BlockTypeParagraph,
+			Paragraph: b.paragraph,
+			Text:      trimmed,
+			Start:     offset + lead,
+			End:       offset + lead + utf8.RuneCountInString(trimmed),
+		})
+	}
+}
+
+// cells adds one table row. row is the text of the row without enclosing
+// pipes, offset the position of its first character and sep the cell
+// separator.
+func (b *layoutBuilder) cells(row string, offset int, sep string, hidden bool) {
+	if !b.inTable {
+		b.tables++
+		b.row = 0
+		b.inTable = true
+	}
+	b.row++
+
+	if hidden {
+		row = row[len(hiddenRowPrefix):]
+		offset += utf8.RuneCountInString(hiddenRowPrefix)
+	}
+
+	position := offset
+	for column, cell := range strings.Split(row, sep) {
+		cellStart := position
+		position += utf8.RuneCountInString(cell) + utf8.RuneCountInString(sep)
+
+		text := strings.TrimSpace(cell)
+		if text == "" {
+			continue
+		}
+		lead := leadingSpaceRunes(cell)
+		b.page.Blocks = append(b.page.Blocks, model.LayoutBlock{
+			Type:      model.BlockTypeTableCell,
+			Paragraph: b.paragraph,
+			Text:      text,
+			Start:     cellStart + lead,
+			End:       cellStart + lead + utf8.RuneCountInString(text),
+			Table:     b.tables,
+			Row:       b.row,
+			Column:    column + 1,
+		})
+	}
+}
+
+// layoutWriter writes a document's Content and records its layout blocks
+// as it goes, for parsers that know their pages, paragraphs and table cells.
+// Offsets are counted in runes, like those of BuildLayout.
+type layoutWriter struct {
+	content strings.Builder
+	offset  int
+	layout  []model.LayoutPage
+
+	paragraph int
+	tables    int
+	row       int
+	inTable   bool
+}
+
+// text writes s without adding a block.
+func (w *layoutWriter) text(s string) {
+	w.content.WriteString(s)
+	w.offset += utf8.RuneCountInString(s)
+}
+
+// heading writes a line that is not a block, such as a section or sheet
+// heading, and ends the current table.
+func (w *layoutWriter) heading(s string) {
+	w.inTable = false
+	w.text(s)
+}
+
+// addPage writes a page marker and starts a new page. Blocks written before
+// the first page go on page 1.
+func (w *layoutWriter) addPage(number int, width, height float64) {
+	if w.offset > 0 {
+		w.text("\n\n")
+	}
+	w.text(PageMarker(number) + "\n")
+	w.layout = append(w.layout, model.LayoutPage{Number: number, Width: width, Height: height, Blocks: []model.LayoutBlock{}})
+	w.paragraph = 0
+	w.inTable = false
+}
+
+func (w *layoutWriter) addBlock(block model.LayoutBlock) {
+	if len(w.layout) == 0 {
+		w.layout = append(w.layout, model.LayoutPage{Number: 1, Blocks: []model.LayoutBlock{}})
+	}
+	page := &w.layout[len(w.layout)-1]
+	page.Blocks = append(page.Blocks, block)
+}
+
+// addParagraph writes text as a paragraph. Surrounding whitespace is written
+// but left out of the block; blank text only ends the current table.
+func (w *layoutWriter) addParagraph(text string, box *model.BoundingBox) {
+	w.inTable = false
+	if trimmed := strings.TrimSpace(text); trimmed != "" {
+		w.paragraph++
+		start := w.offset + leadingSpaceRunes(text)
+		w.addBlock(model.LayoutBlock{
+			Type:      model.BlockTypeParagraph,
+			Paragraph: w.paragraph,
+			Text:      trimmed,
+			Start:     start,
+			End:       start + utf8.RuneCountInString(trimmed),
+			BBox:      box,
+		})
+	}
+	w.text(text)
+}
+
+// addRow writes a table row as prefix, the cells joined by sep, and
+// suffix. Consecutive rows form one table.
+func (w *layoutWriter) addRow(prefix string, cells []string, sep, suffix string) {
+	if !w.inTable {
+		w.tables++
+		w.row = 0
+		w.inTable = true
+	}
+	w.row++
+	w.paragraph++
+
+	w.text(prefix)
+	for column, cell := range cells {
+		if column > 0 {
+			w.text(sep)
+		}
+		if text := strings.TrimSpace(cell); text != "" {
+			start := w.offset + leadingSpaceRunes(cell)
+			w.addBlock(model.LayoutBlock{
+				Type:      model.BlockTypeTableCell,
+				Paragraph: w.paragraph,
+				Text:      text,
+				Start:     start,
+				End:       start + utf8.RuneCountInString(text),
+				Table:     w.tables,
+				Row:       w.row,
+				Column:    column + 1,
+			})
+		}
+		w.text(cell)
+	}
+	w.text(suffix)
+}
+
+// apply sets the document's Content and Layout to what has been written.
+func (w *layoutWriter) apply(doc *model.ParsedDocument) {
+	doc.Content = w.content.String()
+	doc.Layout = w.layout
+	if doc.Layout == nil {
+		doc.Layout = []model.LayoutPage{}
+	}
+}
+
+func leadingSpaceRunes(s string) int {
+	return utf8.RuneCountInString(s[:len(s)-len(strings.TrimLeftFunc(s, unicode.IsSpace))])
+}
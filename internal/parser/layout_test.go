@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"contract-key-extractor/internal/model"
+	"fmt"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// checkLayoutOffsets fails unless every block's offsets select its text in
+// doc.Content.
+func checkLayoutOffsets(t *testing.T, doc *model.ParsedDocument) {
+	t.Helper()
+	content := []rune(doc.Content)
+	for _, page := range doc.Layout {
+		for _, block := range page.Blocks {
+			if block.Start < 0 || block.End > len(content) || block.Start > block.End {
+				t.Errorf("page %d: block %q has offsets [%d, %d) outside the content", page.Number, block.Text, block.Start, block.End)
+				continue
+			}
+			if got := string(content[block.Start:block.End]); got != block.Text {
+				t.Errorf("page %d: block %q points at %q", page.Number, block.Text, got)
+			}
+		}
+	}
+}
+
+func layoutBlocks(doc *model.ParsedDocument) []model.LayoutBlock {
+	var blocks []model.LayoutBlock
+	for _, page := range doc.Layout {
+		blocks = append(blocks, page.Blocks...)
+	}
+	return blocks
+}
+
+func buildTestDocx(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const testLayoutDocumentXML = `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t xml:space="preserve">  甲方：上海星辰科技有限公司</w:t></w:r></w:p>
+<w:p><w:r><w:t>地址：上海市</w:t></w:r><w:r><w:br/><w:t>浦东新区</w:t></w:r></w:p>
+<w:tbl>
+<w:tr><w:tc><w:p><w:r><w:t>项目</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>金额</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:tcPr><w:gridSpan w:val="2"/></w:tcPr><w:p><w:r><w:t>合计 100 元</w:t></w:r></w:p></w:tc></w:tr>
+</w:tbl>
+<w:p><w:r><w:t>乙方：北京远航贸易有限公司</w:t></w:r></w:p>
+</w:body></w:document>`
+
+func TestDocxLayout(t *testing.T) {
+	data := buildTestDocx(t, map[string]string{
+		"word/document.xml": testLayoutDocumentXML,
+		"word/header1.xml":  `<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:p><w:r><w:t>| 机密 |</w:t></w:r></w:p></w:hdr>`,
+	})
+	doc, err := NewWordParser().Parse("test.docx", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLayoutOffsets(t, doc)
+
+	want := []model.LayoutBlock{
+		{Type: model.BlockTypeParagraph, Paragraph: 1, Text: "甲方：上海星辰科技有限公司"},
+		{Type: model.BlockTypeParagraph, Paragraph: 2, Text: "地址：上海市\n浦东新区"},
+		{Type: model.BlockTypeTableCell, Paragraph: 3, Text: "项目", Table: 1, Row: 1, Column: 1},
+		{Type: model.BlockTypeTableCell, Paragraph: 3, Text: "金额", Table: 1, Row: 1, Column: 2},
+		{Type: model.BlockTypeTableCell, Paragraph: 4, Text: "合计 100 元", Table: 1, Row: 2, Column: 1},
+		{Type: model.BlockTypeParagraph, Paragraph: 5, Text: "乙方：北京远航贸易有限公司"},
+		// Header text that looks like a table row is still a paragraph.
+		{Type: model.BlockTypeParagraph, Paragraph: 6, Text: "| 机密 |"},
+	}
+	blocks := layoutBlocks(doc)
+	if len(doc.Layout) != 1 || len(blocks) != len(want) {
+		t.Fatalf("%d pages, blocks %+v", len(doc.Layout), blocks)
+	}
+	for i, block := range blocks {
+		block.Start, block.End = 0, 0
+		if block != want[i] {
+			t.Errorf("block %d = %+v, want %+v", i, block, want[i])
+		}
+	}
+}
+
+func TestPDFLayout(t *testing.T) {
+	doc, err := NewPDFParser().Parse("test.pdf", testTwoPagePDF())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLayoutOffsets(t, doc)
+
+	if len(doc.Layout) != 2 {
+		t.Fatalf("%d pages, want 2", len(doc.Layout))
+	}
+	for i, page := range doc.Layout {
+		if page.Number != i+1 || len(page.Blocks) != 1 || page.Blocks[0].BBox == nil {
+			t.Errorf("page %d = %+v, want one positioned block", i+1, page)
+		}
+	}
+
+	// A page replaced by OCR keeps its offsets but has no positions.
+	doc.Pages[0].Content = "甲方：上海星辰科技有限公司\n\n乙方：北京远航贸易有限公司"
+	doc.Pages[0].Lines = nil
+	MergePages(doc)
+	checkLayoutOffsets(t, doc)
+	if blocks := doc.Layout[0].Blocks; len(blocks) != 2 || blocks[0].BBox != nil || blocks[1].Paragraph != 2 {
+		t.Errorf("page 1 blocks = %+v", blocks)
+	}
+	if doc.Layout[1].Blocks[0].BBox == nil {
+		t.Error("page 2 lost its position")
+	}
+}
+
+func TestExcelLayout(t *testing.T) {
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]interface{}{"项目", "金额"})
+	f.SetSheetRow("Sheet1", "A2", &[]interface{}{"服务费", "1000"})
+	f.SetSheetRow("Sheet1", "A3", &[]interface{}{"备注", ""})
+	f.SetRowVisible("Sheet1", 3, false)
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewExcelParser().Parse("test.xlsx", buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLayoutOffsets(t, doc)
+
+	var cells []string
+	for _, block := range layoutBlocks(doc) {
+		if block.Type != model.BlockTypeTableCell || block.Table != 1 {
+			t.Errorf("block %+v is not a cell of the first table", block)
+		}
+		cells = append(cells, fmt.Sprintf("%d%c=%s", block.Row, 'A'+block.Column-1, block.Text))
+	}
+	want := []string{"1A=项目", "1B=金额", "2A=服务费", "2B=1000", "3A=备注"}
+	if len(cells) != len(want) {
+		t.Fatalf("cells = %v, want %v", cells, want)
+	}
+	for i := range want {
+		if cells[i] != want[i] {
+			t.Errorf("cell %d = %s, want %s", i, cells[i], want[i])
+		}
+	}
+}
+
+func TestBuildLayoutOffsets(t *testing.T) {
+	doc := &model.ParsedDocument{Content: "第一条  定义\n\n| 项目 | 金额 |\n| 服务费 | 1000 |\n\n=== Footnotes ===\n[^1] 注释"}
+	BuildLayout(doc)
+	checkLayoutOffsets(t, doc)
+	if blocks := layoutBlocks(doc); len(blocks) != 6 {
+		t.Errorf("blocks = %+v, want 6", blocks)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package parser
+
+import "io"
+
+// mapReaderAt returns the content of r as a byte slice. Memory mapping is
+// only used on unix; elsewhere the content is read into memory.
+func mapReaderAt(r io.ReaderAt, size int64) ([]byte, func(), error) {
+	return readReaderAt(r, size)
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package parser
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// mapReaderAt returns the content of r as a byte slice. When r is a file it
+// is memory-mapped rather than copied onto the heap, so the kernel pages it
+// in on demand and can drop the pages again under memory pressure. release
+// must be called once the slice is no longer used.
+func mapReaderAt(r io.ReaderAt, size int64) ([]byte, func(), error) {
+	file, ok := r.(*os.File)
+	if !ok || size <= 0 || int64(int(size)) != size {
+		return readReaderAt(r, size)
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return readReaderAt(r, size)
+	}
+	return data, func() { syscall.Munmap(data) }, nil
+}
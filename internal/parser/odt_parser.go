@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"contract-key-extractor/internal/model"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ODTParser reads OpenDocument text files produced by LibreOffice and
+// similar suites. Tables are rendered like DOCX tables and footnotes are
+// collected into a labelled section.
+type ODTParser struct{}
+
+func NewODTParser() *ODTParser {
+	return &ODTParser{}
+}
+
+func (p *ODTParser) Parse(filePath string, fileData []byte) (*model.ParsedDocument, error) {
+	return p.ParseReaderAt(filePath, bytes.NewReader(fileData), int64(len(fileData)))
+}
+
+// ParseReaderAt reads an .odt in place, loading only content.xml and
+// meta.xml.
+func (p *ODTParser) ParseReaderAt(filePath string, r io.ReaderAt, size int64) (*model.ParsedDocument, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open odt file: %w", err)
+	}
+
+	parts := map[string][]byte{}
+	for _, file := range reader.File {
+		if file.Name != "content.xml" && file.Name != "meta.xml" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
+		}
+		data, err := readPart(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		parts[file.Name] = data
+	}
+
+	if parts["content.xml"] == nil {
+		return nil, fmt.Errorf("content.xml not found in odt file")
+	}
+
+	root, err := parseXMLTree(parts["content.xml"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse content.xml: %w", err)
+	}
+
+	walker := &odtWalker{}
+	lines := walker.blocks(root.find("body").find("text"))
+
+	var content strings.Builder
+	content.WriteString(strings.Join(lines, "\n"))
+	if len(walker.notes) > 0 {
+		content.WriteString("\n\n=== Footnotes ===\n")
+		content.WriteString(strings.Join(walker.notes, "\n"))
+	}
+
+	pageCount := 1
+	if meta, err := parseXMLTree(parts["meta.xml"]); err == nil {
+		if n, err := strconv.Atoi(meta.find("document-statistic").attr("page-count")); err == nil && n > 0 {
+			pageCount = n
+		}
+	}
+
+	return &model.ParsedDocument{
+		FileName:   filepath.Base(filePath),
+		FileType:   model.FileTypeODT,
+		Content:    strings.TrimSpace(content.String()),
+		PageCount:  pageCount,
+		IsScanned:  false,
+		ImagePaths: nil,
+	}, nil
+}
+
+func (p *ODTParser) Supports(filePath string) bool {
+	return hasExtension(filePath, p.Extensions())
+}
+
+func (p *ODTParser) Extensions() []string {
+	return []string{".odt"}
+}
+
+func (p *ODTParser) Name() string {
+	return "odt"
+}
+
+func (p *ODTParser) Capabilities() Capabilities {
+	return Capabilities{
+		MIMETypes: []string{"application/vnd.oasis.opendocument.text"},
+	}
+}
+
+type odtWalker struct {
+	notes []string
+}
+
+func (w *odtWalker) blocks(node *xmlNode) []string {
+	if node == nil {
+		return nil
+	}
+
+	var lines []string
+	for _, child := range node.children {
+		switch child.name {
+		case "p", "h":
+			lines = append(lines, w.inline(child))
+		case "list":
+			for _, item := range child.children {
+				if item.name != "list-item" {
+					continue
+				}
+				for i, line := range w.blocks(item) {
+					if i == 0 {
+						line = "• " + line
+					}
+					lines = append(lines, line)
+				}
+			}
+		case "table":
+			lines = append(lines, w.table(child)...)
+		case "section", "index-body", "table-of-content", "list-header":
+			lines = append(lines, w.blocks(child)...)
+		}
+	}
+	return lines
+}
+
+func (w *odtWalker) inline(node *xmlNode) string {
+	var builder strings.Builder
+	var walk func(*xmlNode)
+	walk = func(n *xmlNode) {
+		for _, child := range n.children {
+			switch child.name {
+			case "":
+				builder.WriteString(child.text)
+			case "s":
+				count, err := strconv.Atoi(child.attr("c"))
+				if err != nil || count < 1 {
+					count = 1
+				}
+				builder.WriteString(strings.Repeat(" ", count))
+			case "tab":
+				builder.WriteString("\t")
+			case "line-break":
+				builder.WriteString("\n")
+			case "note":
+				citation := strings.TrimSpace(child.child("note-citation").innerText())
+				if citation == "" {
+					citation = strconv.Itoa(len(w.notes) + 1)
+				}
+				builder.WriteString("[^" + citation + "]")
+				body := strings.Join(w.blocks(child.child("note-body")), " ")
+				w.notes = append(w.notes, fmt.Sprintf("[^%s]: %s", citation, strings.TrimSpace(body)))
+			case "annotation", "annotation-end", "bookmark", "bookmark-start", "bookmark-end":
+			case "frame":
+				if box := child.child("text-box"); box != nil {
+					builder.WriteString(strings.Join(w.blocks(box), " "))
+				}
+			default:
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+	return builder.String()
+}
+
+func (w *odtWalker) table(tbl *xmlNode) []string {
+	var rows []string
+	var walkRows func(*xmlNode)
+	walkRows = func(n *xmlNode) {
+		for _, child := range n.children {
+			switch child.name {
+			case "table-row":
+				var cells []string
+				for _, cell := range child.children {
+					if cell.name != "table-cell" && cell.name != "covered-table-cell" {
+						continue
+					}
+					var parts []string
+					for _, line := range w.blocks(cell) {
+						if line = strings.TrimSpace(line); line != "" {
+							parts = append(parts, line)
+						}
+					}
+					repeat, err := strconv.Atoi(cell.attr("number-columns-repeated"))
+					if err != nil || repeat < 1 || repeat > 64 {
+						repeat = 1
+					}
+					for i := 0; i < repeat; i++ {
+						cells = append(cells, strings.Join(parts, " "))
+					}
+				}
+				rows = append(rows, "| "+strings.Join(cells, " | ")+" |")
+			case "table-header-rows", "table-rows", "table-row-group":
+				walkRows(child)
+			}
+		}
+	}
+	walkRows(tbl)
+	return rows
+}
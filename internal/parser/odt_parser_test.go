@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"contract-key-extractor/internal/model"
+	"testing"
+)
+
+const testODTContentXML = `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0">
+<office:body><office:text>
+<text:h>采购合同</text:h>
+<text:p>甲方：<text:s text:c="2"/>测试公司<text:note><text:note-citation>1</text:note-citation><text:note-body><text:p>以营业执照为准</text:p></text:note-body></text:note></text:p>
+<text:list><text:list-item><text:p>交货</text:p></text:list-item><text:list-item><text:p>验收</text:p></text:list-item></text:list>
+<table:table><table:table-row><table:table-cell><text:p>项目</text:p></table:table-cell><table:table-cell table:number-columns-repeated="2"><text:p>-</text:p></table:table-cell></table:table-row></table:table>
+<text:section><text:p>乙方<text:tab/>签章</text:p></text:section>
+</office:text></office:body></office:document-content>`
+
+const testODTMetaXML = `<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0"><office:meta><meta:document-statistic meta:page-count="3"/></office:meta></office:document-meta>`
+
+func buildTestODT(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestODTParser(t *testing.T) {
+	data := buildTestODT(t, map[string]string{
+		"mimetype":    "application/vnd.oasis.opendocument.text",
+		"content.xml": testODTContentXML,
+		"meta.xml":    testODTMetaXML,
+	})
+	doc, err := NewODTParser().Parse("contract.odt", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "采购合同\n甲方：  测试公司[^1]\n• 交货\n• 验收\n| 项目 | - | - |\n乙方\t签章\n\n=== Footnotes ===\n[^1]: 以营业执照为准"
+	if doc.Content != want {
+		t.Errorf("content = %q, want %q", doc.Content, want)
+	}
+	if doc.FileType != model.FileTypeODT || doc.PageCount != 3 {
+		t.Errorf("file type %s with %d pages, want odt with 3", doc.FileType, doc.PageCount)
+	}
+}
+
+func TestODTParserWithoutContent(t *testing.T) {
+	data := buildTestODT(t, map[string]string{"meta.xml": testODTMetaXML})
+	if _, err := NewODTParser().Parse("broken.odt", data); err == nil {
+		t.Error("expected an error for an odt without content.xml")
+	}
+}
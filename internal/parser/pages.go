@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"contract-key-extractor/internal/model"
+	"fmt"
+	"strings"
+)
+
+const ocrFailedPlaceholder = "[OCR识别失败]"
+
+func PageMarker(number int) string {
+	return fmt.Sprintf("--- 第%d页 ---", number)
+}
+
+func ScannedPages(doc *model.ParsedDocument) []int {
+	var pages []int
+	for _, page := range doc.Pages {
+		if page.IsScanned {
+			pages = append(pages, page.Number)
+		}
+	}
+	return pages
+}
+
+// MergePages sets doc.Content to its pages joined under page markers, and
+// doc.Layout to their lines. It must be called again after OCR replaces the
+// content of a page.
+func MergePages(doc *model.ParsedDocument) {
+	w := &layoutWriter{}
+	for _, page := range doc.Pages {
+		w.addPage(page.Number, page.Width, page.Height)
+		if page.OCRFailed {
+			w.text(ocrFailedPlaceholder)
+			continue
+		}
+
+		// Lines holds the non-blank lines of a text-layer page in order, so
+		// they pair up with the non-blank lines of its content.
+		lines := strings.Split(page.Content, "\n")
+		boxes := page.Lines
+		if nonBlankLines(lines) != len(boxes) {
+			boxes = nil
+		}
+		for i, line := range lines {
+			if i > 0 {
+				w.text("\n")
+			}
+			var box *model.BoundingBox
+			if len(boxes) > 0 && strings.TrimSpace(line) != "" {
+				bbox := boxes[0].BBox
+				box = &bbox
+				boxes = boxes[1:]
+			}
+			w.addParagraph(line, box)
+		}
+	}
+	w.apply(doc)
+}
+
+func nonBlankLines(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -1,62 +1,178 @@
 package parser
 
 import (
+	"bytes"
+	"contract-key-extractor/internal/config"
 	"contract-key-extractor/internal/model"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
 
 	"go.uber.org/zap"
 )
 
+// DocumentParser turns the raw bytes of one file format into a
+// ParsedDocument. Name identifies the parser in logs and in the Parser field
+// of the result.
 type DocumentParser interface {
 	Parse(filePath string, fileData []byte) (*model.ParsedDocument, error)
 	Supports(filePath string) bool
+	Extensions() []string
+	Name() string
+	Capabilities() Capabilities
+}
+
+// ReaderAtParser is implemented by parsers that can read a document through
+// an io.ReaderAt, loading only the parts they need, instead of requiring the
+// whole file as a byte slice. PDF, image, DOCX, DOC, ODT and XLS files are
+// read this way. RTF, HTML, plain text and EML files are read into memory
+// whole, as are XLSX files, which excelize needs in full.
+type ReaderAtParser interface {
+	ParseReaderAt(filePath string, r io.ReaderAt, size int64) (*model.ParsedDocument, error)
 }
 
 type ParserManager struct {
-	parsers []DocumentParser
-	logger  *zap.Logger
+	mu       sync.RWMutex
+	registry []*registration
+	order    int
+	logger   *zap.Logger
 }
 
-func NewParserManager(logger *zap.Logger) *ParserManager {
-	return &ParserManager{
-		parsers: []DocumentParser{
-			NewPDFParser(),
-			NewExcelParser(),
-			NewWordParser(),
-		},
-		logger: logger,
+func NewParserManager(cfg *config.ParserConfig, logger *zap.Logger) *ParserManager {
+	m := &ParserManager{logger: logger}
+	for _, parser := range []DocumentParser{
+		NewPDFParserWithMaxDecodedSize(cfg.PDFMaxDecodedSize),
+		NewExcelParser(),
+		NewWordParserWithRevisionMode(RevisionMode(cfg.WordRevisionMode)),
+		NewImageParser(),
+		NewRTFParser(),
+		NewODTParser(),
+		NewHTMLParser(),
+		NewTextParser(),
+		NewEMLParser(m.Parse),
+	} {
+		m.Register(Registration{Parser: parser})
 	}
+	return m
 }
 
+// Parse picks parsers from the file content when it can be recognised and
+// from the extension otherwise, falling back to the detected content type.
+// A declared extension that disagrees with the content is reported as a
+// warning on the parsed document. If the preferred parser fails, the next
+// matching one is tried. The returned document has its layout built.
 func (m *ParserManager) Parse(filePath string, fileData []byte) (*model.ParsedDocument, error) {
-	for _, parser := range m.parsers {
-		if parser.Supports(filePath) {
-			m.logger.Debug("using parser for file",
+	return m.parse(filePath, &parserSource{
+		reader: bytes.NewReader(fileData),
+		size:   int64(len(fileData)),
+		data:   fileData,
+	})
+}
+
+// ParseFile parses the file at filePath without reading it into memory
+// first. Parsers that implement ReaderAtParser read it in place; the others
+// get the whole content as with Parse.
+func (m *ParserManager) ParseFile(filePath string) (*model.ParsedDocument, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	return m.ParseReaderAt(filePath, file, info.Size())
+}
+
+// ParseReaderAt is Parse for content read through r.
+func (m *ParserManager) ParseReaderAt(filePath string, r io.ReaderAt, size int64) (*model.ParsedDocument, error) {
+	return m.parse(filePath, &parserSource{reader: r, size: size})
+}
+
+func (m *ParserManager) parse(filePath string, src *parserSource) (*model.ParsedDocument, error) {
+	selectPath := filePath
+	var warnings []string
+
+	declared := filepath.Ext(filePath)
+	if sniffed := SniffReaderAt(src.reader, src.size); sniffed != "" && !sameFormat(declared, sniffed) {
+		selectPath = contentPath(filePath, sniffed)
+		if declared != "" {
+			warnings = append(warnings, fmt.Sprintf("file extension %s does not match its content (detected %s)", declared, sniffed))
+			m.logger.Warn("file extension does not match content",
+				zap.String("file", filePath),
+				zap.String("detected", sniffed),
+			)
+		}
+	}
+
+	candidates := m.candidates(selectPath, detectMIMEType(readHead(src.reader, src.size, 512)))
+	if len(candidates) == 0 {
+		return nil, errors.New("no suitable parser found for file: " + filePath)
+	}
+
+	var errs []error
+	for _, candidate := range candidates {
+		parser := candidate.Parser
+		m.logger.Debug("using parser for file",
+			zap.String("file", filePath),
+			zap.String("parser", parser.Name()),
+		)
+
+		doc, err := src.parseWith(filePath, parser)
+		if err != nil {
+			m.logger.Warn("parser failed",
 				zap.String("file", filePath),
-				zap.String("parser", fmt.Sprintf("%T", parser)),
+				zap.String("parser", parser.Name()),
+				zap.Error(err),
 			)
-			return parser.Parse(filePath, fileData)
+			errs = append(errs, fmt.Errorf("%s: %w", parser.Name(), err))
+			continue
 		}
+
+		doc.Parser = parser.Name()
+		for _, failed := range errs {
+			warnings = append(warnings, fmt.Sprintf("parser %v; used %s instead", failed, parser.Name()))
+		}
+		doc.Warnings = append(doc.Warnings, warnings...)
+		if doc.Layout == nil {
+			BuildLayout(doc)
+		}
+		return doc, nil
+	}
+
+	if len(errs) == 1 {
+		return nil, errors.Unwrap(errs[0])
 	}
-	return nil, errors.New("no suitable parser found for file: " + filePath)
+	return nil, fmt.Errorf("all parsers failed: %w", errors.Join(errs...))
 }
 
-func (m *ParserManager) ParseBatch(files map[string][]byte) ([]*model.ParsedDocument, error) {
+// ParseBatch parses the files at filePaths, a few at a time so that only a
+// bounded number of documents are open at once.
+func (m *ParserManager) ParseBatch(filePaths []string) ([]*model.ParsedDocument, error) {
 	var (
 		results []*model.ParsedDocument
 		mu      sync.Mutex
 		wg      sync.WaitGroup
 		errs    []error
+		slots   = make(chan struct{}, runtime.NumCPU())
 	)
 
-	for filePath, data := range files {
+	for _, filePath := range filePaths {
 		wg.Add(1)
-		go func(fp string, d []byte) {
+		slots <- struct{}{}
+		go func(fp string) {
 			defer wg.Done()
+			defer func() { <-slots }()
 
-			doc, err := m.Parse(fp, d)
+			doc, err := m.ParseFile(fp)
 			if err != nil {
 				mu.Lock()
 				errs = append(errs, fmt.Errorf("failed to parse %s: %w", fp, err))
@@ -67,7 +183,7 @@ func (m *ParserManager) ParseBatch(files map[string][]byte) ([]*model.ParsedDocu
 			mu.Lock()
 			results = append(results, doc)
 			mu.Unlock()
-		}(filePath, data)
+		}(filePath)
 	}
 
 	wg.Wait()
@@ -80,14 +196,32 @@ func (m *ParserManager) ParseBatch(files map[string][]byte) ([]*model.ParsedDocu
 }
 
 func (m *ParserManager) GetSupportedExtensions() []string {
-	return []string{".pdf", ".xlsx", ".xls", ".docx", ".doc"}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var extensions []string
+	seen := map[string]bool{}
+	for _, entry := range m.registry {
+		for _, ext := range entry.Extensions {
+			if !seen[ext] {
+				seen[ext] = true
+				extensions = append(extensions, ext)
+			}
+		}
+	}
+	return extensions
 }
 
-func (m *ParserManager) IsSupported(filePath string) bool {
-	for _, parser := range m.parsers {
-		if parser.Supports(filePath) {
+func hasExtension(filePath string, extensions []string) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	for _, candidate := range extensions {
+		if ext == candidate {
 			return true
 		}
 	}
 	return false
 }
+
+func (m *ParserManager) IsSupported(filePath string) bool {
+	return len(m.candidates(filePath, "")) > 0
+}
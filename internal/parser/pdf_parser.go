@@ -2,28 +2,141 @@ package parser
 
 import (
 	"contract-key-extractor/internal/model"
+	"fmt"
+	"io"
 	"path/filepath"
-	"strings"
+	"regexp"
+	"unicode"
 )
 
-type PDFParser struct{}
+const pdfMinPageTextRunes = 10
+
+var pdfPageMarker = regexp.MustCompile(`/Type\s*/Page[^s]`)
+
+type PDFParser struct {
+	maxDecodedSize int64
+}
 
 func NewPDFParser() *PDFParser {
-	return &PDFParser{}
+	return &PDFParser{maxDecodedSize: defaultPDFMaxDecodedSize}
+}
+
+// NewPDFParserWithMaxDecodedSize returns a parser that decodes at most
+// maxDecodedSize bytes from the streams of a document; streams beyond that
+// are treated as unreadable. A value <= 0 keeps the default.
+func NewPDFParserWithMaxDecodedSize(maxDecodedSize int64) *PDFParser {
+	if maxDecodedSize <= 0 {
+		maxDecodedSize = defaultPDFMaxDecodedSize
+	}
+	return &PDFParser{maxDecodedSize: maxDecodedSize}
 }
 
 func (p *PDFParser) Parse(filePath string, fileData []byte) (*model.ParsedDocument, error) {
-	return &model.ParsedDocument{
+	doc := &model.ParsedDocument{
 		FileName:   filepath.Base(filePath),
 		FileType:   model.FileTypePDF,
 		Content:    "",
 		PageCount:  1,
 		IsScanned:  true,
 		ImagePaths: nil,
-	}, nil
+	}
+
+	pages, err := extractPDFText(fileData, p.maxDecodedSize)
+	if err != nil {
+		if n := len(pdfPageMarker.FindAll(fileData, -1)); n > 0 {
+			doc.PageCount = n
+		}
+		return doc, nil
+	}
+
+	for i := range pages {
+		text := pages[i].String()
+		scanned := !hasExtractableText(text)
+		if !scanned {
+			doc.IsScanned = false
+		}
+		doc.Pages = append(doc.Pages, model.PageContent{
+			Number:    i + 1,
+			Content:   text,
+			IsScanned: scanned,
+			Width:     pages[i].width,
+			Height:    pages[i].height,
+			Lines:     pages[i].textLines(),
+		})
+	}
+
+	doc.PageCount = len(pages)
+	if !doc.IsScanned {
+		MergePages(doc)
+	}
+
+	return doc, nil
+}
+
+// ParseReaderAt maps a PDF file into memory instead of copying it, so large
+// scanned PDFs do not have to fit on the heap.
+func (p *PDFParser) ParseReaderAt(filePath string, r io.ReaderAt, size int64) (*model.ParsedDocument, error) {
+	data, release, err := mapReaderAt(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf file: %w", err)
+	}
+	defer release()
+
+	return p.Parse(filePath, data)
+}
+
+func extractPDFText(data []byte, maxDecodedSize int64) (pages []pdfPageText, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("pdf text extraction failed: %v", rec)
+		}
+	}()
+
+	reader, err := newPDFReader(data, maxDecodedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, page := range reader.pages() {
+		pages = append(pages, reader.extractPageText(page))
+	}
+	if len(pages) == 0 {
+		return nil, errPDFNoPages
+	}
+
+	return pages, nil
+}
+
+func hasExtractableText(text string) bool {
+	var meaningful, total int
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if r != unicode.ReplacementChar && !unicode.Is(unicode.Co, r) && !unicode.IsControl(r) {
+			meaningful++
+		}
+	}
+	return meaningful >= pdfMinPageTextRunes && meaningful*2 >= total
 }
 
 func (p *PDFParser) Supports(filePath string) bool {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	return ext == ".pdf"
+	return hasExtension(filePath, p.Extensions())
+}
+
+func (p *PDFParser) Extensions() []string {
+	return []string{".pdf"}
+}
+
+func (p *PDFParser) Name() string {
+	return "pdf"
+}
+
+func (p *PDFParser) Capabilities() Capabilities {
+	return Capabilities{
+		MIMETypes: []string{"application/pdf"},
+		Pages:     true,
+		Scanned:   true,
+	}
 }
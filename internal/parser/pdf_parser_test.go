@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPDFPageClassification(t *testing.T) {
+	// Page 2 holds only a page number and page 3 draws nothing, so both are
+	// sent to OCR even though neither contains an image.
+	data := buildTestPDF(
+		testPDFCatalog,
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 >>",
+		testPDFPage(6, 9),
+		testPDFPage(7, 9),
+		testPDFPage(8, 9),
+		testPDFText("Contract number HT-2024-001"),
+		testPDFText("- 2 -"),
+		testPDFStream("", []byte("q 1 0 0 1 0 0 cm Q")),
+		testPDFFont,
+	)
+
+	doc, err := NewPDFParser().Parse("test.pdf", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.IsScanned {
+		t.Error("a document with one text page should not be scanned")
+	}
+	if got := ScannedPages(doc); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("ScannedPages = %v, want [2 3]", got)
+	}
+}
+
+func TestMergePagesInPageOrder(t *testing.T) {
+	doc, err := NewPDFParser().Parse("test.pdf", testTwoPagePDF())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- 第1页 ---\nContract number HT-2024-001\n\n--- 第2页 ---\nPayment is due within thirty days"
+	if doc.Content != want {
+		t.Errorf("Content = %q, want %q", doc.Content, want)
+	}
+
+	// OCR results are written back into their own pages, and a failed page
+	// keeps its marker.
+	doc.Pages[0].Content = "甲方：上海星辰科技有限公司"
+	doc.Pages[0].Lines = nil
+	doc.Pages[1].OCRFailed = true
+	MergePages(doc)
+	want = "--- 第1页 ---\n甲方：上海星辰科技有限公司\n\n--- 第2页 ---\n" + ocrFailedPlaceholder
+	if doc.Content != want {
+		t.Errorf("Content = %q, want %q", doc.Content, want)
+	}
+}
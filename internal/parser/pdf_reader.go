@@ -0,0 +1,923 @@
+package parser
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+var (
+	errPDFEncrypted = errors.New("encrypted pdf is not supported")
+	errPDFNoPages   = errors.New("pdf page tree not found")
+	errPDFTooLarge  = errors.New("pdf stream exceeds the decoded size limit")
+
+	pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+)
+
+const (
+	pdfMaxResolveDepth = 32
+	pdfMaxTreeDepth    = 64
+
+	// defaultPDFMaxDecodedSize bounds the bytes decoded from all the
+	// streams of a document, so a small compression bomb cannot exhaust
+	// memory.
+	defaultPDFMaxDecodedSize = 256 << 20
+)
+
+type pdfXrefEntry struct {
+	offset   int
+	inStream bool
+	stream   int
+	index    int
+}
+
+type pdfObjStm struct {
+	data    []byte
+	offsets map[int]int
+}
+
+type pdfReader struct {
+	data    []byte
+	xref    map[int]pdfXrefEntry
+	trailer pdfDict
+	cache   map[int]interface{}
+	objStms map[int]*pdfObjStm
+	loading map[int]bool
+
+	// decoded caches the data of each stream object, and budget is what
+	// remains of the decoded bytes the document may use. Every use of a
+	// stream is charged, so a stream referenced many times cannot
+	// multiply its size either.
+	decoded map[*pdfStream]pdfDecodedStream
+	budget  int64
+}
+
+type pdfDecodedStream struct {
+	data []byte
+	err  error
+}
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+	mediaBox  []float64
+}
+
+func newPDFReader(data []byte, maxDecodedSize int64) (*pdfReader, error) {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	if !bytes.Contains(head, []byte("%PDF-")) {
+		return nil, fmt.Errorf("missing pdf header")
+	}
+
+	r := &pdfReader{
+		data:    data,
+		xref:    map[int]pdfXrefEntry{},
+		cache:   map[int]interface{}{},
+		objStms: map[int]*pdfObjStm{},
+		loading: map[int]bool{},
+		decoded: map[*pdfStream]pdfDecodedStream{},
+		budget:  maxDecodedSize,
+	}
+
+	if err := r.loadXref(); err != nil || r.catalog() == nil {
+		r.xref = map[int]pdfXrefEntry{}
+		r.trailer = nil
+		r.cache = map[int]interface{}{}
+		r.scanObjects()
+	}
+
+	if r.trailer != nil && r.trailer["Encrypt"] != nil {
+		return nil, errPDFEncrypted
+	}
+	if r.catalog() == nil {
+		return nil, errPDFNoPages
+	}
+
+	return r, nil
+}
+
+func (r *pdfReader) loadXref() error {
+	idx := bytes.LastIndex(r.data, []byte("startxref"))
+	if idx < 0 {
+		return errors.New("startxref not found")
+	}
+
+	l := &pdfLexer{data: r.data, pos: idx + len("startxref")}
+	tok, err := l.token()
+	if err != nil {
+		return err
+	}
+	offset, ok := tok.(int)
+	if !ok {
+		return errors.New("invalid startxref offset")
+	}
+
+	seen := map[int]bool{}
+	for offset > 0 && !seen[offset] {
+		seen[offset] = true
+		trailer, err := r.readXrefSection(offset)
+		if err != nil {
+			return err
+		}
+		if r.trailer == nil {
+			r.trailer = trailer
+		}
+		if stm, ok := trailer["XRefStm"].(int); ok && !seen[stm] {
+			seen[stm] = true
+			if _, err := r.readXrefSection(stm); err != nil {
+				return err
+			}
+		}
+		offset, _ = trailer["Prev"].(int)
+	}
+
+	if r.trailer == nil {
+		return errors.New("trailer not found")
+	}
+	return nil
+}
+
+func (r *pdfReader) setXref(num int, entry pdfXrefEntry) {
+	if _, exists := r.xref[num]; !exists {
+		r.xref[num] = entry
+	}
+}
+
+func (r *pdfReader) readXrefSection(offset int) (pdfDict, error) {
+	if offset < 0 || offset >= len(r.data) {
+		return nil, fmt.Errorf("xref offset %d out of range", offset)
+	}
+
+	l := &pdfLexer{data: r.data, pos: offset}
+	l.skipSpace()
+	if !bytes.HasPrefix(r.data[l.pos:], []byte("xref")) {
+		return r.readXrefStream(offset)
+	}
+	l.pos += len("xref")
+
+	for {
+		tok, err := l.token()
+		if err != nil {
+			return nil, err
+		}
+		if k, ok := tok.(pdfKeyword); ok && k == "trailer" {
+			obj, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			trailer, ok := obj.(pdfDict)
+			if !ok {
+				return nil, errors.New("invalid trailer")
+			}
+			return trailer, nil
+		}
+
+		start, ok := tok.(int)
+		if !ok {
+			return nil, errors.New("invalid xref subsection")
+		}
+		countTok, err := l.token()
+		if err != nil {
+			return nil, err
+		}
+		count, ok := countTok.(int)
+		if !ok {
+			return nil, errors.New("invalid xref subsection count")
+		}
+
+		for i := 0; i < count; i++ {
+			offTok, _ := l.token()
+			_, _ = l.token()
+			kind, _ := l.token()
+			off, ok := offTok.(int)
+			if !ok {
+				return nil, errors.New("invalid xref entry")
+			}
+			if k, _ := kind.(pdfKeyword); k == "n" {
+				r.setXref(start+i, pdfXrefEntry{offset: off})
+			} else {
+				r.setXref(start+i, pdfXrefEntry{offset: -1})
+			}
+		}
+	}
+}
+
+func (r *pdfReader) readXrefStream(offset int) (pdfDict, error) {
+	obj, err := r.readIndirect(offset)
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := obj.(*pdfStream)
+	if !ok {
+		return nil, errors.New("xref stream expected")
+	}
+
+	data, err := r.streamData(stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode xref stream: %w", err)
+	}
+
+	widths, _ := stream.dict["W"].(pdfArray)
+	if len(widths) < 3 {
+		return nil, errors.New("invalid xref stream widths")
+	}
+	var w [3]int
+	rowLen := 0
+	for i := 0; i < 3; i++ {
+		w[i], _ = pdfInt(widths[i])
+		rowLen += w[i]
+	}
+	if rowLen == 0 {
+		return nil, errors.New("invalid xref stream widths")
+	}
+
+	size, _ := pdfInt(stream.dict["Size"])
+	index, _ := stream.dict["Index"].(pdfArray)
+	if len(index) == 0 {
+		index = pdfArray{0, size}
+	}
+
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, _ := pdfInt(index[i])
+		count, _ := pdfInt(index[i+1])
+		for j := 0; j < count && pos+rowLen <= len(data); j++ {
+			var fields [3]int
+			p := pos
+			for k := 0; k < 3; k++ {
+				for b := 0; b < w[k]; b++ {
+					fields[k] = fields[k]<<8 | int(data[p])
+					p++
+				}
+			}
+			pos += rowLen
+			if w[0] == 0 {
+				fields[0] = 1
+			}
+			switch fields[0] {
+			case 0:
+				r.setXref(start+j, pdfXrefEntry{offset: -1})
+			case 1:
+				r.setXref(start+j, pdfXrefEntry{offset: fields[1]})
+			case 2:
+				r.setXref(start+j, pdfXrefEntry{inStream: true, stream: fields[1], index: fields[2]})
+			}
+		}
+	}
+
+	return stream.dict, nil
+}
+
+func (r *pdfReader) scanObjects() {
+	for _, m := range pdfObjectHeader.FindAllSubmatchIndex(r.data, -1) {
+		if m[0] > 0 && !isPDFWhitespace(r.data[m[0]-1]) && !isPDFDelimiter(r.data[m[0]-1]) {
+			continue
+		}
+		num, _ := atoiBytes(r.data[m[2]:m[3]])
+		r.xref[num] = pdfXrefEntry{offset: m[0]}
+	}
+
+	direct := make(map[int]int, len(r.xref))
+	for num, entry := range r.xref {
+		direct[num] = entry.offset
+	}
+
+	for num, offset := range direct {
+		obj, err := r.readIndirect(offset)
+		if err != nil {
+			continue
+		}
+		stream, ok := obj.(*pdfStream)
+		if !ok || stream.dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		stm, err := r.loadObjStm(num, stream)
+		if err != nil {
+			continue
+		}
+		for inner, off := range stm.offsets {
+			if _, exists := r.xref[inner]; !exists {
+				r.xref[inner] = pdfXrefEntry{inStream: true, stream: num, index: off}
+			}
+		}
+	}
+
+	for pos := len(r.data); ; {
+		idx := bytes.LastIndex(r.data[:pos], []byte("trailer"))
+		if idx < 0 {
+			break
+		}
+		l := &pdfLexer{data: r.data, pos: idx + len("trailer")}
+		if obj, err := l.object(); err == nil {
+			if dict, ok := obj.(pdfDict); ok && dict["Root"] != nil {
+				r.trailer = dict
+				return
+			}
+		}
+		pos = idx
+	}
+
+	for num := range r.xref {
+		if dict, ok := r.getObject(num).(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+			r.trailer = pdfDict{"Root": pdfRef{num: num}}
+			return
+		}
+	}
+}
+
+func atoiBytes(b []byte) (int, bool) {
+	n := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+func (r *pdfReader) readIndirect(offset int) (interface{}, error) {
+	if offset < 0 || offset >= len(r.data) {
+		return nil, fmt.Errorf("object offset %d out of range", offset)
+	}
+
+	l := &pdfLexer{data: r.data, pos: offset}
+	if _, err := l.token(); err != nil {
+		return nil, err
+	}
+	if _, err := l.token(); err != nil {
+		return nil, err
+	}
+	if tok, err := l.token(); err != nil || tok != pdfKeyword("obj") {
+		return nil, errPDFSyntax
+	}
+
+	obj, err := l.object()
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := obj.(pdfDict)
+	if !ok {
+		return obj, nil
+	}
+
+	save := l.pos
+	if tok, err := l.token(); err != nil || tok != pdfKeyword("stream") {
+		l.pos = save
+		return dict, nil
+	}
+
+	start := l.pos
+	if start < len(r.data) && r.data[start] == '\r' {
+		start++
+	}
+	if start < len(r.data) && r.data[start] == '\n' {
+		start++
+	}
+
+	length, ok := pdfInt(dict["Length"])
+	if ref, isRef := dict["Length"].(pdfRef); isRef {
+		length, ok = pdfInt(r.getObject(ref.num))
+	}
+	if ok && length >= 0 && start+length <= len(r.data) {
+		end := &pdfLexer{data: r.data, pos: start + length}
+		end.skipSpace()
+		if bytes.HasPrefix(r.data[end.pos:], []byte("endstream")) {
+			return &pdfStream{dict: dict, data: r.data[start : start+length]}, nil
+		}
+	}
+
+	idx := bytes.Index(r.data[start:], []byte("endstream"))
+	if idx < 0 {
+		return &pdfStream{dict: dict, data: r.data[start:]}, nil
+	}
+	data := r.data[start : start+idx]
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+	return &pdfStream{dict: dict, data: data}, nil
+}
+
+func (r *pdfReader) getObject(num int) interface{} {
+	if obj, ok := r.cache[num]; ok {
+		return obj
+	}
+	entry, ok := r.xref[num]
+	if !ok || r.loading[num] {
+		return nil
+	}
+
+	r.loading[num] = true
+	defer delete(r.loading, num)
+
+	var obj interface{}
+	if entry.inStream {
+		obj = r.objectFromStream(entry.stream, num, entry.index)
+	} else if entry.offset >= 0 {
+		obj, _ = r.readIndirect(entry.offset)
+	}
+
+	r.cache[num] = obj
+	return obj
+}
+
+func (r *pdfReader) objectFromStream(streamNum, num, index int) interface{} {
+	stm, ok := r.objStms[streamNum]
+	if !ok {
+		stream, isStream := r.getObject(streamNum).(*pdfStream)
+		if !isStream {
+			return nil
+		}
+		var err error
+		stm, err = r.loadObjStm(streamNum, stream)
+		if err != nil {
+			return nil
+		}
+	}
+
+	off, ok := stm.offsets[num]
+	if !ok || off >= len(stm.data) {
+		return nil
+	}
+	l := &pdfLexer{data: stm.data, pos: off}
+	obj, err := l.object()
+	if err != nil {
+		return nil
+	}
+	return obj
+}
+
+func (r *pdfReader) loadObjStm(num int, stream *pdfStream) (*pdfObjStm, error) {
+	if stm, ok := r.objStms[num]; ok {
+		return stm, nil
+	}
+
+	data, err := r.streamData(stream)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := pdfInt(stream.dict["N"])
+	first, _ := pdfInt(stream.dict["First"])
+
+	stm := &pdfObjStm{data: data, offsets: map[int]int{}}
+	l := &pdfLexer{data: data}
+	for i := 0; i < n; i++ {
+		numTok, err1 := l.token()
+		offTok, err2 := l.token()
+		if err1 != nil || err2 != nil {
+			break
+		}
+		objNum, ok1 := numTok.(int)
+		off, ok2 := offTok.(int)
+		if !ok1 || !ok2 {
+			break
+		}
+		stm.offsets[objNum] = first + off
+	}
+
+	r.objStms[num] = stm
+	return stm, nil
+}
+
+func (r *pdfReader) resolve(obj interface{}) interface{} {
+	for i := 0; i < pdfMaxResolveDepth; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = r.getObject(ref.num)
+	}
+	return nil
+}
+
+func (r *pdfReader) dict(obj interface{}) pdfDict {
+	switch v := r.resolve(obj).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+func (r *pdfReader) catalog() pdfDict {
+	if r.trailer == nil {
+		return nil
+	}
+	return r.dict(r.trailer["Root"])
+}
+
+func (r *pdfReader) pages() []pdfPage {
+	root := r.catalog()
+	if root == nil {
+		return nil
+	}
+	var pages []pdfPage
+	visited := map[int]bool{}
+	r.walkPages(root["Pages"], pdfPage{}, visited, 0, &pages)
+	return pages
+}
+
+// walkPages collects the leaves of the page tree. inherited carries the
+// resources and media box passed down from ancestor nodes.
+func (r *pdfReader) walkPages(node interface{}, inherited pdfPage, visited map[int]bool, depth int, pages *[]pdfPage) {
+	if depth > pdfMaxTreeDepth {
+		return
+	}
+	if ref, ok := node.(pdfRef); ok {
+		if visited[ref.num] {
+			return
+		}
+		visited[ref.num] = true
+	}
+
+	dict := r.dict(node)
+	if dict == nil {
+		return
+	}
+
+	page := pdfPage{dict: dict, resources: inherited.resources, mediaBox: inherited.mediaBox}
+	if res := r.dict(dict["Resources"]); res != nil {
+		page.resources = res
+	}
+	if box := r.rect(dict["MediaBox"]); box != nil {
+		page.mediaBox = box
+	}
+
+	kids, hasKids := r.resolve(dict["Kids"]).(pdfArray)
+	if dict["Type"] == pdfName("Pages") || (hasKids && dict["Type"] != pdfName("Page")) {
+		for _, kid := range kids {
+			r.walkPages(kid, page, visited, depth+1, pages)
+		}
+		return
+	}
+
+	*pages = append(*pages, page)
+}
+
+// rect reads a PDF rectangle array, returning nil if obj is not one.
+func (r *pdfReader) rect(obj interface{}) []float64 {
+	arr, ok := r.resolve(obj).(pdfArray)
+	if !ok || len(arr) != 4 {
+		return nil
+	}
+	box := make([]float64, 4)
+	for i, v := range arr {
+		n, ok := pdfNumber(r.resolve(v))
+		if !ok {
+			return nil
+		}
+		box[i] = n
+	}
+	return box
+}
+
+func (r *pdfReader) pageContent(page pdfPage) []byte {
+	var streams []interface{}
+	switch c := r.resolve(page.dict["Contents"]).(type) {
+	case pdfArray:
+		streams = c
+	case *pdfStream:
+		streams = []interface{}{c}
+	}
+
+	var buf bytes.Buffer
+	for _, s := range streams {
+		stream, ok := r.resolve(s).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := r.streamData(stream)
+		if err != nil && len(data) == 0 {
+			continue
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// streamData returns the decoded data of s, charging it to the document's
+// budget. Streams are decoded once; objects are cached by number, so every
+// reference to a stream yields the same *pdfStream.
+func (r *pdfReader) streamData(s *pdfStream) ([]byte, error) {
+	decoded, ok := r.decoded[s]
+	if !ok {
+		decoded.data, decoded.err = r.decodeStream(s)
+		r.decoded[s] = decoded
+	}
+	if int64(len(decoded.data)) > r.budget {
+		r.budget = 0
+		return nil, errPDFTooLarge
+	}
+	r.budget -= int64(len(decoded.data))
+	return decoded.data, decoded.err
+}
+
+// decodeStream applies the filters of s. Each filter may produce at most
+// the remaining budget.
+func (r *pdfReader) decodeStream(s *pdfStream) ([]byte, error) {
+	var filters, parms pdfArray
+	switch f := r.resolve(s.dict["Filter"]).(type) {
+	case pdfName:
+		filters = pdfArray{f}
+	case pdfArray:
+		filters = f
+	}
+	switch p := r.resolve(s.dict["DecodeParms"]).(type) {
+	case pdfDict:
+		parms = pdfArray{p}
+	case pdfArray:
+		parms = p
+	}
+
+	data := s.data
+	for i, f := range filters {
+		var parm pdfDict
+		if i < len(parms) {
+			parm = r.dict(parms[i])
+		}
+
+		var err error
+		switch name, _ := r.resolve(f).(pdfName); name {
+		case "FlateDecode", "Fl":
+			data, err = flateDecode(data, r.budget)
+			if err == nil || len(data) > 0 {
+				data, err = applyPNGPredictor(data, parm)
+			}
+		case "LZWDecode", "LZW":
+			early := 1
+			if v, ok := pdfInt(parm["EarlyChange"]); ok {
+				early = v
+			}
+			data, err = lzwDecode(data, early == 1, r.budget)
+			if err == nil {
+				data, err = applyPNGPredictor(data, parm)
+			}
+		case "ASCIIHexDecode", "AHx":
+			l := &pdfLexer{data: data}
+			data = l.hexString()
+		case "ASCII85Decode", "A85":
+			data, err = ascii85Decode(data, r.budget)
+		case "RunLengthDecode", "RL":
+			data, err = runLengthDecode(data, r.budget)
+		default:
+			return nil, fmt.Errorf("unsupported pdf filter: %s", name)
+		}
+		if err != nil {
+			return data, err
+		}
+	}
+	return data, nil
+}
+
+// flateDecode inflates a zlib stream, or a raw deflate stream as some
+// writers produce, failing with errPDFTooLarge beyond limit bytes.
+func flateDecode(data []byte, limit int64) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		out, rawErr := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(data)), limit+1))
+		if int64(len(out)) > limit {
+			return nil, errPDFTooLarge
+		}
+		if len(out) > 0 {
+			return out, nil
+		}
+		return nil, rawErr
+	}
+	defer zr.Close()
+
+	out, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if int64(len(out)) > limit {
+		return nil, errPDFTooLarge
+	}
+	if err != nil && len(out) > 0 {
+		return out, nil
+	}
+	return out, err
+}
+
+func applyPNGPredictor(data []byte, parm pdfDict) ([]byte, error) {
+	predictor, _ := pdfInt(parm["Predictor"])
+	if predictor < 10 {
+		return data, nil
+	}
+
+	colors, bpc, columns := 1, 8, 1
+	if v, ok := pdfInt(parm["Colors"]); ok && v > 0 {
+		colors = v
+	}
+	if v, ok := pdfInt(parm["BitsPerComponent"]); ok && v > 0 {
+		bpc = v
+	}
+	if v, ok := pdfInt(parm["Columns"]); ok && v > 0 {
+		columns = v
+	}
+
+	bpp := (colors*bpc + 7) / 8
+	rowLen := (colors*bpc*columns + 7) / 8
+	if rowLen <= 0 {
+		return data, nil
+	}
+
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+	for pos := 0; pos+1+rowLen <= len(data); pos += rowLen + 1 {
+		kind := data[pos]
+		row := make([]byte, rowLen)
+		copy(row, data[pos+1:pos+1+rowLen])
+		for i := 0; i < rowLen; i++ {
+			var left, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up := prev[i]
+			switch kind {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// ascii85Decode decodes ASCII base-85 data, failing with errPDFTooLarge
+// beyond limit bytes.
+func ascii85Decode(data []byte, limit int64) ([]byte, error) {
+	var out []byte
+	var group [5]byte
+	n := 0
+	for _, c := range data {
+		if c == '~' {
+			break
+		}
+		if isPDFWhitespace(c) {
+			continue
+		}
+		if int64(len(out))+4 > limit {
+			return nil, errPDFTooLarge
+		}
+		if c == 'z' && n == 0 {
+			out = append(out, 0, 0, 0, 0)
+			continue
+		}
+		if c < '!' || c > 'u' {
+			return out, fmt.Errorf("invalid ascii85 byte: %q", c)
+		}
+		group[n] = c - '!'
+		n++
+		if n == 5 {
+			v := uint32(0)
+			for _, g := range group {
+				v = v*85 + uint32(g)
+			}
+			out = append(out, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+			n = 0
+		}
+	}
+	if n > 1 {
+		for i := n; i < 5; i++ {
+			group[i] = 84
+		}
+		v := uint32(0)
+		for _, g := range group {
+			v = v*85 + uint32(g)
+		}
+		tail := []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+		out = append(out, tail[:n-1]...)
+	}
+	return out, nil
+}
+
+// runLengthDecode expands RunLength data, failing with errPDFTooLarge beyond
+// limit bytes.
+func runLengthDecode(data []byte, limit int64) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(data); {
+		n := int(data[i])
+		i++
+		if int64(len(out))+128 > limit {
+			return nil, errPDFTooLarge
+		}
+		switch {
+		case n == 128:
+			return out, nil
+		case n < 128:
+			end := i + n + 1
+			if end > len(data) {
+				end = len(data)
+			}
+			out = append(out, data[i:end]...)
+			i = end
+		default:
+			if i < len(data) {
+				out = append(out, bytes.Repeat(data[i:i+1], 257-n)...)
+				i++
+			}
+		}
+	}
+	return out, nil
+}
+
+// lzwDecode expands LZW data, failing with errPDFTooLarge beyond limit
+// bytes. Malformed input ends the output early.
+func lzwDecode(data []byte, earlyChange bool, limit int64) ([]byte, error) {
+	var (
+		out    []byte
+		table  [][]byte
+		prev   []byte
+		bitBuf uint32
+		bits   uint
+		width  uint = 9
+	)
+	reset := func() {
+		table = table[:0]
+		for i := 0; i < 256; i++ {
+			table = append(table, []byte{byte(i)})
+		}
+		table = append(table, nil, nil)
+		width = 9
+		prev = nil
+	}
+	reset()
+
+	early := 0
+	if earlyChange {
+		early = 1
+	}
+
+	for _, b := range data {
+		bitBuf = bitBuf<<8 | uint32(b)
+		bits += 8
+		for bits >= width {
+			code := int(bitBuf>>(bits-width)) & (1<<width - 1)
+			bits -= width
+
+			if code == 256 {
+				reset()
+				continue
+			}
+			if code == 257 {
+				return out, nil
+			}
+
+			var entry []byte
+			switch {
+			case code < len(table) && table[code] != nil:
+				entry = table[code]
+			case code == len(table) && prev != nil:
+				entry = append(append([]byte{}, prev...), prev[0])
+			default:
+				return out, nil
+			}
+			if int64(len(out)+len(entry)) > limit {
+				return nil, errPDFTooLarge
+			}
+			out = append(out, entry...)
+
+			if prev != nil {
+				table = append(table, append(append([]byte{}, prev...), entry[0]))
+			}
+			prev = entry
+
+			if len(table)+early >= 1<<width && width < 12 {
+				width++
+			}
+		}
+	}
+	return out, nil
+}
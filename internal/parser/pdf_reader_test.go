@@ -0,0 +1,293 @@
+package parser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildTestPDF assembles a PDF with a classic xref table. objects[i] is the
+// body of object i+1, and object 1 must be the catalog.
+func buildTestPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// testPDFStream returns a stream object holding data, with dict's entries.
+func testPDFStream(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func zlibCompress(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestFlateDecodeLimit(t *testing.T) {
+	compressed := zlibCompress(bytes.Repeat([]byte("0"), 1<<20))
+
+	if out, err := flateDecode(compressed, 1<<20); err != nil || len(out) != 1<<20 {
+		t.Errorf("at the limit: %d bytes, err = %v", len(out), err)
+	}
+	if _, err := flateDecode(compressed, 1<<20-1); !errors.Is(err, errPDFTooLarge) {
+		t.Errorf("over the limit: err = %v, want errPDFTooLarge", err)
+	}
+}
+
+func TestFilterDecodeLimits(t *testing.T) {
+	// Clear, 'A', then codes 258, 259, ... each naming the previous string
+	// plus one more 'A': 1+2+...+201 bytes from 9-bit codes.
+	codes := []int{256, 'A'}
+	for code := 258; code < 458; code++ {
+		codes = append(codes, code)
+	}
+	var lzw []byte
+	var bitBuf, bits uint32
+	for _, code := range append(codes, 257) {
+		bitBuf = bitBuf<<9 | uint32(code)
+		for bits += 9; bits >= 8; bits -= 8 {
+			lzw = append(lzw, byte(bitBuf>>(bits-8)))
+		}
+	}
+	lzw = append(lzw, byte(bitBuf<<(8-bits)))
+
+	for _, tc := range []struct {
+		name   string
+		decode func(limit int64) ([]byte, error)
+		size   int
+	}{
+		{"RunLength", func(limit int64) ([]byte, error) {
+			return runLengthDecode(bytes.Repeat([]byte{129, 'x'}, 100), limit)
+		}, 12800},
+		{"ASCII85", func(limit int64) ([]byte, error) {
+			return ascii85Decode(bytes.Repeat([]byte("z"), 100), limit)
+		}, 400},
+		{"LZW", func(limit int64) ([]byte, error) {
+			return lzwDecode(lzw, true, limit)
+		}, 201 * 202 / 2},
+	} {
+		out, err := tc.decode(1 << 20)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(out) != tc.size {
+			t.Errorf("%s: %d bytes, want %d", tc.name, len(out), tc.size)
+		}
+		if _, err := tc.decode(int64(len(out) - 1)); !errors.Is(err, errPDFTooLarge) {
+			t.Errorf("%s over the limit: err = %v, want errPDFTooLarge", tc.name, err)
+		}
+	}
+}
+
+func TestPDFRepeatedContentsShareBudget(t *testing.T) {
+	content := append([]byte("BT (A) Tj ET\n"), bytes.Repeat([]byte(" "), 1<<20)...)
+	refs := strings.Repeat("4 0 R ", 50)
+	data := buildTestPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents ["+refs+"] >>",
+		testPDFStream("/Filter /FlateDecode", zlibCompress(content)),
+	)
+
+	const budget = 8 << 20
+	reader, err := newPDFReader(data, budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := reader.pages()
+	if len(pages) != 1 {
+		t.Fatalf("%d pages, want 1", len(pages))
+	}
+	if got := reader.pageContent(pages[0]); len(got) > budget || len(got) < 7*len(content) {
+		t.Errorf("page content is %d bytes, want the first %d bytes' worth of streams", len(got), budget)
+	}
+	if reader.budget != 0 || len(reader.decoded) != 1 {
+		t.Errorf("budget left %d with %d streams decoded, want 0 and 1", reader.budget, len(reader.decoded))
+	}
+}
+
+const (
+	testPDFCatalog = "<< /Type /Catalog /Pages 2 0 R >>"
+	testPDFFont    = "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"
+)
+
+// testPDFPage returns a page object drawing its content with font object
+// fontNum as /F1.
+func testPDFPage(contentNum, fontNum int) string {
+	return fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents %d 0 R /Resources << /Font << /F1 %d 0 R >> >> >>", contentNum, fontNum)
+}
+
+func testPDFText(text string) string {
+	return testPDFStream("", []byte("BT /F1 12 Tf 72 700 Td ("+text+") Tj ET"))
+}
+
+// testTwoPagePDF is a classic PDF with a text page on each side of a page
+// with no content.
+func testTwoPagePDF() []byte {
+	return buildTestPDF(
+		testPDFCatalog,
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		testPDFPage(5, 7),
+		testPDFPage(6, 7),
+		testPDFText("Contract number HT-2024-001"),
+		testPDFText("Payment is due within thirty days"),
+		testPDFFont,
+	)
+}
+
+// buildTestPDFObjStm assembles a PDF 1.5 file whose first objects are packed
+// into a compressed object stream and indexed by a compressed xref stream.
+// The direct objects follow the packed ones.
+func buildTestPDFObjStm(packed, direct []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+
+	var offsets []int
+	for i, object := range direct {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(packed)+i+1, object)
+	}
+
+	var index, bodies bytes.Buffer
+	for i, object := range packed {
+		fmt.Fprintf(&index, "%d %d ", i+1, bodies.Len())
+		bodies.WriteString(object + "\n")
+	}
+	stmNum := len(packed) + len(direct) + 1
+	stmData := append(index.Bytes(), bodies.Bytes()...)
+	offsets = append(offsets, buf.Len())
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", stmNum, testPDFStream(
+		fmt.Sprintf("/Type /ObjStm /N %d /First %d /Filter /FlateDecode", len(packed), index.Len()),
+		zlibCompress(stmData)))
+
+	// Rows are a type byte, a 4-byte offset or object stream number and a
+	// 2-byte generation or index.
+	xrefNum := stmNum + 1
+	xrefAt := buf.Len()
+	offsets = append(offsets, xrefAt)
+	rows := []byte{0, 0, 0, 0, 0, 0xFF, 0xFF}
+	for i := range packed {
+		rows = append(rows, 2, 0, 0, 0, byte(stmNum), 0, byte(i))
+	}
+	for _, offset := range offsets {
+		rows = append(rows, 1, byte(offset>>24), byte(offset>>16), byte(offset>>8), byte(offset), 0, 0)
+	}
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", xrefNum, testPDFStream(
+		fmt.Sprintf("/Type /XRef /Size %d /W [1 4 2] /Root 1 0 R /Filter /FlateDecode", xrefNum+1),
+		zlibCompress(rows)))
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", xrefAt)
+	return buf.Bytes()
+}
+
+func parseTestPDF(t *testing.T, data []byte) []string {
+	t.Helper()
+	doc, err := NewPDFParser().Parse("test.pdf", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.PageCount != len(doc.Pages) {
+		t.Errorf("PageCount = %d with %d pages", doc.PageCount, len(doc.Pages))
+	}
+	texts := make([]string, len(doc.Pages))
+	for i, page := range doc.Pages {
+		texts[i] = strings.TrimSpace(page.Content)
+	}
+	return texts
+}
+
+func TestPDFClassicXref(t *testing.T) {
+	texts := parseTestPDF(t, testTwoPagePDF())
+	if len(texts) != 2 || texts[0] != "Contract number HT-2024-001" || texts[1] != "Payment is due within thirty days" {
+		t.Errorf("pages = %q", texts)
+	}
+}
+
+func TestPDFXrefAndObjectStreams(t *testing.T) {
+	data := buildTestPDFObjStm(
+		[]string{
+			testPDFCatalog,
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			testPDFPage(5, 4),
+			testPDFFont,
+		},
+		[]string{testPDFText("Governing law is the law of the PRC")},
+	)
+	if bytes.Contains(data, []byte("/Catalog")) {
+		t.Fatal("the catalog should only be inside the compressed object stream")
+	}
+
+	texts := parseTestPDF(t, data)
+	if len(texts) != 1 || texts[0] != "Governing law is the law of the PRC" {
+		t.Errorf("pages = %q", texts)
+	}
+}
+
+func TestPDFToUnicodeCJK(t *testing.T) {
+	const text = "甲方：上海星辰科技有限公司"
+	var codes, bfchar strings.Builder
+	for i, r := range []rune(text) {
+		fmt.Fprintf(&codes, "%04X", i+1)
+		fmt.Fprintf(&bfchar, "<%04X> <%04X>\n", i+1, r)
+	}
+	cmap := "/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n" +
+		"1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		fmt.Sprintf("%d beginbfchar\n%sendbfchar\n", len([]rune(text)), bfchar.String()) +
+		"endcmap CMapName currentdict /CMap defineresource pop end end"
+
+	texts := parseTestPDF(t, buildTestPDF(
+		testPDFCatalog,
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		testPDFPage(4, 5),
+		testPDFStream("", []byte("BT /F1 12 Tf 72 700 Td <"+codes.String()+"> Tj ET")),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /SimSun /Encoding /Identity-H /DescendantFonts [6 0 R] /ToUnicode 7 0 R >>",
+		"<< /Type /Font /Subtype /CIDFontType2 /BaseFont /SimSun /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /DW 1000 >>",
+		testPDFStream("", []byte(cmap)),
+	))
+	if len(texts) != 1 || texts[0] != text {
+		t.Errorf("pages = %q, want %q", texts, text)
+	}
+}
+
+func TestPDFBrokenXrefFallsBackToScan(t *testing.T) {
+	data := testTwoPagePDF()
+	at := bytes.LastIndex(data, []byte("startxref\n")) + len("startxref\n")
+	data = append(data[:at:at], "12345\n%%EOF\n"...)
+
+	texts := parseTestPDF(t, data)
+	if len(texts) != 2 || texts[0] != "Contract number HT-2024-001" || texts[1] != "Payment is due within thirty days" {
+		t.Errorf("pages = %q", texts)
+	}
+}
+
+func TestPDFPageCountWithoutText(t *testing.T) {
+	// Text extraction fails on encrypted files; the page count still comes
+	// from the page objects.
+	data := bytes.Replace(testTwoPagePDF(), []byte("/Root 1 0 R"), []byte("/Root 1 0 R /Encrypt << >>"), 1)
+
+	doc, err := NewPDFParser().Parse("test.pdf", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.PageCount != 2 || !doc.IsScanned || len(doc.Pages) != 0 {
+		t.Errorf("PageCount = %d, IsScanned = %v, %d pages", doc.PageCount, doc.IsScanned, len(doc.Pages))
+	}
+}
@@ -0,0 +1,856 @@
+package parser
+
+import (
+	"contract-key-extractor/internal/model"
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+const pdfMaxFormDepth = 8
+
+type pdfMatrix [6]float64
+
+var pdfIdentity = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+func (a pdfMatrix) mul(b pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		a[0]*b[0] + a[1]*b[2],
+		a[0]*b[1] + a[1]*b[3],
+		a[2]*b[0] + a[3]*b[2],
+		a[2]*b[1] + a[3]*b[3],
+		a[4]*b[0] + a[5]*b[2] + b[4],
+		a[4]*b[1] + a[5]*b[3] + b[5],
+	}
+}
+
+func matrixFromOperands(ops []interface{}) (pdfMatrix, bool) {
+	if len(ops) < 6 {
+		return pdfMatrix{}, false
+	}
+	var m pdfMatrix
+	for i := 0; i < 6; i++ {
+		v, ok := pdfNumber(ops[len(ops)-6+i])
+		if !ok {
+			return pdfMatrix{}, false
+		}
+		m[i] = v
+	}
+	return m, true
+}
+
+type pdfTextRun struct {
+	text  string
+	x     float64
+	y     float64
+	size  float64
+	width float64
+}
+
+type pdfPageText struct {
+	runs   []pdfTextRun
+	width  float64
+	height float64
+}
+
+// pdfLine is a line of text assembled from runs. gap is set when a blank
+// line separates it from the previous one.
+type pdfLine struct {
+	text string
+	gap  bool
+	box  model.BoundingBox
+}
+
+func (p *pdfPageText) lines() []pdfLine {
+	var lines []pdfLine
+	var b strings.Builder
+	var current pdfLine
+	var prev *pdfTextRun
+
+	for i := range p.runs {
+		run := &p.runs[i]
+		if prev != nil {
+			size := math.Max(run.size, prev.size)
+			if size <= 0 {
+				size = 10
+			}
+			dy := math.Abs(run.y - prev.y)
+			gap := run.x - (prev.x + prev.width)
+
+			switch {
+			case dy > size*0.5:
+				current.text = b.String()
+				lines = append(lines, current)
+				b.Reset()
+				current = pdfLine{gap: dy > size*2.2}
+			case run.text == prev.text && math.Abs(run.x-prev.x) < size*0.1:
+				continue
+			case gap > size*0.2:
+				if !strings.HasSuffix(prev.text, " ") && !strings.HasPrefix(run.text, " ") {
+					b.WriteByte(' ')
+				}
+			}
+		}
+		if b.Len() == 0 {
+			current.box = run.box()
+		} else {
+			current.box = unionBoxes(current.box, run.box())
+		}
+		b.WriteString(run.text)
+		prev = run
+	}
+	if prev != nil {
+		current.text = b.String()
+		lines = append(lines, current)
+	}
+
+	return lines
+}
+
+func (p *pdfPageText) String() string {
+	var b strings.Builder
+	for i, line := range p.lines() {
+		if i > 0 {
+			b.WriteByte('\n')
+			if line.gap {
+				b.WriteByte('\n')
+			}
+		}
+		b.WriteString(line.text)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// textLines returns the non-blank lines of the page with their positions.
+func (p *pdfPageText) textLines() []model.TextLine {
+	var lines []model.TextLine
+	for _, line := range p.lines() {
+		if strings.TrimSpace(line.text) != "" {
+			lines = append(lines, model.TextLine{Text: line.text, BBox: line.box})
+		}
+	}
+	return lines
+}
+
+// box approximates the area covered by the run from its baseline origin,
+// advance width and font size, allowing for descenders below the baseline.
+func (r *pdfTextRun) box() model.BoundingBox {
+	return model.BoundingBox{
+		X0: r.x,
+		Y0: r.y - r.size*0.2,
+		X1: r.x + r.width,
+		Y1: r.y + r.size*0.8,
+	}
+}
+
+func unionBoxes(a, b model.BoundingBox) model.BoundingBox {
+	return model.BoundingBox{
+		X0: math.Min(a.X0, b.X0),
+		Y0: math.Min(a.Y0, b.Y0),
+		X1: math.Max(a.X1, b.X1),
+		Y1: math.Max(a.Y1, b.Y1),
+	}
+}
+
+type pdfGlyph struct {
+	text  string
+	width float64
+	space bool
+}
+
+type pdfCodespace struct {
+	lo []byte
+	hi []byte
+}
+
+type pdfCMap struct {
+	ranges []pdfCodespace
+	chars  map[string]string
+}
+
+func (c *pdfCMap) codeLength(b []byte) int {
+	for _, r := range c.ranges {
+		n := len(r.lo)
+		if n == 0 || n > len(b) {
+			continue
+		}
+		match := true
+		for i := 0; i < n; i++ {
+			if b[i] < r.lo[i] || b[i] > r.hi[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return n
+		}
+	}
+	return 0
+}
+
+func parseCMap(data []byte) *pdfCMap {
+	cm := &pdfCMap{chars: map[string]string{}}
+	l := &pdfLexer{data: data}
+	var operands []interface{}
+
+	for {
+		obj, err := l.object()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			operands = operands[:0]
+			continue
+		}
+		kw, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch kw {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 && len(lo) == len(hi) {
+					cm.ranges = append(cm.ranges, pdfCodespace{lo: lo, hi: hi})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok := operands[i].(pdfString)
+				if !ok {
+					continue
+				}
+				if dst := cmapDestination(operands[i+1]); dst != "" {
+					cm.chars[string(src)] = dst
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 || len(lo) > 4 {
+					continue
+				}
+				start, end := bytesToUint(lo), bytesToUint(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				for code := start; code <= end; code++ {
+					key := string(uintToBytes(code, len(lo)))
+					offset := int(code - start)
+					switch dst := operands[i+2].(type) {
+					case pdfString:
+						cm.chars[key] = utf16BEString(incrementBytes(dst, offset))
+					case pdfArray:
+						if offset < len(dst) {
+							if s := cmapDestination(dst[offset]); s != "" {
+								cm.chars[key] = s
+							}
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+
+	return cm
+}
+
+func cmapDestination(v interface{}) string {
+	switch d := v.(type) {
+	case pdfString:
+		return utf16BEString(d)
+	case pdfName:
+		return glyphNameToText(string(d))
+	}
+	return ""
+}
+
+func bytesToUint(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+func uintToBytes(v uint32, n int) []byte {
+	out := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		out[i] = byte(v)
+		v >>= 8
+	}
+	return out
+}
+
+func incrementBytes(b []byte, delta int) []byte {
+	out := append([]byte{}, b...)
+	carry := delta
+	for i := len(out) - 1; i >= 0 && carry > 0; i-- {
+		sum := int(out[i]) + carry
+		out[i] = byte(sum)
+		carry = sum >> 8
+	}
+	return out
+}
+
+func utf16BEString(b []byte) string {
+	if len(b) == 1 {
+		return string(rune(b[0]))
+	}
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, binary.BigEndian.Uint16(b[i:]))
+	}
+	return string(utf16.Decode(units))
+}
+
+var pdfGlyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$",
+	"percent": "%", "ampersand": "&", "quotesingle": "'", "parenleft": "(", "parenright": ")",
+	"asterisk": "*", "plus": "+", "comma": ",", "hyphen": "-", "period": ".", "slash": "/",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5", "six": "6",
+	"seven": "7", "eight": "8", "nine": "9", "colon": ":", "semicolon": ";", "less": "<",
+	"equal": "=", "greater": ">", "question": "?", "at": "@", "bracketleft": "[",
+	"backslash": "\\", "bracketright": "]", "asciicircum": "^", "underscore": "_",
+	"grave": "`", "braceleft": "{", "bar": "|", "braceright": "}", "asciitilde": "~",
+	"bullet": "•", "endash": "–", "emdash": "—", "quoteleft": "‘", "quoteright": "’",
+	"quotedblleft": "“", "quotedblright": "”", "ellipsis": "…", "yen": "¥", "section": "§",
+	"degree": "°", "copyright": "©", "registered": "®", "trademark": "™", "percent.full": "％",
+	"fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi", "ffl": "ffl", "nbspace": " ",
+}
+
+func glyphNameToText(name string) string {
+	if idx := strings.IndexByte(name, '.'); idx > 0 {
+		if s, ok := pdfGlyphNames[name]; ok {
+			return s
+		}
+		name = name[:idx]
+	}
+	if s, ok := pdfGlyphNames[name]; ok {
+		return s
+	}
+	if len(name) == 1 && unicode.IsLetter(rune(name[0])) {
+		return name
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 && (len(name)-3)%4 == 0 {
+		var units []uint16
+		for i := 3; i+4 <= len(name); i += 4 {
+			v, err := strconv.ParseUint(name[i:i+4], 16, 16)
+			if err != nil {
+				return ""
+			}
+			units = append(units, uint16(v))
+		}
+		return string(utf16.Decode(units))
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if v, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return string(rune(v))
+		}
+	}
+	return ""
+}
+
+type pdfFont struct {
+	composite    bool
+	codeLen      int
+	toUnicode    *pdfCMap
+	encodingCMap *pdfCMap
+	encoding     [256]string
+	utf16        bool
+	multiByte    encoding.Encoding
+	firstChar    int
+	widths       []float64
+	cidWidths    map[int]float64
+	defaultWidth float64
+	widthScale   float64
+}
+
+func (r *pdfReader) loadFont(dict pdfDict) *pdfFont {
+	f := &pdfFont{codeLen: 1, defaultWidth: 500, widthScale: 1}
+
+	if stream, ok := r.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := r.streamData(stream); err == nil || len(data) > 0 {
+			f.toUnicode = parseCMap(data)
+		}
+	}
+
+	if dict["Subtype"] == pdfName("Type0") {
+		f.composite = true
+		f.codeLen = 2
+		f.defaultWidth = 1000
+
+		if descendants, ok := r.resolve(dict["DescendantFonts"]).(pdfArray); ok && len(descendants) > 0 {
+			desc := r.dict(descendants[0])
+			if dw, ok := pdfNumber(r.resolve(desc["DW"])); ok {
+				f.defaultWidth = dw
+			}
+			f.cidWidths = r.parseCIDWidths(desc["W"])
+		}
+
+		switch enc := r.resolve(dict["Encoding"]).(type) {
+		case pdfName:
+			name := string(enc)
+			switch {
+			case strings.Contains(name, "UCS2") || strings.Contains(name, "UTF16"):
+				f.utf16 = true
+			case strings.HasPrefix(name, "GB"):
+				f.multiByte = simplifiedchinese.GBK
+			case strings.HasPrefix(name, "B5") || strings.HasPrefix(name, "ETen") || strings.HasPrefix(name, "CNS-EUC"):
+				f.multiByte = traditionalchinese.Big5
+			}
+		case *pdfStream:
+			if data, err := r.streamData(enc); err == nil {
+				f.encodingCMap = parseCMap(data)
+			}
+		}
+		return f
+	}
+
+	base := charmap.Windows1252
+	var differences pdfArray
+	switch enc := r.resolve(dict["Encoding"]).(type) {
+	case pdfName:
+		if enc == "MacRomanEncoding" {
+			base = charmap.Macintosh
+		}
+	case pdfDict:
+		if enc["BaseEncoding"] == pdfName("MacRomanEncoding") {
+			base = charmap.Macintosh
+		}
+		differences, _ = r.resolve(enc["Differences"]).(pdfArray)
+	}
+
+	for i := 0; i < 256; i++ {
+		if i < 0x20 {
+			continue
+		}
+		if out, err := base.NewDecoder().Bytes([]byte{byte(i)}); err == nil {
+			f.encoding[i] = string(out)
+		}
+	}
+
+	code := 0
+	for _, item := range differences {
+		switch v := r.resolve(item).(type) {
+		case int:
+			code = v
+		case float64:
+			code = int(v)
+		case pdfName:
+			if code >= 0 && code < 256 {
+				f.encoding[code] = glyphNameToText(string(v))
+			}
+			code++
+		}
+	}
+
+	f.firstChar, _ = pdfInt(r.resolve(dict["FirstChar"]))
+	if widths, ok := r.resolve(dict["Widths"]).(pdfArray); ok {
+		f.defaultWidth = 0
+		if desc := r.dict(dict["FontDescriptor"]); desc != nil {
+			if mw, ok := pdfNumber(r.resolve(desc["MissingWidth"])); ok {
+				f.defaultWidth = mw
+			}
+		}
+		for _, w := range widths {
+			v, _ := pdfNumber(r.resolve(w))
+			f.widths = append(f.widths, v)
+		}
+	}
+	if dict["Subtype"] == pdfName("Type3") {
+		if fm, ok := r.resolve(dict["FontMatrix"]).(pdfArray); ok && len(fm) > 0 {
+			if a, ok := pdfNumber(fm[0]); ok {
+				f.widthScale = a * 1000
+			}
+		}
+	}
+
+	return f
+}
+
+func (r *pdfReader) parseCIDWidths(obj interface{}) map[int]float64 {
+	arr, ok := r.resolve(obj).(pdfArray)
+	if !ok {
+		return nil
+	}
+	widths := map[int]float64{}
+	for i := 0; i < len(arr); {
+		first, ok := pdfInt(r.resolve(arr[i]))
+		if !ok || i+1 >= len(arr) {
+			break
+		}
+		if list, ok := r.resolve(arr[i+1]).(pdfArray); ok {
+			for j, w := range list {
+				if v, ok := pdfNumber(r.resolve(w)); ok {
+					widths[first+j] = v
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(arr) {
+			break
+		}
+		last, _ := pdfInt(r.resolve(arr[i+1]))
+		w, _ := pdfNumber(r.resolve(arr[i+2]))
+		if last-first <= 0xFFFF {
+			for c := first; c <= last; c++ {
+				widths[c] = w
+			}
+		}
+		i += 3
+	}
+	return widths
+}
+
+func (f *pdfFont) codeLength(b []byte) int {
+	n := 0
+	if f.toUnicode != nil {
+		n = f.toUnicode.codeLength(b)
+	}
+	if n == 0 && f.encodingCMap != nil {
+		n = f.encodingCMap.codeLength(b)
+	}
+	if n == 0 {
+		n = f.codeLen
+	}
+	if n > len(b) {
+		n = len(b)
+	}
+	return n
+}
+
+func (f *pdfFont) width(code []byte) float64 {
+	v := int(bytesToUint(code))
+	if f.composite {
+		if w, ok := f.cidWidths[v]; ok {
+			return w
+		}
+		return f.defaultWidth
+	}
+	if idx := v - f.firstChar; idx >= 0 && idx < len(f.widths) {
+		return f.widths[idx] * f.widthScale
+	}
+	return f.defaultWidth * f.widthScale
+}
+
+func (f *pdfFont) decode(s []byte) []pdfGlyph {
+	if f.multiByte != nil && f.toUnicode == nil {
+		out, err := f.multiByte.NewDecoder().Bytes(s)
+		if err != nil {
+			return nil
+		}
+		var glyphs []pdfGlyph
+		for _, r := range string(out) {
+			glyphs = append(glyphs, pdfGlyph{text: string(r), width: f.defaultWidth, space: r == ' '})
+		}
+		return glyphs
+	}
+
+	var glyphs []pdfGlyph
+	for i := 0; i < len(s); {
+		n := f.codeLength(s[i:])
+		code := s[i : i+n]
+		i += n
+
+		g := pdfGlyph{width: f.width(code), space: n == 1 && code[0] == ' '}
+		if f.toUnicode != nil {
+			g.text = f.toUnicode.chars[string(code)]
+		}
+		if g.text == "" {
+			switch {
+			case !f.composite:
+				g.text = f.encoding[code[0]]
+			case f.utf16:
+				g.text = utf16BEString(code)
+			}
+		}
+		glyphs = append(glyphs, g)
+	}
+	return glyphs
+}
+
+type pdfGState struct {
+	ctm       pdfMatrix
+	font      *pdfFont
+	fontSize  float64
+	charSpace float64
+	wordSpace float64
+	hScale    float64
+	leading   float64
+	rise      float64
+}
+
+type pdfTextExtractor struct {
+	reader *pdfReader
+	fonts  map[int]*pdfFont
+	page   pdfPageText
+}
+
+func (r *pdfReader) extractPageText(page pdfPage) pdfPageText {
+	ex := &pdfTextExtractor{reader: r, fonts: map[int]*pdfFont{}}
+	ex.run(r.pageContent(page), page.resources, pdfIdentity, 0)
+	if len(page.mediaBox) == 4 {
+		ex.page.width = math.Abs(page.mediaBox[2] - page.mediaBox[0])
+		ex.page.height = math.Abs(page.mediaBox[3] - page.mediaBox[1])
+	}
+	return ex.page
+}
+
+func (ex *pdfTextExtractor) font(resources pdfDict, name pdfName) *pdfFont {
+	fonts := ex.reader.dict(resources["Font"])
+	if fonts == nil {
+		return nil
+	}
+	entry := fonts[name]
+	if ref, ok := entry.(pdfRef); ok {
+		if f, cached := ex.fonts[ref.num]; cached {
+			return f
+		}
+		dict := ex.reader.dict(ref)
+		if dict == nil {
+			return nil
+		}
+		f := ex.reader.loadFont(dict)
+		ex.fonts[ref.num] = f
+		return f
+	}
+	if dict := ex.reader.dict(entry); dict != nil {
+		return ex.reader.loadFont(dict)
+	}
+	return nil
+}
+
+func (ex *pdfTextExtractor) run(content []byte, resources pdfDict, ctm pdfMatrix, depth int) {
+	l := &pdfLexer{data: content}
+	state := pdfGState{ctm: ctm, hScale: 1}
+	var (
+		stack    []pdfGState
+		operands []interface{}
+		tm, tlm  = pdfIdentity, pdfIdentity
+	)
+
+	num := func(i int) float64 {
+		if i >= len(operands) {
+			return 0
+		}
+		v, _ := pdfNumber(operands[i])
+		return v
+	}
+	moveLine := func(tx, ty float64) {
+		tlm = pdfMatrix{1, 0, 0, 1, tx, ty}.mul(tlm)
+		tm = tlm
+	}
+
+	for {
+		obj, err := l.object()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			operands = operands[:0]
+			continue
+		}
+		op, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch op {
+		case "q":
+			stack = append(stack, state)
+		case "Q":
+			if len(stack) > 0 {
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := matrixFromOperands(operands); ok {
+				state.ctm = m.mul(state.ctm)
+			}
+		case "BT":
+			tm, tlm = pdfIdentity, pdfIdentity
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					state.font = ex.font(resources, name)
+				}
+				state.fontSize = num(1)
+			}
+		case "Tc":
+			state.charSpace = num(0)
+		case "Tw":
+			state.wordSpace = num(0)
+		case "Tz":
+			state.hScale = num(0) / 100
+		case "TL":
+			state.leading = num(0)
+		case "Ts":
+			state.rise = num(0)
+		case "Td":
+			moveLine(num(0), num(1))
+		case "TD":
+			state.leading = -num(1)
+			moveLine(num(0), num(1))
+		case "Tm":
+			if m, ok := matrixFromOperands(operands); ok {
+				tm, tlm = m, m
+			}
+		case "T*":
+			moveLine(0, -state.leading)
+		case "Tj":
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					ex.show(&state, &tm, s)
+				}
+			}
+		case "'":
+			moveLine(0, -state.leading)
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					ex.show(&state, &tm, s)
+				}
+			}
+		case "\"":
+			if len(operands) >= 3 {
+				state.wordSpace = num(0)
+				state.charSpace = num(1)
+				moveLine(0, -state.leading)
+				if s, ok := operands[2].(pdfString); ok {
+					ex.show(&state, &tm, s)
+				}
+			}
+		case "TJ":
+			if len(operands) == 0 {
+				break
+			}
+			arr, ok := operands[len(operands)-1].(pdfArray)
+			if !ok {
+				break
+			}
+			for _, item := range arr {
+				switch v := item.(type) {
+				case pdfString:
+					ex.show(&state, &tm, v)
+				case int, float64:
+					adj, _ := pdfNumber(v)
+					tx := -adj / 1000 * state.fontSize * state.hScale
+					tm = pdfMatrix{1, 0, 0, 1, tx, 0}.mul(tm)
+				}
+			}
+		case "Do":
+			if len(operands) > 0 {
+				if name, ok := operands[len(operands)-1].(pdfName); ok {
+					ex.doXObject(resources, name, state.ctm, depth)
+				}
+			}
+		case "BI":
+			ex.skipInlineImage(l)
+		}
+		operands = operands[:0]
+	}
+}
+
+func (ex *pdfTextExtractor) show(state *pdfGState, tm *pdfMatrix, s []byte) {
+	if state.font == nil {
+		return
+	}
+
+	var text strings.Builder
+	width := 0.0
+	for _, g := range state.font.decode(s) {
+		text.WriteString(g.text)
+		w := g.width/1000*state.fontSize + state.charSpace
+		if g.space {
+			w += state.wordSpace
+		}
+		width += w * state.hScale
+	}
+
+	render := tm.mul(state.ctm)
+	trm := pdfMatrix{state.fontSize * state.hScale, 0, 0, state.fontSize, 0, state.rise}.mul(render)
+	if t := text.String(); t != "" {
+		ex.page.runs = append(ex.page.runs, pdfTextRun{
+			text:  t,
+			x:     trm[4],
+			y:     trm[5],
+			size:  math.Hypot(trm[2], trm[3]),
+			width: width * math.Hypot(render[0], render[1]),
+		})
+	}
+
+	*tm = pdfMatrix{1, 0, 0, 1, width, 0}.mul(*tm)
+}
+
+func (ex *pdfTextExtractor) doXObject(resources pdfDict, name pdfName, ctm pdfMatrix, depth int) {
+	xobjects := ex.reader.dict(resources["XObject"])
+	if xobjects == nil {
+		return
+	}
+	stream, ok := ex.reader.resolve(xobjects[name]).(*pdfStream)
+	if !ok {
+		return
+	}
+
+	if stream.dict["Subtype"] != pdfName("Form") || depth >= pdfMaxFormDepth {
+		return
+	}
+	data, err := ex.reader.streamData(stream)
+	if err != nil && len(data) == 0 {
+		return
+	}
+	formResources := ex.reader.dict(stream.dict["Resources"])
+	if formResources == nil {
+		formResources = resources
+	}
+	if m, ok := matrixFromOperands(ex.reader.resolveArray(stream.dict["Matrix"])); ok {
+		ctm = m.mul(ctm)
+	}
+	ex.run(data, formResources, ctm, depth+1)
+}
+
+func (r *pdfReader) resolveArray(obj interface{}) []interface{} {
+	arr, _ := r.resolve(obj).(pdfArray)
+	out := make([]interface{}, len(arr))
+	for i, v := range arr {
+		out[i] = r.resolve(v)
+	}
+	return out
+}
+
+func (ex *pdfTextExtractor) skipInlineImage(l *pdfLexer) {
+	for {
+		tok, err := l.token()
+		if err != nil {
+			return
+		}
+		if tok == pdfKeyword("ID") {
+			break
+		}
+	}
+	l.pos++
+	for ; l.pos+1 < len(l.data); l.pos++ {
+		if l.data[l.pos] == 'E' && l.data[l.pos+1] == 'I' && isPDFWhitespace(l.data[l.pos-1]) &&
+			(l.pos+2 == len(l.data) || isPDFWhitespace(l.data[l.pos+2])) {
+			l.pos += 2
+			return
+		}
+	}
+	l.pos = len(l.data)
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Capabilities describes what a parser can produce, so callers can tell
+// parsers apart without knowing their concrete types.
+type Capabilities struct {
+	// MIMETypes are the content types the parser accepts by default.
+	MIMETypes []string
+	// Tables reports whether tables are returned in ParsedDocument.Tables.
+	Tables bool
+	// Pages reports whether per-page content is returned in ParsedDocument.Pages.
+	Pages bool
+	// Scanned reports whether the parser may return pages that still need OCR.
+	Scanned bool
+	// Comments reports whether comments and revisions are extracted.
+	Comments bool
+}
+
+// Registration binds a parser to the extensions and MIME types it handles.
+// Empty Extensions or MIMETypes default to the parser's own Extensions and
+// Capabilities().MIMETypes. When several parsers match a file they are tried
+// from the highest Priority down, and a parser that returns an error falls
+// through to the next one.
+type Registration struct {
+	Parser     DocumentParser
+	Extensions []string
+	MIMETypes  []string
+	Priority   int
+}
+
+type registration struct {
+	Registration
+	order int
+}
+
+func (r *registration) matchesExtension(ext string) bool {
+	for _, candidate := range r.Extensions {
+		if ext == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *registration) matchesMIMEType(mimeType string) bool {
+	for _, candidate := range r.MIMETypes {
+		if mimeType == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// Register adds a parser to the manager. It may be called at any time,
+// including while documents are being parsed.
+func (m *ParserManager) Register(reg Registration) {
+	if reg.Extensions == nil {
+		reg.Extensions = reg.Parser.Extensions()
+	}
+	if reg.MIMETypes == nil {
+		reg.MIMETypes = reg.Parser.Capabilities().MIMETypes
+	}
+
+	entry := &registration{Registration: reg}
+	entry.Extensions = make([]string, len(reg.Extensions))
+	for i, ext := range reg.Extensions {
+		entry.Extensions[i] = normalizeExtension(ext)
+	}
+	entry.MIMETypes = make([]string, len(reg.MIMETypes))
+	for i, mimeType := range reg.MIMETypes {
+		entry.MIMETypes[i] = normalizeMIMEType(mimeType)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.order++
+	entry.order = m.order
+	m.registry = append(m.registry, entry)
+}
+
+// Unregister removes every registration of the parser with the given name
+// and reports whether any was found.
+func (m *ParserManager) Unregister(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.registry[:0]
+	for _, entry := range m.registry {
+		if entry.Parser.Name() != name {
+			kept = append(kept, entry)
+		}
+	}
+	removed := len(kept) < len(m.registry)
+	for i := len(kept); i < len(m.registry); i++ {
+		m.registry[i] = nil
+	}
+	m.registry = kept
+	return removed
+}
+
+// candidates returns the parsers that accept filePath by extension or
+// mimeType by content type, in the order they should be tried: highest
+// priority first, extension matches before content type matches, then
+// registration order.
+func (m *ParserManager) candidates(filePath, mimeType string) []*registration {
+	ext := normalizeExtension(filepath.Ext(filePath))
+	mimeType = normalizeMIMEType(mimeType)
+
+	m.mu.RLock()
+	var matched []*registration
+	byExtension := map[*registration]bool{}
+	for _, entry := range m.registry {
+		switch {
+		case ext != "" && entry.matchesExtension(ext):
+			byExtension[entry] = true
+			matched = append(matched, entry)
+		case mimeType != "" && entry.matchesMIMEType(mimeType):
+			matched = append(matched, entry)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if byExtension[a] != byExtension[b] {
+			return byExtension[a]
+		}
+		return a.order < b.order
+	})
+	return matched
+}
+
+// detectMIMEType guesses the content type of fileData for matching against
+// registered MIME types.
+func detectMIMEType(fileData []byte) string {
+	if len(fileData) == 0 {
+		return ""
+	}
+	return normalizeMIMEType(http.DetectContentType(fileData))
+}
+
+func normalizeMIMEType(mimeType string) string {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
@@ -0,0 +1,343 @@
+package parser
+
+import (
+	"bytes"
+	"contract-key-extractor/internal/model"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+var rtfSignature = []byte(`{\rtf`)
+
+var errNotRTF = errors.New("not an rtf document")
+
+var rtfBlankLines = regexp.MustCompile(`\n{3,}`)
+
+// rtfSkipDestinations are groups that hold formatting or metadata rather
+// than document text.
+var rtfSkipDestinations = map[string]bool{
+	"colortbl": true, "stylesheet": true, "info": true, "pict": true, "object": true,
+	"themedata": true, "colorschememapping": true, "datastore": true, "latentstyles": true,
+	"listtable": true, "listoverridetable": true, "rsidtbl": true, "generator": true,
+	"xmlnstbl": true, "mmathPr": true, "fldinst": true, "filetbl": true, "revtbl": true,
+	"pgdsctbl": true, "bkmkstart": true, "bkmkend": true,
+}
+
+type RTFParser struct{}
+
+func NewRTFParser() *RTFParser {
+	return &RTFParser{}
+}
+
+func (p *RTFParser) Parse(filePath string, fileData []byte) (*model.ParsedDocument, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(fileData, " \t\r\n"), rtfSignature) {
+		return nil, fmt.Errorf("failed to read rtf file: %w", errNotRTF)
+	}
+
+	return &model.ParsedDocument{
+		FileName:   filepath.Base(filePath),
+		FileType:   model.FileTypeRTF,
+		Content:    extractRTFText(fileData),
+		PageCount:  1,
+		IsScanned:  false,
+		ImagePaths: nil,
+	}, nil
+}
+
+func (p *RTFParser) Supports(filePath string) bool {
+	return hasExtension(filePath, p.Extensions())
+}
+
+func (p *RTFParser) Extensions() []string {
+	return []string{".rtf"}
+}
+
+func (p *RTFParser) Name() string {
+	return "rtf"
+}
+
+func (p *RTFParser) Capabilities() Capabilities {
+	return Capabilities{
+		MIMETypes: []string{"application/rtf", "text/rtf"},
+	}
+}
+
+type rtfState struct {
+	skip     bool
+	fontTbl  bool
+	font     int
+	uc       int
+	encoding encoding.Encoding
+}
+
+type rtfReader struct {
+	data    []byte
+	pos     int
+	state   rtfState
+	stack   []rtfState
+	ansi    encoding.Encoding
+	fonts   map[int]encoding.Encoding
+	pending []byte
+	ucSkip  int
+
+	out     strings.Builder
+	inTable bool
+	cell    strings.Builder
+	cells   []string
+}
+
+func extractRTFText(data []byte) string {
+	r := &rtfReader{
+		data:  data,
+		state: rtfState{uc: 1},
+		ansi:  charmap.Windows1252,
+		fonts: map[int]encoding.Encoding{},
+	}
+	r.run()
+
+	text := normalizeNewlines(r.out.String())
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(rtfBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+func (r *rtfReader) run() {
+	for r.pos < len(r.data) {
+		c := r.data[r.pos]
+		r.pos++
+		switch c {
+		case '{':
+			r.flush()
+			r.stack = append(r.stack, r.state)
+			if bytes.HasPrefix(r.data[r.pos:], []byte(`\*`)) {
+				r.state.skip = true
+			}
+		case '}':
+			r.flush()
+			if len(r.stack) > 0 {
+				r.state = r.stack[len(r.stack)-1]
+				r.stack = r.stack[:len(r.stack)-1]
+			}
+		case '\\':
+			r.control()
+		case '\r', '\n':
+		default:
+			r.byte(c)
+		}
+	}
+	r.flush()
+}
+
+func (r *rtfReader) byte(c byte) {
+	if r.ucSkip > 0 {
+		r.ucSkip--
+		return
+	}
+	if r.state.skip {
+		return
+	}
+	r.pending = append(r.pending, c)
+}
+
+func (r *rtfReader) control() {
+	if r.pos >= len(r.data) {
+		return
+	}
+
+	c := r.data[r.pos]
+	if !isASCIILetter(c) {
+		r.pos++
+		switch c {
+		case '\'':
+			if r.pos+2 <= len(r.data) {
+				if v, err := strconv.ParseUint(string(r.data[r.pos:r.pos+2]), 16, 8); err == nil {
+					r.byte(byte(v))
+				}
+				r.pos += 2
+			}
+		case '\\', '{', '}':
+			r.byte(c)
+		case '~':
+			r.text(" ")
+		case '_':
+			r.text("-")
+		case '*':
+			r.state.skip = true
+		case '\r', '\n':
+			r.text("\n")
+		}
+		return
+	}
+
+	start := r.pos
+	for r.pos < len(r.data) && isASCIILetter(r.data[r.pos]) {
+		r.pos++
+	}
+	word := string(r.data[start:r.pos])
+
+	param, hasParam := 0, false
+	numStart := r.pos
+	if r.pos < len(r.data) && r.data[r.pos] == '-' {
+		r.pos++
+	}
+	for r.pos < len(r.data) && r.data[r.pos] >= '0' && r.data[r.pos] <= '9' {
+		r.pos++
+	}
+	if r.pos > numStart {
+		param, _ = strconv.Atoi(string(r.data[numStart:r.pos]))
+		hasParam = true
+	}
+	if r.pos < len(r.data) && r.data[r.pos] == ' ' {
+		r.pos++
+	}
+
+	r.word(word, param, hasParam)
+}
+
+func (r *rtfReader) word(word string, param int, hasParam bool) {
+	if rtfSkipDestinations[word] {
+		r.state.skip = true
+		return
+	}
+
+	switch word {
+	case "fonttbl":
+		r.state.fontTbl = true
+		r.state.skip = true
+	case "f":
+		r.flush()
+		r.state.font = param
+		r.state.encoding = r.fonts[param]
+	case "fcharset":
+		if r.state.fontTbl {
+			if enc := rtfCharsetEncoding(param); enc != nil {
+				r.fonts[r.state.font] = enc
+			}
+		}
+	case "ansicpg":
+		if enc := rtfCodepageEncoding(param); enc != nil {
+			r.ansi = enc
+		}
+	case "uc":
+		if hasParam {
+			r.state.uc = param
+		}
+	case "u":
+		if r.state.skip {
+			return
+		}
+		if param < 0 {
+			param += 65536
+		}
+		r.text(string(rune(param)))
+		r.ucSkip = r.state.uc
+	case "par", "line", "sect", "page":
+		if r.inTable {
+			r.text(" ")
+		} else {
+			r.text("\n")
+		}
+	case "tab":
+		r.text("\t")
+	case "emdash":
+		r.text("—")
+	case "endash":
+		r.text("–")
+	case "lquote":
+		r.text("‘")
+	case "rquote":
+		r.text("’")
+	case "ldblquote":
+		r.text("“")
+	case "rdblquote":
+		r.text("”")
+	case "bullet":
+		r.text("•")
+	case "trowd":
+		r.flush()
+		r.inTable = true
+	case "cell":
+		r.flush()
+		r.cells = append(r.cells, strings.TrimSpace(r.cell.String()))
+		r.cell.Reset()
+	case "row":
+		r.flush()
+		if len(r.cells) > 0 {
+			r.out.WriteString("| " + strings.Join(r.cells, " | ") + " |\n")
+		}
+		r.cells = nil
+		r.cell.Reset()
+		r.inTable = false
+	}
+}
+
+// text writes already-decoded text, flushing any pending code page bytes
+// first so ordering is preserved.
+func (r *rtfReader) text(s string) {
+	if r.state.skip {
+		return
+	}
+	r.flush()
+	r.write(s)
+}
+
+func (r *rtfReader) flush() {
+	if len(r.pending) == 0 {
+		return
+	}
+	enc := r.state.encoding
+	if enc == nil {
+		enc = r.ansi
+	}
+	decoded, err := enc.NewDecoder().Bytes(r.pending)
+	if err != nil {
+		decoded = r.pending
+	}
+	r.pending = r.pending[:0]
+	r.write(string(decoded))
+}
+
+func (r *rtfReader) write(s string) {
+	if r.inTable {
+		r.cell.WriteString(s)
+	} else {
+		r.out.WriteString(s)
+	}
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func rtfCharsetEncoding(charset int) encoding.Encoding {
+	switch charset {
+	case 134:
+		return simplifiedchinese.GBK
+	case 136:
+		return traditionalchinese.Big5
+	}
+	return nil
+}
+
+func rtfCodepageEncoding(codepage int) encoding.Encoding {
+	switch codepage {
+	case 936:
+		return simplifiedchinese.GBK
+	case 950:
+		return traditionalchinese.Big5
+	case 1252:
+		return charmap.Windows1252
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExtractRTFText(t *testing.T) {
+	for _, tc := range []struct {
+		name, rtf, want string
+	}{
+		{"font charset", `{\rtf1\ansi\ansicpg1252{\fonttbl{\f0\fnil\fcharset134 SimSun;}{\f1\fswiss Arial;}}\f0 \'bc\'d7\'b7\'bd\'a3\'ba\f1 \'93Acme\'94\par}`, "甲方：“Acme”"},
+		{"document code page", `{\rtf1\ansi\ansicpg936 \'c6\'f5\'d4\'bc}`, "契约"},
+		{"unicode escapes", `{\rtf1\uc1\u20057?\u26041?\uc2\u-28647??x}`, "乙方這x"},
+		{"unicode in skipped group", `{\rtf1{\*\comment \u20057?}A}`, "A"},
+		{"metadata and table", `{\rtf1{\*\generator Word;}{\info{\author Bob}}\trowd\cellx100\cellx200 A\cell B\cell\row Z\par}`, "| A | B |\nZ"},
+		{"escaped symbols", `{\rtf1 a\{b\}\\c\~d\_e\ldblquote f\rdblquote\tab g}`, "a{b}\\c d-e“f”\tg"},
+	} {
+		if got := extractRTFText([]byte(tc.rtf)); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRTFParserRejectsOtherFiles(t *testing.T) {
+	if _, err := NewRTFParser().Parse("contract.rtf", []byte("PK\x03\x04")); !errors.Is(err, errNotRTF) {
+		t.Errorf("err = %v, want errNotRTF", err)
+	}
+}
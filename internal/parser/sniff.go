@@ -0,0 +1,157 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+)
+
+var (
+	pdfSignature  = []byte("%PDF-")
+	zipSignature  = []byte("PK\x03\x04")
+	jpegSignature = []byte{0xFF, 0xD8, 0xFF}
+	pngSignature  = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// pdfHeaderWindow is how far into the file the PDF header may start; readers
+// accept up to 1024 bytes of junk before it, and some generators prepend it.
+const pdfHeaderWindow = 1024
+
+// pdfHeaderLen is the length of a full header such as "%PDF-1.7".
+const pdfHeaderLen = 8
+
+// SniffExtension inspects the file content and returns the canonical
+// extension of the detected format (".pdf", ".docx", ".xlsx", ".odt", ".doc",
+// ".xls", ".rtf", ".html", ".jpg", ".png" or ".tif"), or "" if the content
+// is not recognised.
+func SniffExtension(fileData []byte) string {
+	return SniffReaderAt(bytes.NewReader(fileData), int64(len(fileData)))
+}
+
+// SniffReaderAt is SniffExtension for content read through r. Only the head
+// of the file and, for ZIP and compound files, their directories are read.
+func SniffReaderAt(r io.ReaderAt, size int64) string {
+	head := readHead(r, size, pdfHeaderWindow+pdfHeaderLen)
+	switch {
+	case isPDF(head):
+		return ".pdf"
+	case bytes.HasPrefix(head, zipSignature):
+		return sniffZip(r, size)
+	case bytes.HasPrefix(head, cfbSignature):
+		return sniffCFB(r)
+	case bytes.HasPrefix(head, jpegSignature):
+		return ".jpg"
+	case bytes.HasPrefix(head, pngSignature):
+		return ".png"
+	case isTIFF(head):
+		return ".tif"
+	case bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n"), rtfSignature):
+		return ".rtf"
+	case isHTML(head):
+		return ".html"
+	}
+	return ""
+}
+
+// readHead returns up to n bytes from the start of r.
+func readHead(r io.ReaderAt, size int64, n int) []byte {
+	head := make([]byte, min(size, int64(n)))
+	read, _ := r.ReadAt(head, 0)
+	return head[:read]
+}
+
+// isPDF reports whether head holds a "%PDF-n.n" header starting within the
+// first pdfHeaderWindow bytes.
+func isPDF(head []byte) bool {
+	for offset := 0; offset < pdfHeaderWindow; {
+		i := bytes.Index(head[offset:], pdfSignature)
+		if i < 0 || offset+i >= pdfHeaderWindow {
+			return false
+		}
+		offset += i
+		if version := head[offset+len(pdfSignature):]; len(version) >= 3 &&
+			isDigit(version[0]) && version[1] == '.' && isDigit(version[2]) {
+			return true
+		}
+		offset++
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHTML(fileData []byte) bool {
+	head := bytes.ToLower(bytes.TrimLeft(fileData[:min(len(fileData), 512)], " \t\r\n\xef\xbb\xbf"))
+	return bytes.HasPrefix(head, []byte("<!doctype html")) || bytes.HasPrefix(head, []byte("<html"))
+}
+
+func sniffZip(r io.ReaderAt, size int64) string {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return ""
+	}
+	for _, file := range reader.File {
+		switch {
+		case file.Name == "mimetype":
+			if rc, err := file.Open(); err == nil {
+				mimetype, _ := io.ReadAll(io.LimitReader(rc, 128))
+				rc.Close()
+				if string(mimetype) == "application/vnd.oasis.opendocument.text" {
+					return ".odt"
+				}
+			}
+		case strings.HasPrefix(file.Name, "word/"):
+			return ".docx"
+		case strings.HasPrefix(file.Name, "xl/"):
+			return ".xlsx"
+		}
+	}
+	return ""
+}
+
+func sniffCFB(r io.ReaderAt) string {
+	reader, err := mscfb.New(r)
+	if err != nil {
+		return ""
+	}
+	for entry, err := reader.Next(); err == nil; entry, err = reader.Next() {
+		if len(entry.Path) > 0 {
+			continue
+		}
+		switch entry.Name {
+		case "WordDocument":
+			return ".doc"
+		case "Workbook", "Book":
+			return ".xls"
+		}
+	}
+	return ""
+}
+
+// sameFormat reports whether two extensions name the same file format.
+func sameFormat(a, b string) bool {
+	canonical := func(ext string) string {
+		switch ext = strings.ToLower(ext); ext {
+		case ".jpeg":
+			return ".jpg"
+		case ".tiff":
+			return ".tif"
+		case ".htm":
+			return ".html"
+		}
+		return ext
+	}
+	return canonical(a) == canonical(b)
+}
+
+// contentPath returns a path whose extension reflects the sniffed content,
+// for selecting a parser when the declared extension is missing or wrong.
+func contentPath(filePath, sniffed string) string {
+	return strings.TrimSuffix(filePath, filepath.Ext(filePath)) + sniffed
+}
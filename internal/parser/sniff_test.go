@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSniffPDFHeader(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		want string
+	}{
+		{"plain", "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n", ".pdf"},
+		{"leading junk", strings.Repeat("x", 1000) + "%PDF-1.4\n", ".pdf"},
+		{"header past 1024 bytes", strings.Repeat("x", 1024) + "%PDF-1.4\n", ""},
+		{"signature in text", "notes about the %PDF- header format\n", ""},
+	} {
+		if got := SniffExtension([]byte(tc.data)); got != tc.want {
+			t.Errorf("%s: SniffExtension = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSameFormat(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{".htm", ".html", true},
+		{".JPEG", ".jpg", true},
+		{".tiff", ".tif", true},
+		{".doc", ".docx", false},
+	} {
+		if got := sameFormat(tc.a, tc.b); got != tc.want {
+			t.Errorf("sameFormat(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"contract-key-extractor/internal/model"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxPartSize caps how much is read from any one part of a container
+// format: a zip member of a DOCX or ODT file or a stream of a compound
+// file. A larger part is treated as damaged, so that a small compressed file
+// cannot expand into an unbounded allocation.
+const maxPartSize = 128 << 20
+
+var errPartTooLarge = errors.New("document part exceeds the size limit")
+
+// parserSource is the content being parsed. data is filled in the first
+// time a parser without ReaderAtParser support needs the whole file, and is
+// then shared by any fallback parsers.
+type parserSource struct {
+	reader io.ReaderAt
+	size   int64
+	data   []byte
+}
+
+func (s *parserSource) parseWith(filePath string, parser DocumentParser) (*model.ParsedDocument, error) {
+	if streaming, ok := parser.(ReaderAtParser); ok && s.data == nil {
+		return streaming.ParseReaderAt(filePath, s.reader, s.size)
+	}
+	if s.data == nil {
+		data, _, err := readReaderAt(s.reader, s.size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		s.data = data
+	}
+	return parser.Parse(filePath, s.data)
+}
+
+// readPart reads one part of a container, failing with errPartTooLarge
+// beyond maxPartSize.
+func readPart(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxPartSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxPartSize {
+		return nil, errPartTooLarge
+	}
+	return data, nil
+}
+
+// readReaderAt reads all size bytes of r into memory. The returned release
+// function does nothing and exists to match mapReaderAt.
+func readReaderAt(r io.ReaderAt, size int64) ([]byte, func(), error) {
+	data := make([]byte, size)
+	n, err := r.ReadAt(data, 0)
+	if err != nil && !(err == io.EOF && int64(n) == size) {
+		return nil, nil, err
+	}
+	return data, func() {}, nil
+}
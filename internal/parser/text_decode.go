@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// decodeText converts the bytes of a plain-text file to UTF-8. A BOM is
+// honoured; otherwise the data is taken as UTF-8 when valid and as GBK, the
+// usual encoding of legacy Chinese text files, when not.
+func decodeText(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return string(data[len(utf8BOM):])
+	case bytes.HasPrefix(data, utf16LEBOM):
+		text, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder().Bytes(data)
+		return string(text)
+	case bytes.HasPrefix(data, utf16BEBOM):
+		text, _ := unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder().Bytes(data)
+		return string(text)
+	case utf8.Valid(data):
+		return string(data)
+	}
+	if text, err := simplifiedchinese.GBK.NewDecoder().Bytes(data); err == nil {
+		return string(text)
+	}
+	return strings.ToValidUTF8(string(data), "�")
+}
+
+// decodeCharset converts data in the named charset (as found in MIME and
+// HTML declarations) to UTF-8, falling back to decodeText when the charset is
+// unknown or empty.
+func decodeCharset(data []byte, charset string) string {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii":
+		return decodeText(data)
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return decodeText(data)
+	}
+	text, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return decodeText(data)
+	}
+	return string(text)
+}
+
+// normalizeNewlines converts CRLF and CR line endings to LF.
+func normalizeNewlines(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+}
@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"contract-key-extractor/internal/model"
+	"path/filepath"
+	"strings"
+)
+
+// TextParser reads plain-text and Markdown files. Markdown is passed through
+// as-is; its markup is light enough for the extraction model to read.
+type TextParser struct{}
+
+func NewTextParser() *TextParser {
+	return &TextParser{}
+}
+
+func (p *TextParser) Parse(filePath string, fileData []byte) (*model.ParsedDocument, error) {
+	content := strings.TrimSpace(normalizeNewlines(decodeText(fileData)))
+
+	return &model.ParsedDocument{
+		FileName:   filepath.Base(filePath),
+		FileType:   model.FileTypeText,
+		Content:    content,
+		PageCount:  1,
+		IsScanned:  false,
+		ImagePaths: nil,
+	}, nil
+}
+
+func (p *TextParser) Supports(filePath string) bool {
+	return hasExtension(filePath, p.Extensions())
+}
+
+func (p *TextParser) Extensions() []string {
+	return []string{".txt", ".md", ".markdown"}
+}
+
+func (p *TextParser) Name() string {
+	return "text"
+}
+
+func (p *TextParser) Capabilities() Capabilities {
+	return Capabilities{
+		MIMETypes: []string{"text/plain", "text/markdown"},
+	}
+}
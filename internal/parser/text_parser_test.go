@@ -0,0 +1,33 @@
+package parser
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestTextParserEncodings(t *testing.T) {
+	const want = "合同编号：A-1\n甲方：测试公司"
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(want))
+	utf16, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(want))
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"utf-8", []byte(want)},
+		{"utf-8 with BOM and CRLF", append([]byte("\xEF\xBB\xBF"), "合同编号：A-1\r\n甲方：测试公司\r\n"...)},
+		{"utf-16 with BOM", utf16},
+		{"gbk", gbk},
+		{"old mac line endings", []byte("合同编号：A-1\r甲方：测试公司")},
+	} {
+		doc, err := NewTextParser().Parse("contract.txt", tc.data)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if doc.Content != want {
+			t.Errorf("%s: content = %q, want %q", tc.name, doc.Content, want)
+		}
+	}
+}
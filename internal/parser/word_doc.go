@@ -0,0 +1,269 @@
+package parser
+
+import (
+	"contract-key-extractor/internal/model"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+const (
+	docWordIdent   = 0xA5EC
+	docNFibWord97  = 0xC1
+	docFibMinSize  = 0x1AA
+	docOffsetFlags = 0x0A
+	docOffsetLid   = 0x06
+	docOffsetCcp   = 0x4C
+	docOffsetFcClx = 0x1A2
+	docOffsetFcMin = 0x18
+
+	docFlagComplex   = 0x0004
+	docFlagEncrypted = 0x0100
+	docFlagTable1    = 0x0200
+)
+
+var errDocEncrypted = errors.New("encrypted .doc is not supported")
+
+type docPiece struct {
+	cpStart    int
+	cpEnd      int
+	fc         int
+	compressed bool
+}
+
+func (p *WordParser) parseDoc(filePath string, r io.ReaderAt, size int64) (*model.ParsedDocument, error) {
+	cfb, err := openCFB(r, size, "WordDocument", "0Table", "1Table")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open doc file: %w", err)
+	}
+
+	wordDoc, ok := cfb.streams["WordDocument"]
+	if !ok {
+		return nil, fmt.Errorf("WordDocument stream not found in doc")
+	}
+
+	text, err := extractDocText(wordDoc, cfb.streams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract doc text: %w", err)
+	}
+
+	pageCount := cfb.pageCount()
+	if pageCount <= 0 {
+		pageCount = 1
+	}
+
+	return &model.ParsedDocument{
+		FileName:   filepath.Base(filePath),
+		FileType:   model.FileTypeWord,
+		Content:    text,
+		PageCount:  pageCount,
+		IsScanned:  false,
+		ImagePaths: nil,
+	}, nil
+}
+
+func extractDocText(wordDoc []byte, streams map[string][]byte) (string, error) {
+	if len(wordDoc) < docOffsetFcMin+8 || binary.LittleEndian.Uint16(wordDoc) != docWordIdent {
+		return "", fmt.Errorf("invalid WordDocument header")
+	}
+
+	nFib := binary.LittleEndian.Uint16(wordDoc[2:])
+	flags := binary.LittleEndian.Uint16(wordDoc[docOffsetFlags:])
+	if flags&docFlagEncrypted != 0 {
+		return "", errDocEncrypted
+	}
+
+	if nFib < docNFibWord97 {
+		// Word 6/95 stores text in the ANSI codepage of the document's
+		// language.
+		ansi := docCodepage(binary.LittleEndian.Uint16(wordDoc[docOffsetLid:]))
+		if flags&docFlagComplex != 0 {
+			return "", fmt.Errorf("fast-saved Word 6/95 documents are not supported")
+		}
+		fcMin := int(binary.LittleEndian.Uint32(wordDoc[docOffsetFcMin:]))
+		fcMac := int(binary.LittleEndian.Uint32(wordDoc[docOffsetFcMin+4:]))
+		if fcMin < 0 || fcMac > len(wordDoc) || fcMin > fcMac {
+			return "", fmt.Errorf("invalid text range in Word 6/95 document")
+		}
+		text, err := ansi.NewDecoder().Bytes(wordDoc[fcMin:fcMac])
+		if err != nil {
+			return "", fmt.Errorf("failed to decode text: %w", err)
+		}
+		return cleanDocText(string(text)), nil
+	}
+
+	if len(wordDoc) < docFibMinSize {
+		return "", fmt.Errorf("WordDocument FIB is truncated")
+	}
+
+	tableName := "0Table"
+	if flags&docFlagTable1 != 0 {
+		tableName = "1Table"
+	}
+	table, ok := streams[tableName]
+	if !ok {
+		return "", fmt.Errorf("%s stream not found", tableName)
+	}
+
+	fcClx := int(binary.LittleEndian.Uint32(wordDoc[docOffsetFcClx:]))
+	lcbClx := int(binary.LittleEndian.Uint32(wordDoc[docOffsetFcClx+4:]))
+	if fcClx < 0 || lcbClx <= 0 || fcClx+lcbClx > len(table) {
+		return "", fmt.Errorf("invalid piece table location")
+	}
+
+	pieces, err := parseDocPieces(table[fcClx : fcClx+lcbClx])
+	if err != nil {
+		return "", err
+	}
+
+	ccpText := int(binary.LittleEndian.Uint32(wordDoc[docOffsetCcp:]))
+
+	var builder strings.Builder
+	for _, piece := range pieces {
+		if piece.cpStart >= ccpText {
+			break
+		}
+		end := piece.cpEnd
+		if end > ccpText {
+			end = ccpText
+		}
+		builder.WriteString(readDocPiece(wordDoc, piece, end-piece.cpStart))
+	}
+
+	return cleanDocText(builder.String()), nil
+}
+
+func parseDocPieces(clx []byte) ([]docPiece, error) {
+	pos := 0
+	for pos < len(clx) && clx[pos] == 0x01 {
+		if pos+3 > len(clx) {
+			return nil, fmt.Errorf("truncated Prc in piece table")
+		}
+		pos += 3 + int(binary.LittleEndian.Uint16(clx[pos+1:]))
+	}
+	if pos+5 > len(clx) || clx[pos] != 0x02 {
+		return nil, fmt.Errorf("Pcdt not found in piece table")
+	}
+
+	lcb := int(binary.LittleEndian.Uint32(clx[pos+1:]))
+	plc := clx[pos+5:]
+	if lcb > len(plc) || lcb < 4 {
+		return nil, fmt.Errorf("truncated PlcPcd")
+	}
+	plc = plc[:lcb]
+
+	n := (lcb - 4) / 12
+	pieces := make([]docPiece, 0, n)
+	for i := 0; i < n; i++ {
+		cpStart := int(binary.LittleEndian.Uint32(plc[i*4:]))
+		cpEnd := int(binary.LittleEndian.Uint32(plc[(i+1)*4:]))
+		pcd := plc[(n+1)*4+i*8:]
+		fcValue := binary.LittleEndian.Uint32(pcd[2:])
+
+		piece := docPiece{cpStart: cpStart, cpEnd: cpEnd}
+		if fcValue&0x40000000 != 0 {
+			piece.compressed = true
+			piece.fc = int(fcValue&0x3FFFFFFF) / 2
+		} else {
+			piece.fc = int(fcValue)
+		}
+		pieces = append(pieces, piece)
+	}
+
+	return pieces, nil
+}
+
+// readDocPiece decodes a piece of Word 97+ text. Compressed pieces are
+// always cp1252, whatever the document's language (MS-DOC 2.4.1); text in
+// other scripts is stored in uncompressed UTF-16 pieces.
+func readDocPiece(wordDoc []byte, piece docPiece, count int) string {
+	if count <= 0 || piece.fc < 0 {
+		return ""
+	}
+
+	if piece.compressed {
+		end := piece.fc + count
+		if end > len(wordDoc) {
+			end = len(wordDoc)
+		}
+		if piece.fc >= end {
+			return ""
+		}
+		text, _ := charmap.Windows1252.NewDecoder().Bytes(wordDoc[piece.fc:end])
+		return string(text)
+	}
+
+	end := piece.fc + count*2
+	if end > len(wordDoc) {
+		end = len(wordDoc)
+	}
+	units := make([]uint16, 0, count)
+	for i := piece.fc; i+1 < end; i += 2 {
+		units = append(units, binary.LittleEndian.Uint16(wordDoc[i:]))
+	}
+	return string(utf16.Decode(units))
+}
+
+func docCodepage(lid uint16) encoding.Encoding {
+	switch lid {
+	case 0x0804, 0x1004:
+		return simplifiedchinese.GBK
+	case 0x0404, 0x0C04, 0x1404:
+		return traditionalchinese.Big5
+	}
+	return charmap.Windows1252
+}
+
+func cleanDocText(text string) string {
+	var builder strings.Builder
+	var fieldDepth []bool
+
+	for _, r := range text {
+		switch r {
+		case 0x13:
+			fieldDepth = append(fieldDepth, true)
+			continue
+		case 0x14:
+			if len(fieldDepth) > 0 {
+				fieldDepth[len(fieldDepth)-1] = false
+			}
+			continue
+		case 0x15:
+			if len(fieldDepth) > 0 {
+				fieldDepth = fieldDepth[:len(fieldDepth)-1]
+			}
+			continue
+		}
+
+		if len(fieldDepth) > 0 && fieldDepth[len(fieldDepth)-1] {
+			continue
+		}
+
+		switch r {
+		case '\r', 0x0B, 0x0C:
+			builder.WriteByte('\n')
+		case 0x07:
+			builder.WriteByte('\t')
+		case 0x1E:
+			builder.WriteByte('-')
+		case 0xA0:
+			builder.WriteByte(' ')
+		case 0x01, 0x02, 0x05, 0x08, 0x1F:
+		default:
+			if r >= 0x20 || r == '\t' || r == '\n' {
+				builder.WriteRune(r)
+			}
+		}
+	}
+
+	return strings.TrimSpace(builder.String())
+}
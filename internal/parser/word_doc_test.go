@@ -0,0 +1,14 @@
+package parser
+
+import "testing"
+
+// Compressed pieces are cp1252 even in documents whose language would
+// suggest GBK: 0x93/0x94 are curly quotes, not the lead byte of a Chinese
+// character.
+func TestReadDocPieceCompressedIsCP1252(t *testing.T) {
+	wordDoc := []byte("\x00\x00\x93ok\x94")
+	piece := docPiece{cpStart: 0, cpEnd: 4, fc: 2, compressed: true}
+	if got := readDocPiece(wordDoc, piece, 4); got != "“ok”" {
+		t.Errorf("readDocPiece = %q, want %q", got, "“ok”")
+	}
+}
@@ -0,0 +1,342 @@
+package parser
+
+import (
+	"contract-key-extractor/internal/model"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type RevisionMode string
+
+const (
+	RevisionModeAccepted RevisionMode = "accepted"
+	RevisionModeOriginal RevisionMode = "original"
+)
+
+type docxWalker struct {
+	numbering  *docxNumbering
+	mode       RevisionMode
+	revisions  []model.DocumentRevision
+	anchors    map[string]*strings.Builder
+	anchorText map[string]string
+}
+
+func newDocxWalker(numbering *docxNumbering, mode RevisionMode) *docxWalker {
+	return &docxWalker{
+		numbering:  numbering,
+		mode:       mode,
+		anchors:    map[string]*strings.Builder{},
+		anchorText: map[string]string{},
+	}
+}
+
+// docxLine is a line of a part: a paragraph, or a table row with its cells.
+type docxLine struct {
+	text  string
+	cells []string
+}
+
+func (w *docxWalker) partLines(data []byte) ([]docxLine, error) {
+	root, err := parseXMLTree(data)
+	if err != nil {
+		return nil, err
+	}
+	return w.blocks(root), nil
+}
+
+func (w *docxWalker) partText(data []byte) (string, error) {
+	lines, err := w.partLines(data)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(lineTexts(lines), "\n"), nil
+}
+
+func lineTexts(lines []docxLine) []string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.text
+	}
+	return texts
+}
+
+func (w *docxWalker) uniquePartTexts(parts map[string][]byte, names []string) []string {
+	var texts []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		text, err := w.partText(parts[name])
+		text = strings.TrimSpace(text)
+		if err != nil || text == "" || seen[text] {
+			continue
+		}
+		seen[text] = true
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+func (w *docxWalker) notes(data []byte, element, marker string) []string {
+	if data == nil {
+		return nil
+	}
+	root, err := parseXMLTree(data)
+	if err != nil {
+		return nil
+	}
+
+	var texts []string
+	for _, container := range root.children {
+		for _, note := range container.children {
+			if note.name != element {
+				continue
+			}
+			if t := note.attr("type"); t == "separator" || t == "continuationSeparator" || t == "continuationNotice" {
+				continue
+			}
+			text := strings.TrimSpace(strings.Join(lineTexts(w.blocks(note)), " "))
+			if text == "" {
+				continue
+			}
+			texts = append(texts, fmt.Sprintf("[%s%s] %s", marker, note.attr("id"), text))
+		}
+	}
+	return texts
+}
+
+func (w *docxWalker) blocks(node *xmlNode) []docxLine {
+	var lines []docxLine
+	for _, c := range node.children {
+		switch c.name {
+		case "p":
+			if text, ok := w.paragraph(c); ok {
+				lines = append(lines, docxLine{text: text})
+			}
+		case "tbl":
+			lines = append(lines, docxLine{})
+			lines = append(lines, w.table(c)...)
+			lines = append(lines, docxLine{})
+		case "", "sectPr", "pPr", "rPr", "tblPr", "tblGrid", "trPr", "tcPr", "sdtPr", "sdtEndPr":
+		default:
+			lines = append(lines, w.blocks(c)...)
+		}
+	}
+	return lines
+}
+
+// paragraph returns the text of p. ok is false for a paragraph that a
+// tracked change removes in the walker's mode: its mark and all of its
+// content are deleted (or, for the original text, inserted). Such a
+// paragraph takes no list number.
+func (w *docxWalker) paragraph(p *xmlNode) (text string, ok bool) {
+	var builder strings.Builder
+	w.inline(p, &builder)
+
+	text = builder.String()
+	if w.removedBy(p.child("pPr").child("rPr")) != nil && strings.TrimSpace(text) == "" {
+		return "", false
+	}
+	if label := w.paragraphLabel(p); label != "" {
+		text = label + " " + text
+	}
+	return text, true
+}
+
+// removedBy returns the tracked change among props that removes the element
+// they belong to in the walker's mode, or nil.
+func (w *docxWalker) removedBy(props *xmlNode) *xmlNode {
+	if w.mode == RevisionModeOriginal {
+		return props.child("ins")
+	}
+	return props.child("del")
+}
+
+func (w *docxWalker) paragraphLabel(p *xmlNode) string {
+	pPr := p.child("pPr")
+	if pPr == nil {
+		return ""
+	}
+
+	if numPr := pPr.child("numPr"); numPr != nil && numPr.child("numId") != nil {
+		ilvl, _ := strconv.Atoi(numPr.child("ilvl").attr("val"))
+		return w.numbering.label(numPr.child("numId").attr("val"), ilvl)
+	}
+
+	numID, ilvl := w.numbering.resolveStyle(pPr.child("pStyle").attr("val"))
+	return w.numbering.label(numID, ilvl)
+}
+
+func (w *docxWalker) write(builder *strings.Builder, text string) {
+	builder.WriteString(text)
+	for _, anchor := range w.anchors {
+		anchor.WriteString(text)
+	}
+}
+
+func (w *docxWalker) inline(node *xmlNode, builder *strings.Builder) {
+	for _, c := range node.children {
+		switch c.name {
+		case "t", "delText":
+			w.write(builder, c.innerText())
+		case "tab", "ptab":
+			w.write(builder, "\t")
+		case "br", "cr":
+			w.write(builder, "\n")
+		case "noBreakHyphen":
+			w.write(builder, "-")
+		case "sym":
+			if v, err := strconv.ParseUint(c.attr("char"), 16, 32); err == nil && v < 0xF000 {
+				w.write(builder, string(rune(v)))
+			}
+		case "ins", "moveTo":
+			w.recordRevision("insert", c)
+			if w.mode != RevisionModeOriginal {
+				w.inline(c, builder)
+			}
+		case "del", "moveFrom":
+			w.recordRevision("delete", c)
+			if w.mode == RevisionModeOriginal {
+				w.inline(c, builder)
+			}
+		case "commentRangeStart":
+			w.anchors[c.attr("id")] = &strings.Builder{}
+		case "commentRangeEnd":
+			if anchor, ok := w.anchors[c.attr("id")]; ok {
+				w.anchorText[c.attr("id")] = strings.TrimSpace(anchor.String())
+				delete(w.anchors, c.attr("id"))
+			}
+		case "footnoteReference":
+			builder.WriteString(fmt.Sprintf("[^%s]", c.attr("id")))
+		case "endnoteReference":
+			builder.WriteString(fmt.Sprintf("[^e%s]", c.attr("id")))
+		case "txbxContent":
+			for _, line := range lineTexts(w.blocks(c)) {
+				if line = strings.TrimSpace(line); line != "" {
+					builder.WriteString("\n")
+					builder.WriteString(line)
+				}
+			}
+		case "p":
+			if text, ok := w.paragraph(c); ok {
+				builder.WriteString(text)
+			}
+		case "", "pPr", "rPr", "instrText", "delInstrText", "Fallback":
+		default:
+			w.inline(c, builder)
+		}
+	}
+}
+
+func (w *docxWalker) recordRevision(kind string, node *xmlNode) {
+	w.addRevision(kind, node, revisionText(node))
+}
+
+// addRevision records a tracked change made by mark to text.
+func (w *docxWalker) addRevision(kind string, mark *xmlNode, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	w.revisions = append(w.revisions, model.DocumentRevision{
+		Type:   kind,
+		Author: mark.attr("author"),
+		Date:   mark.attr("date"),
+		Text:   text,
+	})
+}
+
+func revisionText(node *xmlNode) string {
+	var builder strings.Builder
+	for _, c := range node.children {
+		switch c.name {
+		case "t", "delText":
+			builder.WriteString(c.innerText())
+		case "", "instrText", "delInstrText":
+		default:
+			builder.WriteString(revisionText(c))
+		}
+	}
+	return builder.String()
+}
+
+func (w *docxWalker) comments(data []byte) []model.DocumentComment {
+	if data == nil {
+		return nil
+	}
+	root, err := parseXMLTree(data)
+	if err != nil {
+		return nil
+	}
+
+	var comments []model.DocumentComment
+	for _, c := range root.child("comments").children {
+		if c.name != "comment" {
+			continue
+		}
+		var parts []string
+		for _, line := range lineTexts(w.blocks(c)) {
+			if line = strings.TrimSpace(line); line != "" {
+				parts = append(parts, line)
+			}
+		}
+		id := c.attr("id")
+		comments = append(comments, model.DocumentComment{
+			ID:         id,
+			Author:     c.attr("author"),
+			Date:       c.attr("date"),
+			Text:       strings.Join(parts, "\n"),
+			AnchorText: w.anchorText[id],
+		})
+	}
+	return comments
+}
+
+// A table row is rendered as its cells between pipes.
+const (
+	docxRowPrefix = "| "
+	docxCellSep   = " | "
+	docxRowSuffix = " |"
+)
+
+func (w *docxWalker) table(tbl *xmlNode) []docxLine {
+	var rows []docxLine
+	for _, tr := range tbl.children {
+		if tr.name != "tr" {
+			continue
+		}
+		if mark := w.removedBy(tr.child("trPr")); mark != nil {
+			kind := "delete"
+			if mark.name == "ins" {
+				kind = "insert"
+			}
+			w.addRevision(kind, mark, revisionText(tr))
+			continue
+		}
+
+		var cells []string
+		for _, tc := range tr.children {
+			if tc.name != "tc" {
+				continue
+			}
+
+			var parts []string
+			for _, line := range lineTexts(w.blocks(tc)) {
+				if line = strings.TrimSpace(line); line != "" {
+					parts = append(parts, line)
+				}
+			}
+			cells = append(cells, strings.Join(parts, " "))
+
+			span, _ := strconv.Atoi(tc.child("tcPr").child("gridSpan").attr("val"))
+			for i := 1; i < span; i++ {
+				cells = append(cells, "")
+			}
+		}
+
+		rows = append(rows, docxLine{
+			text:  docxRowPrefix + strings.Join(cells, docxCellSep) + docxRowSuffix,
+			cells: cells,
+		})
+	}
+	return rows
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+const testNumberingXML = `<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:abstractNum w:abstractNumId="0"><w:lvl w:ilvl="0"><w:start w:val="1"/><w:numFmt w:val="chineseCounting"/><w:lvlText w:val="第%1条"/></w:lvl></w:abstractNum>
+<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>
+</w:numbering>`
+
+// testTrackedChangesXML has a numbered paragraph and a table row that were
+// deleted, and a numbered paragraph and a table row that were inserted, all
+// with tracked changes.
+const testTrackedChangesXML = `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>定义</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr><w:rPr><w:del w:id="1" w:author="张三"/></w:rPr></w:pPr><w:del w:id="2" w:author="张三"><w:r><w:delText>旧条款</w:delText></w:r></w:del></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr><w:rPr><w:ins w:id="3" w:author="李四"/></w:rPr></w:pPr><w:ins w:id="4" w:author="李四"><w:r><w:t>新条款</w:t></w:r></w:ins></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>付款</w:t></w:r></w:p>
+<w:tbl>
+<w:tr><w:trPr><w:del w:id="5" w:author="张三"/></w:trPr><w:tc><w:p><w:del w:id="6" w:author="张三"><w:r><w:delText>removed</w:delText></w:r></w:del></w:p></w:tc></w:tr>
+<w:tr><w:trPr><w:ins w:id="7" w:author="李四"/></w:trPr><w:tc><w:p><w:ins w:id="8" w:author="李四"><w:r><w:t>added</w:t></w:r></w:ins></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>kept</w:t></w:r></w:p></w:tc></w:tr>
+</w:tbl>
+</w:body></w:document>`
+
+func TestDocxTrackedParagraphsAndRows(t *testing.T) {
+	for _, tc := range []struct {
+		mode RevisionMode
+		want string
+	}{
+		{RevisionModeAccepted, "第一条 定义\n第二条 新条款\n第三条 付款\n\n| added |\n| kept |"},
+		{RevisionModeOriginal, "第一条 定义\n第二条 旧条款\n第三条 付款\n\n| removed |\n| kept |"},
+	} {
+		walker := newDocxWalker(parseDocxNumbering([]byte(testNumberingXML), nil), tc.mode)
+		text, err := walker.partText([]byte(testTrackedChangesXML))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text = strings.TrimSpace(text); text != tc.want {
+			t.Errorf("%s mode:\ngot  %q\nwant %q", tc.mode, text, tc.want)
+		}
+		if len(walker.revisions) != 4 {
+			t.Errorf("%s mode: %d revisions recorded, want 4: %+v", tc.mode, len(walker.revisions), walker.revisions)
+		}
+	}
+}
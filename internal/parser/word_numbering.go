@@ -0,0 +1,325 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const docxMaxLevels = 9
+
+type docxLevel struct {
+	start   int
+	numFmt  string
+	lvlText string
+	isLegal bool
+}
+
+type docxNum struct {
+	abstractID string
+	overrides  map[int]int
+}
+
+type docxStyle struct {
+	numID   string
+	ilvl    int
+	hasIlvl bool
+	basedOn string
+}
+
+type docxCounter struct {
+	values [docxMaxLevels]int
+	set    [docxMaxLevels]bool
+}
+
+type docxNumbering struct {
+	abstracts map[string][]docxLevel
+	nums      map[string]docxNum
+	styles    map[string]docxStyle
+	counters  map[string]*docxCounter
+	started   map[string]bool
+}
+
+func parseDocxNumbering(numberingXML, stylesXML []byte) *docxNumbering {
+	n := &docxNumbering{
+		abstracts: map[string][]docxLevel{},
+		nums:      map[string]docxNum{},
+		styles:    map[string]docxStyle{},
+		counters:  map[string]*docxCounter{},
+		started:   map[string]bool{},
+	}
+
+	if root, err := parseXMLTree(numberingXML); err == nil {
+		if numbering := root.child("numbering"); numbering != nil {
+			for _, c := range numbering.children {
+				switch c.name {
+				case "abstractNum":
+					levels := make([]docxLevel, docxMaxLevels)
+					for i := range levels {
+						levels[i] = docxLevel{start: 1, numFmt: "decimal"}
+					}
+					for _, lvl := range c.children {
+						if lvl.name != "lvl" {
+							continue
+						}
+						ilvl, err := strconv.Atoi(lvl.attr("ilvl"))
+						if err != nil || ilvl < 0 || ilvl >= docxMaxLevels {
+							continue
+						}
+						if start := lvl.child("start"); start != nil {
+							if v, err := strconv.Atoi(start.attr("val")); err == nil {
+								levels[ilvl].start = v
+							}
+						}
+						if f := lvl.child("numFmt"); f != nil {
+							levels[ilvl].numFmt = f.attr("val")
+						}
+						if t := lvl.child("lvlText"); t != nil {
+							levels[ilvl].lvlText = t.attr("val")
+						}
+						levels[ilvl].isLegal = lvl.child("isLgl") != nil
+					}
+					n.abstracts[c.attr("abstractNumId")] = levels
+				case "num":
+					num := docxNum{
+						abstractID: c.child("abstractNumId").attr("val"),
+						overrides:  map[int]int{},
+					}
+					for _, o := range c.children {
+						if o.name != "lvlOverride" || o.child("startOverride") == nil {
+							continue
+						}
+						ilvl, err1 := strconv.Atoi(o.attr("ilvl"))
+						start, err2 := strconv.Atoi(o.child("startOverride").attr("val"))
+						if err1 == nil && err2 == nil {
+							num.overrides[ilvl] = start
+						}
+					}
+					n.nums[c.attr("numId")] = num
+				}
+			}
+		}
+	}
+
+	if root, err := parseXMLTree(stylesXML); err == nil {
+		if styles := root.child("styles"); styles != nil {
+			for _, s := range styles.children {
+				if s.name != "style" {
+					continue
+				}
+				style := docxStyle{basedOn: s.child("basedOn").attr("val")}
+				if pPr := s.child("pPr"); pPr != nil {
+					if numPr := pPr.child("numPr"); numPr != nil {
+						style.numID = numPr.child("numId").attr("val")
+						if ilvl := numPr.child("ilvl"); ilvl != nil {
+							style.ilvl, _ = strconv.Atoi(ilvl.attr("val"))
+							style.hasIlvl = true
+						}
+					}
+				}
+				n.styles[s.attr("styleId")] = style
+			}
+		}
+	}
+
+	return n
+}
+
+func (n *docxNumbering) resolveStyle(styleID string) (string, int) {
+	ilvl, hasIlvl := 0, false
+	for depth := 0; styleID != "" && depth < 16; depth++ {
+		style, ok := n.styles[styleID]
+		if !ok {
+			break
+		}
+		if style.hasIlvl && !hasIlvl {
+			ilvl, hasIlvl = style.ilvl, true
+		}
+		if style.numID != "" {
+			return style.numID, ilvl
+		}
+		styleID = style.basedOn
+	}
+	return "", 0
+}
+
+func (n *docxNumbering) label(numID string, ilvl int) string {
+	if numID == "" || numID == "0" || ilvl < 0 || ilvl >= docxMaxLevels {
+		return ""
+	}
+	num, ok := n.nums[numID]
+	if !ok {
+		return ""
+	}
+	levels, ok := n.abstracts[num.abstractID]
+	if !ok {
+		return ""
+	}
+
+	counter, ok := n.counters[num.abstractID]
+	if !ok {
+		counter = &docxCounter{}
+		n.counters[num.abstractID] = counter
+	}
+	if !n.started[numID] {
+		n.started[numID] = true
+		for lvl, start := range num.overrides {
+			if lvl >= 0 && lvl < docxMaxLevels {
+				counter.values[lvl] = start - 1
+				counter.set[lvl] = true
+			}
+		}
+	}
+
+	if counter.set[ilvl] {
+		counter.values[ilvl]++
+	} else {
+		counter.values[ilvl] = levels[ilvl].start
+		counter.set[ilvl] = true
+	}
+	for deeper := ilvl + 1; deeper < docxMaxLevels; deeper++ {
+		counter.set[deeper] = false
+	}
+
+	level := levels[ilvl]
+	if level.numFmt == "bullet" {
+		return "•"
+	}
+	if level.numFmt == "none" {
+		return strings.TrimSpace(stripLevelPlaceholders(level.lvlText))
+	}
+
+	text := level.lvlText
+	for k := 0; k <= ilvl; k++ {
+		placeholder := fmt.Sprintf("%%%d", k+1)
+		if !strings.Contains(text, placeholder) {
+			continue
+		}
+		value := levels[k].start
+		if counter.set[k] {
+			value = counter.values[k]
+		}
+		numFmt := levels[k].numFmt
+		if level.isLegal {
+			numFmt = "decimal"
+		}
+		text = strings.ReplaceAll(text, placeholder, formatListNumber(value, numFmt))
+	}
+	return strings.TrimSpace(text)
+}
+
+func stripLevelPlaceholders(text string) string {
+	for k := 1; k <= docxMaxLevels; k++ {
+		text = strings.ReplaceAll(text, fmt.Sprintf("%%%d", k), "")
+	}
+	return text
+}
+
+func formatListNumber(value int, numFmt string) string {
+	switch numFmt {
+	case "upperRoman":
+		return romanNumber(value)
+	case "lowerRoman":
+		return strings.ToLower(romanNumber(value))
+	case "upperLetter":
+		return letterNumber(value)
+	case "lowerLetter":
+		return strings.ToLower(letterNumber(value))
+	case "decimalZero":
+		return fmt.Sprintf("%02d", value)
+	case "decimalFullWidth", "decimalFullWidth2":
+		return fullWidthNumber(value)
+	case "decimalEnclosedCircle", "decimalEnclosedCircleChinese":
+		if value >= 1 && value <= 20 {
+			return string(rune('①' + value - 1))
+		}
+	case "chineseCounting", "chineseCountingThousand", "ideographDigital", "taiwaneseCounting", "taiwaneseCountingThousand", "japaneseCounting":
+		return chineseNumber(value, []rune("零一二三四五六七八九"), []rune("十百千"))
+	case "chineseLegalSimplified":
+		return chineseNumber(value, []rune("零壹贰叁肆伍陆柒捌玖"), []rune("拾佰仟"))
+	case "ideographTraditional":
+		stems := []rune("甲乙丙丁戊己庚辛壬癸")
+		if value >= 1 && value <= len(stems) {
+			return string(stems[value-1])
+		}
+	case "ideographZodiac":
+		branches := []rune("子丑寅卯辰巳午未申酉戌亥")
+		if value >= 1 && value <= len(branches) {
+			return string(branches[value-1])
+		}
+	}
+	return strconv.Itoa(value)
+}
+
+func romanNumber(value int) string {
+	if value <= 0 || value >= 4000 {
+		return strconv.Itoa(value)
+	}
+	numerals := []struct {
+		value  int
+		symbol string
+	}{
+		{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+		{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+	}
+	var builder strings.Builder
+	for _, n := range numerals {
+		for value >= n.value {
+			builder.WriteString(n.symbol)
+			value -= n.value
+		}
+	}
+	return builder.String()
+}
+
+func letterNumber(value int) string {
+	if value <= 0 {
+		return strconv.Itoa(value)
+	}
+	letter := string(rune('A' + (value-1)%26))
+	return strings.Repeat(letter, (value-1)/26+1)
+}
+
+func fullWidthNumber(value int) string {
+	var builder strings.Builder
+	for _, r := range strconv.Itoa(value) {
+		if r >= '0' && r <= '9' {
+			builder.WriteRune('０' + r - '0')
+		} else {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+func chineseNumber(value int, digits, units []rune) string {
+	if value <= 0 || value >= 10000 {
+		return strconv.Itoa(value)
+	}
+
+	var builder strings.Builder
+	places := []int{1000, 100, 10, 1}
+	zero := false
+	started := false
+	for i, place := range places {
+		d := value / place % 10
+		if d == 0 {
+			if started {
+				zero = true
+			}
+			continue
+		}
+		if zero {
+			builder.WriteRune(digits[0])
+			zero = false
+		}
+		if !(place == 10 && d == 1 && !started && units[0] == '十') {
+			builder.WriteRune(digits[d])
+		}
+		if i < 3 {
+			builder.WriteRune(units[2-i])
+		}
+		started = true
+	}
+	return builder.String()
+}
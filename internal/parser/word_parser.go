@@ -4,103 +4,175 @@ import (
 	"archive/zip"
 	"bytes"
 	"contract-key-extractor/internal/model"
-	"encoding/xml"
 	"fmt"
 	"io"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-type WordParser struct{}
+type WordParser struct {
+	revisionMode RevisionMode
+}
 
 func NewWordParser() *WordParser {
-	return &WordParser{}
+	return &WordParser{revisionMode: RevisionModeAccepted}
+}
+
+func NewWordParserWithRevisionMode(mode RevisionMode) *WordParser {
+	if mode != RevisionModeOriginal {
+		mode = RevisionModeAccepted
+	}
+	return &WordParser{revisionMode: mode}
 }
 
 func (p *WordParser) Parse(filePath string, fileData []byte) (*model.ParsedDocument, error) {
+	return p.ParseReaderAt(filePath, bytes.NewReader(fileData), int64(len(fileData)))
+}
+
+// ParseReaderAt reads a .docx or .doc in place, loading only the parts it
+// needs.
+func (p *WordParser) ParseReaderAt(filePath string, r io.ReaderAt, size int64) (*model.ParsedDocument, error) {
+	head := readHead(r, size, len(cfbSignature))
 	ext := strings.ToLower(filepath.Ext(filePath))
 
-	if ext == ".docx" {
-		return p.parseDocx(filePath, fileData)
+	switch {
+	case bytes.HasPrefix(head, zipSignature):
+		return p.parseDocx(filePath, r, size)
+	case bytes.HasPrefix(head, cfbSignature):
+		return p.parseDoc(filePath, r, size)
+	case ext == ".docx":
+		return p.parseDocx(filePath, r, size)
+	case ext == ".doc":
+		return p.parseDoc(filePath, r, size)
 	}
 
 	return nil, fmt.Errorf("unsupported word format: %s", ext)
 }
 
-func (p *WordParser) parseDocx(filePath string, fileData []byte) (*model.ParsedDocument, error) {
-	reader, err := zip.NewReader(bytes.NewReader(fileData), int64(len(fileData)))
+func (p *WordParser) parseDocx(filePath string, r io.ReaderAt, size int64) (*model.ParsedDocument, error) {
+	reader, err := zip.NewReader(r, size)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open docx file: %w", err)
 	}
 
-	var contentBuilder strings.Builder
-	var documentXML []byte
+	parts := map[string][]byte{}
+	var headers, footers []string
 
 	for _, file := range reader.File {
-		if file.Name == "word/document.xml" {
-			rc, err := file.Open()
-			if err != nil {
-				return nil, fmt.Errorf("failed to open document.xml: %w", err)
-			}
-			documentXML, err = io.ReadAll(rc)
-			rc.Close()
-			if err != nil {
-				return nil, fmt.Errorf("failed to read document.xml: %w", err)
-			}
-			break
+		name := file.Name
+		switch {
+		case name == "word/document.xml", name == "word/numbering.xml", name == "word/styles.xml",
+			name == "word/footnotes.xml", name == "word/endnotes.xml", name == "word/comments.xml",
+			name == "docProps/app.xml":
+		case strings.HasPrefix(name, "word/header") && strings.HasSuffix(name, ".xml"):
+			headers = append(headers, name)
+		case strings.HasPrefix(name, "word/footer") && strings.HasSuffix(name, ".xml"):
+			footers = append(footers, name)
+		default:
+			continue
 		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		data, err := readPart(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		parts[name] = data
 	}
 
-	if documentXML == nil {
+	documentXML, ok := parts["word/document.xml"]
+	if !ok {
 		return nil, fmt.Errorf("document.xml not found in docx")
 	}
 
-	text := p.extractTextFromXML(documentXML)
-	contentBuilder.WriteString(text)
+	walker := newDocxWalker(parseDocxNumbering(parts["word/numbering.xml"], parts["word/styles.xml"]), p.revisionMode)
+
+	body, err := walker.partLines(documentXML)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse document.xml: %w", err)
+	}
+
+	revisions := walker.revisions
+	comments := walker.comments(parts["word/comments.xml"])
+
+	w := &layoutWriter{}
+	for i, line := range body {
+		if i > 0 {
+			w.text("\n")
+		}
+		if line.cells != nil {
+			w.addRow(docxRowPrefix, line.cells, docxCellSep, docxRowSuffix)
+		} else {
+			w.addParagraph(line.text, nil)
+		}
+	}
+
+	sort.Strings(headers)
+	sort.Strings(footers)
+	p.writeSection(w, "Header", walker.uniquePartTexts(parts, headers))
+	p.writeSection(w, "Footer", walker.uniquePartTexts(parts, footers))
+	p.writeSection(w, "Footnotes", walker.notes(parts["word/footnotes.xml"], "footnote", "^"))
+	p.writeSection(w, "Endnotes", walker.notes(parts["word/endnotes.xml"], "endnote", "^e"))
 
-	return &model.ParsedDocument{
+	doc := &model.ParsedDocument{
 		FileName:   filepath.Base(filePath),
 		FileType:   model.FileTypeWord,
-		Content:    contentBuilder.String(),
-		PageCount:  1,
+		PageCount:  docxPageCount(parts["docProps/app.xml"]),
 		IsScanned:  false,
 		ImagePaths: nil,
-	}, nil
+		Comments:   comments,
+		Revisions:  revisions,
+	}
+	w.apply(doc)
+	return doc, nil
 }
 
-func (p *WordParser) extractTextFromXML(data []byte) string {
-	var result strings.Builder
-
-	decoder := xml.NewDecoder(bytes.NewReader(data))
-
-	for {
-		token, err := decoder.Token()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			continue
-		}
-
-		switch t := token.(type) {
-		case xml.CharData:
-			text := strings.TrimSpace(string(t))
-			if text != "" {
-				result.WriteString(text)
-			}
-		case xml.EndElement:
-			if t.Name.Local == "p" {
-				result.WriteString("\n")
-			} else if t.Name.Local == "t" {
-				result.WriteString(" ")
-			}
+// writeSection writes labelled texts after the body, one paragraph per line.
+func (p *WordParser) writeSection(w *layoutWriter, label string, texts []string) {
+	if len(texts) == 0 {
+		return
+	}
+	w.heading(fmt.Sprintf("\n\n=== %s ===", label))
+	for _, text := range texts {
+		for _, line := range strings.Split(text, "\n") {
+			w.text("\n")
+			w.addParagraph(line, nil)
 		}
 	}
+}
 
-	return result.String()
+func docxPageCount(appXML []byte) int {
+	root, err := parseXMLTree(appXML)
+	if err != nil {
+		return 1
+	}
+	if pages, err := strconv.Atoi(strings.TrimSpace(root.child("Properties").child("Pages").innerText())); err == nil && pages > 0 {
+		return pages
+	}
+	return 1
 }
 
 func (p *WordParser) Supports(filePath string) bool {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	return ext == ".docx" || ext == ".doc"
+	return hasExtension(filePath, p.Extensions())
+}
+
+func (p *WordParser) Extensions() []string {
+	return []string{".docx", ".doc"}
+}
+
+func (p *WordParser) Name() string {
+	return "word"
+}
+
+func (p *WordParser) Capabilities() Capabilities {
+	return Capabilities{
+		MIMETypes: []string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/msword"},
+		Comments:  true,
+	}
 }
@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	text     string
+}
+
+func parseXMLTree(data []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		parent := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local}
+			if len(t.Attr) > 0 {
+				node.attrs = make(map[string]string, len(t.Attr))
+				for _, attr := range t.Attr {
+					node.attrs[attr.Name.Local] = attr.Value
+				}
+			}
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(parent.children) > 0 && parent.children[len(parent.children)-1].name == "" {
+				parent.children[len(parent.children)-1].text += string(t)
+			} else {
+				parent.children = append(parent.children, &xmlNode{text: string(t)})
+			}
+		}
+	}
+
+	return root, nil
+}
+
+func (n *xmlNode) child(name string) *xmlNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *xmlNode) attr(name string) string {
+	if n == nil {
+		return ""
+	}
+	return n.attrs[name]
+}
+
+func (n *xmlNode) innerText() string {
+	if n == nil {
+		return ""
+	}
+	var builder strings.Builder
+	var walk func(*xmlNode)
+	walk = func(node *xmlNode) {
+		if node.name == "" {
+			builder.WriteString(node.text)
+		}
+		for _, c := range node.children {
+			walk(c)
+		}
+	}
+	walk(n)
+	return builder.String()
+}
+
+func (n *xmlNode) find(name string) *xmlNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+		if found := c.find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
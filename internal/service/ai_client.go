@@ -1,36 +1,124 @@
 package service
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"contract-key-extractor/internal/config"
 	"contract-key-extractor/internal/model"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+const (
+	defaultAITimeout     = 60 * time.Second
+	defaultAIPageTimeout = 20 * time.Second
+	// aiExtractCharsPerSecond is how much document text extraction is
+	// allowed per second on top of the base timeout.
+	aiExtractCharsPerSecond = 1000
+
+	requestIDHeader = "X-Request-ID"
+)
+
+// ErrAIServiceDisabled is returned by every call when no AI service is
+// configured; OCR is then unavailable.
+var ErrAIServiceDisabled = errors.New("AI service is not configured")
+
 type AIServiceClient struct {
-	baseURL    string
-	httpClient *http.Client
-	logger     *zap.Logger
+	baseURL     string
+	httpClient  *http.Client
+	timeout     time.Duration
+	pageTimeout time.Duration
+	maxRetries  int
+	breaker     *circuitBreaker
+	logger      *zap.Logger
 }
 
 func NewAIServiceClient(cfg *config.AIServiceConfig, logger *zap.Logger) *AIServiceClient {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultAITimeout
+	}
+	pageTimeout := time.Duration(cfg.PageTimeout) * time.Second
+	if pageTimeout <= 0 {
+		pageTimeout = defaultAIPageTimeout
+	}
+	maxRetries := defaultAIMaxRetries
+	if cfg.MaxRetries != nil {
+		maxRetries = max(*cfg.MaxRetries, 0)
+	}
+	threshold := cfg.BreakerThreshold
+	if threshold <= 0 {
+		threshold = defaultAIBreakerThreshold
+	}
+	cooldown := time.Duration(cfg.BreakerCooldown) * time.Second
+	if cooldown <= 0 {
+		cooldown = defaultAIBreakerCooldown
+	}
+
+	var baseURL string
+	if cfg.Host != "" {
+		baseURL = fmt.Sprintf("http://%s:%d", cfg.Host, cfg.Port)
+	}
+
 	return &AIServiceClient{
-		baseURL: fmt.Sprintf("http://%s:%d", cfg.Host, cfg.Port),
-		httpClient: &http.Client{
-			Timeout: time.Duration(cfg.Timeout) * time.Second,
-		},
-		logger: logger,
+		baseURL:     baseURL,
+		httpClient:  &http.Client{},
+		timeout:     timeout,
+		pageTimeout: pageTimeout,
+		maxRetries:  maxRetries,
+		breaker:     newCircuitBreaker(threshold, cooldown, logger),
+		logger:      logger,
+	}
+}
+
+// Enabled reports whether an AI service is configured.
+func (c *AIServiceClient) Enabled() bool {
+	return c.baseURL != ""
+}
+
+type requestIDKey struct{}
+
+// withRequestID makes the AI service calls made with ctx carry request IDs
+// starting with prefix, so that they can be matched with the AI service's
+// logs.
+func withRequestID(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, prefix)
+}
+
+// newRequestID returns the ID of a single call. Retries of the call reuse
+// it.
+func newRequestID(ctx context.Context) string {
+	id := uuid.New().String()
+	if prefix, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return prefix + "-" + id[:8]
 	}
+	return id
 }
 
-func (c *AIServiceClient) ExtractContractInfo(doc *model.ParsedDocument) (*model.AIExtractionResponse, error) {
+// ocrTimeout allows the base timeout plus the page timeout for every page.
+func (c *AIServiceClient) ocrTimeout(pages int) time.Duration {
+	return c.timeout + time.Duration(max(pages, 1))*c.pageTimeout
+}
+
+func (c *AIServiceClient) extractTimeout(text string) time.Duration {
+	chars := utf8.RuneCountInString(text)
+	return c.timeout + time.Duration(chars/aiExtractCharsPerSecond)*time.Second
+}
+
+func (c *AIServiceClient) ExtractContractInfo(ctx context.Context, doc *model.ParsedDocument) (*model.AIExtractionResponse, error) {
 	reqBody := model.AIExtractionRequest{
 		DocumentText: doc.Content,
 	}
@@ -41,13 +129,14 @@ func (c *AIServiceClient) ExtractContractInfo(doc *model.ParsedDocument) (*model
 	}
 
 	url := c.baseURL + "/api/v1/extract"
-	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.send(ctx, c.extractTimeout(doc.Content), true, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -55,7 +144,7 @@ func (c *AIServiceClient) ExtractContractInfo(doc *model.ParsedDocument) (*model
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("AI service returned error: %s - %s", resp.Status, string(respBody))
+		return nil, fmt.Errorf("AI service returned error: %s - %s (request %s)", resp.Status, string(respBody), responseID(resp))
 	}
 
 	var result model.AIExtractionResponse
@@ -66,86 +155,211 @@ func (c *AIServiceClient) ExtractContractInfo(doc *model.ParsedDocument) (*model
 	return &result, nil
 }
 
-func (c *AIServiceClient) PerformOCR(fileData []byte) (string, error) {
-	url := c.baseURL + "/api/v1/ocr/raw"
-	req, err := http.NewRequest("POST", url, bytes.NewReader(fileData))
+// PerformOCR recognises a single image. fileName is sent as the multipart
+// filename; the MIME type is taken from the file content.
+func (c *AIServiceClient) PerformOCR(ctx context.Context, fileName string, image io.Reader) (*model.OCRResponse, error) {
+	return c.postOCR(ctx, "/api/v1/ocr", fileName, image, nil, c.ocrTimeout(1))
+}
+
+// PerformPDFOCR recognises every page of a PDF. pages is the document's page
+// count and sets how long the call may take.
+func (c *AIServiceClient) PerformPDFOCR(ctx context.Context, pdf io.Reader, pages int) (string, error) {
+	result, err := c.postOCR(ctx, "/api/v1/ocr/pdf", "document.pdf", pdf, nil, c.ocrTimeout(pages))
 	if err != nil {
-		return "", fmt.Errorf("failed to create OCR request: %w", err)
+		return "", err
 	}
-	req.Header.Set("Content-Type", "application/octet-stream")
+	return result.Text, nil
+}
 
-	resp, err := c.httpClient.Do(req)
+// PerformPDFPageOCR recognises only the given (1-based) pages of a PDF. Pages
+// the service could not read are returned with Failed set.
+func (c *AIServiceClient) PerformPDFPageOCR(ctx context.Context, pdf io.Reader, pages []int) (map[int]model.OCRPage, error) {
+	pageList := make([]string, len(pages))
+	for i, page := range pages {
+		pageList[i] = strconv.Itoa(page)
+	}
+
+	result, err := c.postOCR(ctx, "/api/v1/ocr/pdf", "document.pdf", pdf, map[string]string{
+		"pages": strings.Join(pageList, ","),
+	}, c.ocrTimeout(len(pages)))
 	if err != nil {
-		return "", fmt.Errorf("failed to send OCR request: %w", err)
+		return nil, err
+	}
+
+	results := make(map[int]model.OCRPage, len(result.Pages))
+	for _, page := range result.Pages {
+		results[page.Page] = page
+	}
+
+	return results, nil
+}
+
+// CheckOCRContract asks the AI service which OCR contract version it speaks
+// and reports an error if it differs from the client's.
+func (c *AIServiceClient) CheckOCRContract(ctx context.Context) error {
+	resp, err := c.get(ctx, "/api/v1/ocr/contract")
+	if err != nil {
+		return fmt.Errorf("failed to query OCR contract: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("OCR service returned error: %s - %s", resp.Status, string(respBody))
+		return fmt.Errorf("OCR contract endpoint returned error: %s", resp.Status)
 	}
 
 	var result struct {
-		Text string `json:"text"`
+		Version string `json:"version"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode OCR response: %w", err)
+		return fmt.Errorf("failed to decode OCR contract response: %w", err)
 	}
 
-	return result.Text, nil
+	return checkOCRContractVersion(result.Version)
 }
 
-func (c *AIServiceClient) PerformPDFOCR(pdfData []byte) (string, error) {
-	url := c.baseURL + "/api/v1/ocr/pdf"
+// postOCR uploads file as a multipart form. The body is streamed as it is
+// written, so the file is never held in memory in full. A failed upload is
+// retried only if file can be rewound.
+func (c *AIServiceClient) postOCR(ctx context.Context, path, fileName string, file io.Reader, fields map[string]string, timeout time.Duration) (*model.OCRResponse, error) {
+	url := c.baseURL + path
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+	seeker, replayable := file.(io.Seeker)
+	var start int64
+	if replayable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			replayable = false
+		}
+	}
+
+	var upload *ocrUpload
+	defer func() {
+		if upload != nil {
+			upload.close()
+		}
+	}()
+
+	resp, err := c.send(ctx, timeout, replayable, func(ctx context.Context) (*http.Request, error) {
+		if upload != nil {
+			upload.close()
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("failed to rewind OCR upload: %w", err)
+			}
+		}
+		upload = startOCRUpload(file, fileName, fields)
 
-	part, err := writer.CreateFormFile("file", "document.pdf")
+		req, err := http.NewRequestWithContext(ctx, "POST", url, upload.body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OCR request: %w", err)
+		}
+		req.Header.Set("Content-Type", upload.contentType)
+		req.Header.Set(ocrContractHeader, model.OCRContractVersion)
+		return req, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to create form file: %w", err)
+		return nil, fmt.Errorf("failed to send OCR request: %w", err)
 	}
+	defer resp.Body.Close()
 
-	_, err = part.Write(pdfData)
-	if err != nil {
-		return "", fmt.Errorf("failed to write pdf data: %w", err)
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("OCR service returned error: %s - %s (request %s)", resp.Status, string(respBody), responseID(resp))
 	}
 
-	err = writer.Close()
-	if err != nil {
-		return "", fmt.Errorf("failed to close writer: %w", err)
+	var result model.OCRResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode OCR response: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, body)
-	if err != nil {
-		return "", fmt.Errorf("failed to create PDF OCR request: %w", err)
+	if err := checkOCRContractVersion(result.ContractVersion); err != nil {
+		return nil, err
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := c.httpClient.Do(req)
+	return &result, nil
+}
+
+// ocrUpload is one streamed OCR request body.
+type ocrUpload struct {
+	body        *io.PipeReader
+	contentType string
+	done        chan struct{}
+}
+
+func startOCRUpload(file io.Reader, fileName string, fields map[string]string) *ocrUpload {
+	source := bufio.NewReader(file)
+	head, _ := source.Peek(512)
+	contentType := ocrContentType(head)
+
+	body, pipe := io.Pipe()
+	writer := multipart.NewWriter(pipe)
+	upload := &ocrUpload{
+		body:        body,
+		contentType: writer.FormDataContentType(),
+		done:        make(chan struct{}),
+	}
+
+	go func() {
+		defer close(upload.done)
+		pipe.CloseWithError(writeOCRForm(writer, fileName, contentType, source, fields))
+	}()
+	return upload
+}
+
+// close stops the upload and waits until file is no longer being read.
+func (u *ocrUpload) close() {
+	u.body.Close()
+	<-u.done
+}
+
+func writeOCRForm(writer *multipart.Writer, fileName, contentType string, file io.Reader, fields map[string]string) error {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, escapeQuotes(fileName)))
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
 	if err != nil {
-		return "", fmt.Errorf("failed to send PDF OCR request: %w", err)
+		return fmt.Errorf("failed to create form file: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("PDF OCR service returned error: %s - %s", resp.Status, string(respBody))
+	if _, err := io.Copy(part, file); err != nil {
+		return fmt.Errorf("failed to write file data: %w", err)
 	}
 
-	var result struct {
-		Text string `json:"text"`
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return fmt.Errorf("failed to write %s field: %w", name, err)
+		}
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode PDF OCR response: %w", err)
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
 	}
+	return nil
+}
 
-	return result.Text, nil
+const ocrContractHeader = "X-OCR-Contract-Version"
+
+func checkOCRContractVersion(version string) error {
+	if version != model.OCRContractVersion {
+		return fmt.Errorf("OCR contract mismatch: client speaks version %s, AI service answered %q", model.OCRContractVersion, version)
+	}
+	return nil
 }
 
-func (c *AIServiceClient) HealthCheck() error {
-	url := c.baseURL + "/health"
-	resp, err := c.httpClient.Get(url)
+func ocrContentType(data []byte) string {
+	if bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")) {
+		return "image/tiff"
+	}
+	return http.DetectContentType(data)
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+func (c *AIServiceClient) HealthCheck(ctx context.Context) error {
+	resp, err := c.get(ctx, "/health")
 	if err != nil {
 		return fmt.Errorf("AI service health check failed: %w", err)
 	}
@@ -157,3 +371,42 @@ func (c *AIServiceClient) HealthCheck() error {
 
 	return nil
 }
+
+// get makes a single GET request with the base timeout, outside the retries
+// and the circuit breaker.
+func (c *AIServiceClient) get(ctx context.Context, path string) (*http.Response, error) {
+	if !c.Enabled() {
+		return nil, ErrAIServiceDisabled
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set(requestIDHeader, newRequestID(ctx))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = cancelBody{resp.Body, cancel}
+	return resp, nil
+}
+
+// cancelBody releases a request's context once its response has been read.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func responseID(resp *http.Response) string {
+	return resp.Request.Header.Get(requestIDHeader)
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"contract-key-extractor/internal/config"
+	"contract-key-extractor/internal/model"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// newTestAIClient returns a client of an AI service stub served by handler.
+func newTestAIClient(t *testing.T, handler http.HandlerFunc) *AIServiceClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	return NewAIServiceClient(&config.AIServiceConfig{Host: host, Port: portNumber, Timeout: 5}, zap.NewNop())
+}
+
+func writeOCRResponse(w http.ResponseWriter, version string) {
+	json.NewEncoder(w).Encode(model.OCRResponse{
+		Text:            "识别结果",
+		Pages:           []model.OCRPage{{Page: 1, Text: "识别结果", Confidence: 0.9}},
+		ContractVersion: version,
+	})
+}
+
+func TestPerformOCRSendsMultipartForm(t *testing.T) {
+	client := newTestAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/ocr" {
+			t.Errorf("path = %s, want /api/v1/ocr", r.URL.Path)
+		}
+		if got := r.Header.Get(ocrContractHeader); got != model.OCRContractVersion {
+			t.Errorf("%s = %q, want %q", ocrContractHeader, got, model.OCRContractVersion)
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("no file field: %v", err)
+		}
+		defer file.Close()
+		if header.Filename != `scan "1".png` {
+			t.Errorf("filename = %q", header.Filename)
+		}
+		if got := header.Header.Get("Content-Type"); got != "image/png" {
+			t.Errorf("file Content-Type = %q, want image/png", got)
+		}
+		data, _ := io.ReadAll(file)
+		if !bytes.Equal(data, testPNG) {
+			t.Errorf("file data = %q", data)
+		}
+		writeOCRResponse(w, model.OCRContractVersion)
+	})
+
+	result, err := client.PerformOCR(context.Background(), `scan "1".png`, bytes.NewReader(testPNG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Text != "识别结果" {
+		t.Errorf("text = %q", result.Text)
+	}
+}
+
+func TestPerformPDFPageOCRSendsPages(t *testing.T) {
+	client := newTestAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/ocr/pdf" {
+			t.Errorf("path = %s, want /api/v1/ocr/pdf", r.URL.Path)
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("no file field: %v", err)
+		}
+		file.Close()
+		if header.Filename != "document.pdf" {
+			t.Errorf("filename = %q", header.Filename)
+		}
+		if got := header.Header.Get("Content-Type"); got != "application/pdf" {
+			t.Errorf("file Content-Type = %q, want application/pdf", got)
+		}
+		if got := r.FormValue("pages"); got != "1,3" {
+			t.Errorf("pages = %q, want 1,3", got)
+		}
+		json.NewEncoder(w).Encode(model.OCRResponse{
+			Pages:           []model.OCRPage{{Page: 1, Text: "一"}, {Page: 3, Failed: true}},
+			ContractVersion: model.OCRContractVersion,
+		})
+	})
+
+	pages, err := client.PerformPDFPageOCR(context.Background(), strings.NewReader("%PDF-1.4\n"), []int{1, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages[1].Text != "一" || !pages[3].Failed {
+		t.Errorf("pages = %+v", pages)
+	}
+}
+
+func TestPerformOCRRejectsContractMismatch(t *testing.T) {
+	client := newTestAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		writeOCRResponse(w, "0")
+	})
+
+	if _, err := client.PerformOCR(context.Background(), "scan.png", bytes.NewReader(testPNG)); err == nil {
+		t.Fatal("expected a contract mismatch error")
+	}
+}
+
+func TestCheckOCRContract(t *testing.T) {
+	for _, tc := range []struct {
+		version string
+		ok      bool
+	}{
+		{model.OCRContractVersion, true},
+		{"0", false},
+		{"", false},
+	} {
+		client := newTestAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v1/ocr/contract" {
+				t.Errorf("path = %s, want /api/v1/ocr/contract", r.URL.Path)
+			}
+			json.NewEncoder(w).Encode(map[string]string{"version": tc.version})
+		})
+
+		err := client.CheckOCRContract(context.Background())
+		if (err == nil) != tc.ok {
+			t.Errorf("version %q: err = %v", tc.version, err)
+		}
+	}
+}
+
+func TestNewAIServiceClientMaxRetries(t *testing.T) {
+	retries := func(n int) *int { return &n }
+	for _, tc := range []struct {
+		configured *int
+		want       int
+	}{
+		{nil, defaultAIMaxRetries},
+		{retries(0), 0},
+		{retries(-1), 0},
+		{retries(2), 2},
+	} {
+		client := NewAIServiceClient(&config.AIServiceConfig{MaxRetries: tc.configured}, zap.NewNop())
+		if client.maxRetries != tc.want {
+			t.Errorf("max_retries %v: maxRetries = %d, want %d", tc.configured, client.maxRetries, tc.want)
+		}
+	}
+}
+
+func TestAIClientSendWithoutRetries(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	})
+	client.maxRetries = 0
+
+	resp, err := client.send(context.Background(), time.Second, true, func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", client.baseURL+"/health", nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 {
+		t.Errorf("%d calls, want 1", calls.Load())
+	}
+}
+
+func TestAIClientRequestBuildErrorsDoNotTripBreaker(t *testing.T) {
+	client := newTestAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no request should be sent")
+	})
+	errRewind := errors.New("failed to rewind OCR upload")
+
+	for i := 0; i < defaultAIBreakerThreshold+1; i++ {
+		_, err := client.send(context.Background(), time.Second, true, func(context.Context) (*http.Request, error) {
+			return nil, errRewind
+		})
+		if !errors.Is(err, errRewind) {
+			t.Fatalf("err = %v, want the build error", err)
+		}
+	}
+	if client.breaker.state != breakerClosed || client.breaker.failures != 0 {
+		t.Errorf("breaker state %d with %d failures, want closed with none", client.breaker.state, client.breaker.failures)
+	}
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAIMaxRetries       = 3
+	defaultAIBreakerThreshold = 5
+	defaultAIBreakerCooldown  = 30 * time.Second
+	aiInitialBackoff          = 500 * time.Millisecond
+	aiMaxBackoff              = 30 * time.Second
+	aiMaxRetryAfter           = 2 * time.Minute
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker tracks whether the AI service is reachable. After threshold
+// consecutive failures it opens, and callers wait instead of sending
+// requests that are bound to fail. Once the cooldown has passed, one caller
+// is let through to probe the service; its outcome closes the breaker or
+// opens it for another cooldown.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	changed   chan struct{} // closed and replaced whenever state changes
+	threshold int
+	cooldown  time.Duration
+	logger    *zap.Logger
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, logger *zap.Logger) *circuitBreaker {
+	return &circuitBreaker{
+		changed:   make(chan struct{}),
+		threshold: threshold,
+		cooldown:  cooldown,
+		logger:    logger,
+	}
+}
+
+// wait blocks while the breaker is open. probe is true when the caller has
+// been chosen to probe the service, and must report the outcome with success,
+// failure or abort.
+func (b *circuitBreaker) wait(ctx context.Context) (probe bool, err error) {
+	for {
+		b.mu.Lock()
+		state, changed := b.state, b.changed
+		remaining := b.cooldown - time.Since(b.openedAt)
+		if state == breakerClosed {
+			b.mu.Unlock()
+			return false, nil
+		}
+		if state == breakerOpen && remaining <= 0 {
+			b.setState(breakerHalfOpen)
+			b.mu.Unlock()
+			return true, nil
+		}
+		b.mu.Unlock()
+
+		var timer *time.Timer
+		var elapsed <-chan time.Time
+		if state == breakerOpen {
+			timer = time.NewTimer(remaining)
+			elapsed = timer.C
+		}
+		select {
+		case <-changed:
+		case <-elapsed:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	if b.state != breakerClosed {
+		b.logger.Info("AI service is available again, resuming extraction")
+		b.setState(breakerClosed)
+	}
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= b.threshold) {
+		if b.state == breakerClosed {
+			b.logger.Warn("AI service is unavailable, pausing extraction",
+				zap.Int("failures", b.failures),
+				zap.Duration("cooldown", b.cooldown),
+			)
+		}
+		b.openedAt = time.Now()
+		b.setState(breakerOpen)
+	}
+}
+
+// abort gives up a probe without an outcome, so that the next caller probes
+// straight away.
+func (b *circuitBreaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.openedAt = time.Time{}
+		b.setState(breakerOpen)
+	}
+}
+
+// setState switches state and wakes the waiters. b.mu must be held.
+func (b *circuitBreaker) setState(state breakerState) {
+	b.state = state
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// waitAvailable blocks while the AI service is considered down, probing it
+// with a health check whenever the breaker allows.
+func (c *AIServiceClient) waitAvailable(ctx context.Context) error {
+	for {
+		probe, err := c.breaker.wait(ctx)
+		if err != nil || !probe {
+			return err
+		}
+
+		err = c.HealthCheck(ctx)
+		switch {
+		case err == nil:
+			c.breaker.success()
+			return nil
+		case ctx.Err() != nil:
+			c.breaker.abort()
+			return ctx.Err()
+		default:
+			c.logger.Warn("AI service probe failed", zap.Error(err))
+			c.breaker.failure()
+		}
+	}
+}
+
+// send makes the request built by newRequest, retrying network errors,
+// timeouts, 5xx and 429 responses with exponential backoff. Each attempt may
+// take up to timeout. newRequest is called once per attempt, with the
+// attempt's context; a request whose body cannot be rebuilt is sent only
+// once, and a failure to build it ends the call without counting against
+// the service. The final response is returned whatever its status.
+func (c *AIServiceClient) send(ctx context.Context, timeout time.Duration, replayable bool, newRequest func(context.Context) (*http.Request, error)) (*http.Response, error) {
+	if !c.Enabled() {
+		return nil, ErrAIServiceDisabled
+	}
+	requestID := newRequestID(ctx)
+	for attempt := 0; ; attempt++ {
+		if err := c.waitAvailable(ctx); err != nil {
+			return nil, err
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		req, err := newRequest(attemptCtx)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("request %s: %w", requestID, err)
+		}
+
+		resp, err := c.attempt(req, requestID, cancel)
+		retry, retryAfter := c.classify(ctx, resp, err)
+		if !retry || !replayable || attempt >= c.maxRetries {
+			if err != nil {
+				err = fmt.Errorf("request %s: %w", requestID, err)
+			}
+			return resp, err
+		}
+
+		delay := max(backoff(attempt), retryAfter)
+		fields := []zap.Field{
+			zap.String("request_id", requestID),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		} else {
+			fields = append(fields, zap.String("status", resp.Status))
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		c.logger.Warn("AI service request failed, retrying", fields...)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// attempt sends req. cancel releases the attempt's context once the response
+// body is closed.
+func (c *AIServiceClient) attempt(req *http.Request, requestID string, cancel context.CancelFunc) (*http.Response, error) {
+	req.Header.Set(requestIDHeader, requestID)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = cancelBody{resp.Body, cancel}
+	return resp, nil
+}
+
+// classify reports whether a request outcome is worth retrying and for how
+// long the service asked to be left alone, and tells the breaker about it.
+// err is a transport error: network errors and 5xx responses count as the
+// service being down; a 429 means it is up but busy.
+func (c *AIServiceClient) classify(ctx context.Context, resp *http.Response, err error) (bool, time.Duration) {
+	switch {
+	case err != nil:
+		if ctx.Err() != nil {
+			return false, 0
+		}
+		c.breaker.failure()
+		return true, 0
+	case resp.StatusCode == http.StatusTooManyRequests:
+		c.breaker.success()
+		return true, retryAfter(resp)
+	case resp.StatusCode >= 500:
+		c.breaker.failure()
+		return true, retryAfter(resp)
+	default:
+		c.breaker.success()
+		return false, 0
+	}
+}
+
+// backoff returns the delay before retry number attempt+1: exponential,
+// with the upper half jittered so that workers do not retry in lockstep.
+func backoff(attempt int) time.Duration {
+	delay := aiInitialBackoff << attempt
+	if delay <= 0 || delay > aiMaxBackoff {
+		delay = aiMaxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP
+// date.
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = time.Until(date)
+	}
+	return min(max(delay, 0), aiMaxRetryAfter)
+}
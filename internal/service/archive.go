@@ -0,0 +1,272 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+const (
+	defaultMaxArchiveEntries = 1000
+	defaultMaxExtractedSize  = 1 << 30
+	// maxCompressionRatio rejects entries that inflate suspiciously well,
+	// which is the signature of a zip bomb rather than of office documents.
+	maxCompressionRatio = 200
+)
+
+var sevenZipSignature = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}
+
+var errArchiveTooLarge = errors.New("archive exceeds extraction limits")
+
+// archiveEntry is one entry of a zip or 7z archive. compressed is 0 when the
+// format does not record it per entry, as in solid 7z archives.
+type archiveEntry struct {
+	name       string
+	mode       os.FileMode
+	compressed uint64
+	size       uint64
+	open       func() (io.ReadCloser, error)
+}
+
+// archiveFile is a document extracted from an uploaded archive. Group is the
+// folder it sat in inside the archive, using forward slashes, and is empty
+// for documents at the top level.
+type archiveFile struct {
+	path  string
+	group string
+}
+
+func isArchiveFile(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".zip", ".7z":
+		return true
+	}
+	return false
+}
+
+// expandArchive extracts the supported documents in an uploaded archive into
+// a directory next to it, and returns that directory and the documents.
+// Entries that would escape that directory, links, nested archives and files
+// no parser handles are skipped; entry count, total size and, for zip,
+// compression ratio are bounded to defend against zip bombs.
+func (s *ExtractionService) expandArchive(archivePath string) (destDir string, files []archiveFile, err error) {
+	// The 7z reader panics on some malformed archives.
+	defer func() {
+		if r := recover(); r != nil {
+			files, err = nil, fmt.Errorf("malformed archive: %v", r)
+		}
+		if err != nil && destDir != "" {
+			os.RemoveAll(destDir)
+			destDir = ""
+		}
+	}()
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to stat archive: %w", err)
+	}
+
+	entries, err := readArchiveEntries(f, info.Size())
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	maxEntries := s.cfg.Upload.MaxArchiveEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxArchiveEntries
+	}
+	maxSize := s.cfg.Upload.MaxExtractedSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxExtractedSize
+	}
+	if len(entries) > maxEntries {
+		return "", nil, fmt.Errorf("%w: %d entries, limit is %d", errArchiveTooLarge, len(entries), maxEntries)
+	}
+
+	baseName := strings.TrimSuffix(filepath.Base(archivePath), filepath.Ext(archivePath))
+	destDir = filepath.Join(filepath.Dir(archivePath), fmt.Sprintf("%s_%s", baseName, uuid.New().String()[:8]))
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create extraction directory: %w", err)
+	}
+
+	var extracted int64
+	for _, entry := range entries {
+		name := entry.name
+		if entry.mode.IsDir() || skipArchiveEntry(name) {
+			continue
+		}
+
+		cleaned, ok := safeArchivePath(name)
+		if !ok {
+			s.logger.Warn("skipping unsafe archive entry",
+				zap.String("archive", archivePath),
+				zap.String("entry", name),
+			)
+			continue
+		}
+		if entry.mode&os.ModeType != 0 {
+			s.logger.Warn("skipping non-regular archive entry",
+				zap.String("archive", archivePath),
+				zap.String("entry", name),
+			)
+			continue
+		}
+		if isArchiveFile(cleaned) || !s.parserManager.IsSupported(cleaned) {
+			s.logger.Info("skipping unsupported archive entry",
+				zap.String("archive", archivePath),
+				zap.String("entry", name),
+			)
+			continue
+		}
+
+		if entry.compressed > 0 && entry.size/entry.compressed > maxCompressionRatio {
+			return "", nil, fmt.Errorf("%w: entry %s has a compression ratio above %d", errArchiveTooLarge, name, maxCompressionRatio)
+		}
+
+		dst := filepath.Join(destDir, filepath.FromSlash(cleaned))
+		written, err := extractArchiveEntry(entry, dst, maxSize-extracted)
+		if errors.Is(err, os.ErrExist) {
+			s.logger.Warn("skipping duplicate archive entry",
+				zap.String("archive", archivePath),
+				zap.String("entry", name),
+			)
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+		extracted += written
+
+		group := path.Dir(cleaned)
+		if group == "." {
+			group = ""
+		}
+		files = append(files, archiveFile{path: dst, group: group})
+	}
+
+	return destDir, files, nil
+}
+
+// readArchiveEntries lists the entries of a 7z archive, recognised by its
+// signature, or of a zip archive.
+func readArchiveEntries(r io.ReaderAt, size int64) ([]archiveEntry, error) {
+	signature := make([]byte, len(sevenZipSignature))
+	if n, _ := r.ReadAt(signature, 0); bytes.Equal(signature[:n], sevenZipSignature) {
+		reader, err := sevenzip.NewReader(r, size)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]archiveEntry, 0, len(reader.File))
+		for _, file := range reader.File {
+			entries = append(entries, archiveEntry{
+				name: file.Name,
+				mode: file.Mode(),
+				size: file.UncompressedSize,
+				open: file.Open,
+			})
+		}
+		return entries, nil
+	}
+
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]archiveEntry, 0, len(reader.File))
+	for _, file := range reader.File {
+		entries = append(entries, archiveEntry{
+			name:       archiveEntryName(file),
+			mode:       file.Mode(),
+			compressed: file.CompressedSize64,
+			size:       file.UncompressedSize64,
+			open:       file.Open,
+		})
+	}
+	return entries, nil
+}
+
+// extractArchiveEntry copies one entry to dst, writing at most limit bytes.
+// The size recorded in the archive header is not trusted.
+func extractArchiveEntry(entry archiveEntry, dst string, limit int64) (int64, error) {
+	if limit <= 0 {
+		return 0, errArchiveTooLarge
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, err
+	}
+
+	rc, err := entry.open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	written, err := io.Copy(out, io.LimitReader(rc, limit+1))
+	if err != nil {
+		return written, err
+	}
+	if written > limit {
+		return written, errArchiveTooLarge
+	}
+	return written, nil
+}
+
+// archiveEntryName returns the entry name as UTF-8. Archives made by the
+// Windows shell on Chinese systems store names in GBK without flagging it.
+func archiveEntryName(file *zip.File) string {
+	if !file.NonUTF8 || utf8.ValidString(file.Name) {
+		return file.Name
+	}
+	if name, err := simplifiedchinese.GBK.NewDecoder().String(file.Name); err == nil {
+		return name
+	}
+	return file.Name
+}
+
+// safeArchivePath cleans an entry name and rejects names that are absolute
+// or climb out of the extraction directory (zip slip).
+func safeArchivePath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" || strings.Contains(name, ":") {
+		return "", false
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", false
+	}
+	return cleaned, true
+}
+
+// skipArchiveEntry reports whether an entry is operating system metadata or
+// an Office lock file rather than a document.
+func skipArchiveEntry(name string) bool {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "__MACOSX/") {
+		return true
+	}
+	base := path.Base(name)
+	return strings.HasPrefix(base, "._") || strings.HasPrefix(base, "~$") || base == ".DS_Store" || strings.EqualFold(base, "Thumbs.db") || strings.EqualFold(base, "desktop.ini")
+}
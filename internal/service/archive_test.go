@@ -0,0 +1,186 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"contract-key-extractor/internal/config"
+	"contract-key-extractor/internal/parser"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"unicode/utf16"
+
+	"go.uber.org/zap"
+)
+
+var testArchiveEntries = []struct {
+	name, content string
+}{
+	{"合同A.txt", "甲方：甲公司"},
+	{"华东/合同B.txt", "乙方：乙公司"},
+	{"../escape.txt", "zip slip"},
+	{"notes.bin", "unsupported"},
+}
+
+func newTestArchiveService(t *testing.T) *ExtractionService {
+	t.Helper()
+	return &ExtractionService{
+		cfg:           &config.Config{},
+		parserManager: parser.NewParserManager(&config.ParserConfig{}, zap.NewNop()),
+		logger:        zap.NewNop(),
+	}
+}
+
+func writeTestZip(t *testing.T, path string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, entry := range testArchiveEntries {
+		f, err := w.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(entry.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTest7z(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, buildTest7z(true), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// buildTest7z returns the entries as a 7z archive holding a single folder
+// stored with the Copy method. All sizes are kept below 0x80 so that every
+// 7z number fits in one byte. Without digests the header is malformed.
+func buildTest7z(digests bool) []byte {
+	var packed []byte
+	for _, entry := range testArchiveEntries {
+		packed = append(packed, entry.content...)
+	}
+
+	header := []byte{
+		0x01,       // Header
+		0x04,       // MainStreamsInfo
+		0x06, 0x00, // PackInfo, pack position
+		0x01, 0x09, byte(len(packed)), 0x00, // one pack stream, Size, End
+		0x07, 0x0B, 0x01, 0x00, // UnpackInfo, Folder, one folder, not external
+		0x01, 0x01, 0x00, // one coder: 1-byte id, Copy
+		0x0C, byte(len(packed)), 0x00, // CodersUnpackSize, End
+		0x08, 0x0D, byte(len(testArchiveEntries)), // SubStreamsInfo, NumUnpackStream
+		0x09, // Size of all substreams but the last
+	}
+	for _, entry := range testArchiveEntries[:len(testArchiveEntries)-1] {
+		header = append(header, byte(len(entry.content)))
+	}
+	if digests {
+		header = append(header, 0x0A, 0x01) // CRC, all defined
+		for _, entry := range testArchiveEntries {
+			header = binary.LittleEndian.AppendUint32(header, crc32.ChecksumIEEE([]byte(entry.content)))
+		}
+	}
+	header = append(header, 0x00, 0x00) // End of SubStreamsInfo and MainStreamsInfo
+
+	var names []byte
+	for _, entry := range testArchiveEntries {
+		for _, unit := range append(utf16.Encode([]rune(entry.name)), 0) {
+			names = binary.LittleEndian.AppendUint16(names, unit)
+		}
+	}
+	header = append(header, 0x05, byte(len(testArchiveEntries))) // FilesInfo
+	header = append(header, 0x11, byte(len(names)+1), 0x00)      // Name, not external
+	header = append(header, names...)
+	header = append(header, 0x00, 0x00) // End of FilesInfo and Header
+
+	startHeader := make([]byte, 20)
+	binary.LittleEndian.PutUint64(startHeader, uint64(len(packed)))
+	binary.LittleEndian.PutUint64(startHeader[8:], uint64(len(header)))
+	binary.LittleEndian.PutUint32(startHeader[16:], crc32.ChecksumIEEE(header))
+
+	data := append([]byte{}, sevenZipSignature...)
+	data = append(data, 0x00, 0x04)
+	data = binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(startHeader))
+	data = append(data, startHeader...)
+	data = append(data, packed...)
+	return append(data, header...)
+}
+
+func TestExpandArchive(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		write func(*testing.T, string)
+	}{
+		{"contracts.zip", writeTestZip},
+		{"contracts.7z", writeTest7z},
+	} {
+		archivePath := filepath.Join(t.TempDir(), tc.name)
+		tc.write(t, archivePath)
+
+		_, files, err := newTestArchiveService(t).expandArchive(archivePath)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		sort.Slice(files, func(i, j int) bool { return filepath.Base(files[i].path) < filepath.Base(files[j].path) })
+		if len(files) != 2 {
+			t.Fatalf("%s: extracted %+v, want the two contracts", tc.name, files)
+		}
+
+		for i, want := range []struct{ name, group, content string }{
+			{"合同A.txt", "", "甲方：甲公司"},
+			{"合同B.txt", "华东", "乙方：乙公司"},
+		} {
+			if filepath.Base(files[i].path) != want.name || files[i].group != want.group {
+				t.Errorf("%s: file %d = %+v, want %s in group %q", tc.name, i, files[i], want.name, want.group)
+			}
+			data, err := os.ReadFile(files[i].path)
+			if err != nil || string(data) != want.content {
+				t.Errorf("%s: %s holds %q (%v), want %q", tc.name, want.name, data, err, want.content)
+			}
+		}
+	}
+}
+
+func TestExpandArchiveMalformed7z(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "broken.7z")
+	if err := os.WriteFile(archivePath, buildTest7z(false), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := newTestArchiveService(t).expandArchive(archivePath); err == nil {
+		t.Fatal("expected an error")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("extraction left %d entries behind", len(entries)-1)
+	}
+}
+
+func TestProcessFilesRemovesExtractionOnQueueFull(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "contracts.zip")
+	writeTestZip(t, archivePath)
+
+	s := newTestArchiveService(t)
+	s.queue = newTaskQueue(1)
+	if err := s.queue.reserve(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ProcessFiles([]string{archivePath}, false, ""); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("err = %v, want ErrQueueFull", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("extraction left %d entries behind", len(entries)-1)
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"contract-key-extractor/internal/model"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	eventTypeStatus  = "status"
+	eventTypeStage   = "stage"
+	eventTypeDropped = "dropped"
+
+	stageParsing    = "parsing"
+	stageOCR        = "ocr"
+	stageExtracting = "extracting"
+	stageExported   = "exported"
+	stageFailed     = "failed"
+
+	// eventBufferSize is how many events a subscriber may fall behind by
+	// before it is dropped.
+	eventBufferSize = 64
+)
+
+// stageFunc is told when a file enters a processing stage.
+type stageFunc func(stage string)
+
+// eventBus fans task events out to the subscribers of each task. Publishing
+// never blocks: a subscriber that falls too far behind is sent a dropped
+// event, has its channel closed and must subscribe again.
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[string]map[chan model.TaskEvent]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: map[string]map[chan model.TaskEvent]struct{}{}}
+}
+
+func (b *eventBus) subscribe(taskID string) (<-chan model.TaskEvent, func()) {
+	// The extra slot keeps room for the dropped event.
+	ch := make(chan model.TaskEvent, eventBufferSize+1)
+
+	b.mu.Lock()
+	if b.subs[taskID] == nil {
+		b.subs[taskID] = map[chan model.TaskEvent]struct{}{}
+	}
+	b.subs[taskID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(taskID, ch)
+	}
+}
+
+func (b *eventBus) publish(event model.TaskEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Only publish sends, and only with b.mu held, so a channel with room
+	// cannot fill up before the send.
+	for ch := range b.subs[event.TaskID] {
+		if len(ch) < eventBufferSize {
+			ch <- event
+			continue
+		}
+		ch <- model.TaskEvent{
+			Type:   eventTypeDropped,
+			TaskID: event.TaskID,
+			Error:  "subscriber fell too far behind, subscribe again",
+			Time:   time.Now().Format(time.RFC3339),
+		}
+		b.remove(event.TaskID, ch)
+	}
+}
+
+// remove closes ch unless it has already been removed. b.mu must be held.
+func (b *eventBus) remove(taskID string, ch chan model.TaskEvent) {
+	subs := b.subs[taskID]
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(b.subs, taskID)
+	}
+}
+
+// Subscribe returns the events of a task as they happen, and a function to
+// stop receiving them. A subscriber that falls behind is sent a dropped
+// event, after which the channel is closed.
+func (s *ExtractionService) Subscribe(taskID string) (<-chan model.TaskEvent, func(), error) {
+	if _, err := s.store.GetTask(taskID); err != nil {
+		return nil, nil, err
+	}
+	events, cancel := s.events.subscribe(taskID)
+	return events, cancel, nil
+}
+
+// StatusEvent describes the task's current state as a status event.
+func StatusEvent(task *Task) model.TaskEvent {
+	return model.TaskEvent{
+		Type:       eventTypeStatus,
+		TaskID:     task.ID,
+		Status:     task.Status,
+		Progress:   task.Progress,
+		Processed:  task.Processed,
+		Failed:     task.Failed,
+		ResultPath: task.ResultPath,
+		Error:      task.Error,
+		Time:       time.Now().Format(time.RFC3339),
+	}
+}
+
+// IsFinalEvent reports whether event is the last one a subscriber receives:
+// the status event of a completed or cancelled task, or a dropped event.
+func IsFinalEvent(event model.TaskEvent) bool {
+	if event.Type == eventTypeDropped {
+		return true
+	}
+	return event.Type == eventTypeStatus && (event.Status == "completed" || event.Status == "cancelled")
+}
+
+func (s *ExtractionService) publishStatus(task *Task) {
+	s.events.publish(StatusEvent(task))
+}
+
+// publishStage reports a file's stage. result and err are set for the
+// exported and failed stages.
+func (s *ExtractionService) publishStage(task *Task, index int, stage string, result *model.ExtractionResult, err error) {
+	event := StatusEvent(task)
+	event.Type = eventTypeStage
+	event.ResultPath = ""
+	event.Error = ""
+	event.FileIndex = &index
+	event.FileName = filepath.Base(task.Files[index].Paths[0])
+	event.Stage = stage
+	event.Result = result
+	if err != nil {
+		event.Error = err.Error()
+		event.ErrorCategory = errorCategory(err)
+	}
+	s.events.publish(event)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"contract-key-extractor/internal/model"
+	"testing"
+)
+
+func TestEventBusDropsSlowSubscriber(t *testing.T) {
+	b := newEventBus()
+	slow, _ := b.subscribe("task")
+	fast, unsubscribe := b.subscribe("task")
+	defer unsubscribe()
+
+	for i := 0; i < eventBufferSize+3; i++ {
+		b.publish(model.TaskEvent{Type: eventTypeStage, TaskID: "task", Processed: i})
+		<-fast
+	}
+
+	for i := 0; i < eventBufferSize; i++ {
+		if event := <-slow; event.Processed != i {
+			t.Fatalf("event %d has processed %d", i, event.Processed)
+		}
+	}
+	last, ok := <-slow
+	if !ok || last.Type != eventTypeDropped || !IsFinalEvent(last) {
+		t.Fatalf("last event = %+v (%v), want a final dropped event", last, ok)
+	}
+	if _, ok := <-slow; ok {
+		t.Fatal("channel still open after the dropped event")
+	}
+
+	// The subscriber keeping up is unaffected.
+	b.publish(model.TaskEvent{Type: eventTypeStage, TaskID: "task"})
+	if event := <-fast; event.Type != eventTypeStage {
+		t.Errorf("fast subscriber got %+v", event)
+	}
+	if n := len(b.subs["task"]); n != 1 {
+		t.Errorf("%d subscribers left, want 1", n)
+	}
+}
+
+func TestEventBusUnsubscribe(t *testing.T) {
+	b := newEventBus()
+	first, unsubscribeFirst := b.subscribe("task")
+	_, unsubscribeSecond := b.subscribe("task")
+
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Error("channel still open after unsubscribe")
+	}
+	if n := len(b.subs["task"]); n != 1 {
+		t.Errorf("%d subscribers left, want 1", n)
+	}
+
+	unsubscribeSecond()
+	// Unsubscribing twice, and publishing to no one, are harmless.
+	unsubscribeSecond()
+	b.publish(model.TaskEvent{Type: eventTypeStatus, TaskID: "task"})
+	if len(b.subs) != 0 {
+		t.Errorf("bus still holds %v", b.subs)
+	}
+}
+
+func TestIsFinalEvent(t *testing.T) {
+	for _, tc := range []struct {
+		event model.TaskEvent
+		want  bool
+	}{
+		{model.TaskEvent{Type: eventTypeStatus, Status: "processing"}, false},
+		{model.TaskEvent{Type: eventTypeStatus, Status: "paused"}, false},
+		{model.TaskEvent{Type: eventTypeStatus, Status: "completed"}, true},
+		{model.TaskEvent{Type: eventTypeStatus, Status: "cancelled"}, true},
+		{model.TaskEvent{Type: eventTypeStage, Status: "completed", Stage: stageExported}, false},
+		{model.TaskEvent{Type: eventTypeDropped}, true},
+	} {
+		if got := IsFinalEvent(tc.event); got != tc.want {
+			t.Errorf("IsFinalEvent(%s %s) = %v, want %v", tc.event.Type, tc.event.Status, got, tc.want)
+		}
+	}
+}
@@ -1,14 +1,17 @@
 package service
 
 import (
+	"context"
 	"contract-key-extractor/internal/config"
 	"contract-key-extractor/internal/model"
 	"contract-key-extractor/internal/parser"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,108 +22,487 @@ import (
 type ExtractionService struct {
 	parserManager *parser.ParserManager
 	aiClient      *AIServiceClient
+	extractor     Extractor
 	cfg           *config.Config
 	logger        *zap.Logger
-	tasks         sync.Map
+	store         TaskStore
+	queue         *taskQueue
+	events        *eventBus
+	webhooks      *webhookSender
 }
 
 type Task struct {
-	ID          string
-	Status      string
-	Progress    float64
-	TotalFiles  int
-	Processed   int
-	Failed      int
-	ResultPath  string
-	Error       string
-	CreatedAt   time.Time
-	CompletedAt time.Time
-	Results     []model.ExtractionResult
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Progress    float64    `json:"progress"`
+	TotalFiles  int        `json:"total_files"`
+	Processed   int        `json:"processed"`
+	Failed      int        `json:"failed"`
+	ResultPath  string     `json:"result_path,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt time.Time  `json:"completed_at"`
+	Files       []TaskFile `json:"files"`
+	// CallbackURL is notified about the task in addition to the
+	// configured webhooks.
+	CallbackURL string `json:"callback_url,omitempty"`
+
+	// QueuePosition is the task's place in the worker queue, set by
+	// GetTaskStatus; 0 when none of its files are waiting.
+	QueuePosition int `json:"-"`
+}
+
+// TaskFile is one unit of work within a task and produces at most one
+// result, stored under the file's index. Status is "pending" until the file
+// has been processed, then "completed", "failed" or "cancelled". The
+// remaining fields record how it was processed.
+type TaskFile struct {
+	Paths   []string `json:"paths"`
+	Archive string   `json:"archive,omitempty"`
+	Group   string   `json:"group,omitempty"`
+	Status  string   `json:"status"`
+	Error   string   `json:"error,omitempty"`
+
+	Parser        string               `json:"parser,omitempty"`
+	OCRUsed       bool                 `json:"ocr_used,omitempty"`
+	ErrorCategory model.ErrorCategory  `json:"error_category,omitempty"`
+	Durations     model.StageDurations `json:"durations"`
+	StartedAt     time.Time            `json:"started_at"`
+	CompletedAt   time.Time            `json:"completed_at"`
+}
+
+// stageError is a file failure tagged with the stage it happened in.
+type stageError struct {
+	category model.ErrorCategory
+	err      error
+}
+
+func (e *stageError) Error() string { return e.err.Error() }
+
+func (e *stageError) Unwrap() error { return e.err }
+
+func failedAt(category model.ErrorCategory, err error) error {
+	return &stageError{category: category, err: err}
+}
+
+func errorCategory(err error) model.ErrorCategory {
+	var stageErr *stageError
+	if errors.As(err, &stageErr) {
+		return stageErr.category
+	}
+	return ""
 }
 
+// NewExtractionService creates the service and starts its workers, which
+// process the files of all tasks, cfg.Worker.Workers at a time.
 func NewExtractionService(
 	parserManager *parser.ParserManager,
 	aiClient *AIServiceClient,
+	extractor Extractor,
+	store TaskStore,
 	cfg *config.Config,
 	logger *zap.Logger,
 ) *ExtractionService {
-	return &ExtractionService{
+	workers := cfg.Worker.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	maxQueued := cfg.Worker.MaxQueuedFiles
+	if maxQueued <= 0 {
+		maxQueued = defaultMaxQueuedFiles
+	}
+
+	s := &ExtractionService{
 		parserManager: parserManager,
 		aiClient:      aiClient,
+		extractor:     extractor,
 		cfg:           cfg,
 		logger:        logger,
+		store:         store,
+		queue:         newTaskQueue(maxQueued),
+		events:        newEventBus(),
+		webhooks:      newWebhookSender(&cfg.Webhook, store, logger),
 	}
+	for i := 0; i < workers; i++ {
+		go s.worker()
+	}
+	return s
+}
+
+// fileUnit is the input for one result: a single document, or the pages of
+// an image set. Documents extracted from an uploaded archive carry the
+// archive name and their folder inside it; an archive that could not be
+// expanded becomes a unit holding only the error.
+type fileUnit struct {
+	paths   []string
+	archive string
+	group   string
+	err     error
 }
 
-func (s *ExtractionService) ProcessFiles(filePaths []string) (*Task, error) {
+// ProcessFiles starts a task over the uploaded files. Archives are expanded
+// and each document inside becomes a result of its own. When imageSet is
+// true, all image files in the same folder are treated as the pages of a
+// single contract, in upload order, and produce one result. A non-empty
+// callbackURL receives the task's webhooks.
+//
+// It fails with ErrQueueFull when the workers are too far behind to accept
+// the task's files. The task returned is a copy taken as it was queued; the
+// workers update their own.
+func (s *ExtractionService) ProcessFiles(filePaths []string, imageSet bool, callbackURL string) (task *Task, err error) {
+	files, extractDirs := s.expandUploads(filePaths)
+	defer func() {
+		if err != nil {
+			for _, dir := range extractDirs {
+				os.RemoveAll(dir)
+			}
+		}
+	}()
+	units := groupFiles(files, imageSet)
+
 	taskID := uuid.New().String()
-	task := &Task{
-		ID:         taskID,
-		Status:     "pending",
-		Progress:   0,
-		TotalFiles: len(filePaths),
-		CreatedAt:  time.Now(),
+	task = &Task{
+		ID:          taskID,
+		Status:      "pending",
+		Progress:    0,
+		TotalFiles:  len(units),
+		CreatedAt:   time.Now(),
+		Files:       make([]TaskFile, len(units)),
+		CallbackURL: callbackURL,
 	}
 
-	s.tasks.Store(taskID, task)
+	for i, unit := range units {
+		task.Files[i] = TaskFile{
+			Paths:   unit.paths,
+			Archive: unit.archive,
+			Group:   unit.group,
+			Status:  "pending",
+		}
+		if unit.err != nil {
+			task.Files[i].Status = "failed"
+			task.Files[i].Error = unit.err.Error()
+			task.Files[i].ErrorCategory = model.ErrorCategoryArchive
+			task.Failed++
+			task.Processed++
+		}
+	}
+	if task.TotalFiles > 0 {
+		task.Progress = float64(task.Processed) / float64(task.TotalFiles) * 100
+	}
 
-	go s.processTask(task, filePaths)
+	run := newTaskRun(task)
+	if err := s.queue.reserve(len(run.files)); err != nil {
+		return nil, err
+	}
+	defer s.queue.release(len(run.files))
 
-	return task, nil
+	if err := s.store.SaveTask(task); err != nil {
+		return nil, fmt.Errorf("failed to save task: %w", err)
+	}
+	queued := *task
+	queued.Files = slices.Clone(task.Files)
+	s.enqueue(run)
+
+	return &queued, nil
+}
+
+// RecoverTasks resumes the tasks that were still pending or processing when
+// the server stopped. Files that had not finished are processed again;
+// finished files keep their stored results. Paused tasks stay paused.
+// Webhook deliveries that were still due are resumed for every task.
+func (s *ExtractionService) RecoverTasks() error {
+	tasks, err := s.store.ListTasks()
+	if err != nil {
+		return fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	for _, task := range tasks {
+		s.resumeWebhooks(task.ID)
+		if task.Status != "pending" && task.Status != "processing" && task.Status != "paused" {
+			continue
+		}
+
+		run := newTaskRun(task)
+		run.paused = task.Status == "paused"
+		s.logger.Info("Recovering task",
+			zap.String("task_id", task.ID),
+			zap.String("status", task.Status),
+			zap.Int("remaining_files", len(run.files)),
+		)
+		s.enqueue(run)
+	}
+	return nil
+}
+
+// resumeWebhooks restarts the task's webhook deliveries that were still due
+// when the server stopped.
+func (s *ExtractionService) resumeWebhooks(taskID string) {
+	deliveries, err := s.store.GetDeliveries(taskID)
+	if err != nil {
+		s.logger.Warn("failed to load webhook deliveries",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+		return
+	}
+	if n := s.webhooks.resume(deliveries); n > 0 {
+		s.logger.Info("Resuming webhook deliveries",
+			zap.String("task_id", taskID),
+			zap.Int("deliveries", n),
+		)
+	}
 }
 
-func (s *ExtractionService) processTask(task *Task, filePaths []string) {
-	task.Status = "processing"
-	s.tasks.Store(task.ID, task)
+// Close stops delivering webhooks, waiting for attempts under way. Files
+// still being processed are picked up again by RecoverTasks after a
+// restart, as are webhook deliveries that are still due.
+func (s *ExtractionService) Close() {
+	s.webhooks.close()
+}
 
-	var results []model.ExtractionResult
-	var mu sync.Mutex
+// enqueue hands the run's pending files to the workers. A task with nothing
+// left to process is finished straight away.
+func (s *ExtractionService) enqueue(run *taskRun) {
+	if len(run.files) == 0 {
+		go s.finishTask(run)
+		return
+	}
+	s.queue.push(run)
+}
 
+// expandUploads replaces each archive in filePaths with the documents it
+// contains, one unit per file. It also returns the directories the archives
+// were extracted to.
+func (s *ExtractionService) expandUploads(filePaths []string) ([]fileUnit, []string) {
+	var files []fileUnit
+	var dirs []string
 	for _, filePath := range filePaths {
-		result, err := s.processSingleFile(filePath)
+		if !isArchiveFile(filePath) {
+			files = append(files, fileUnit{paths: []string{filePath}})
+			continue
+		}
+
+		archive := filepath.Base(filePath)
+		dir, extracted, err := s.expandArchive(filePath)
 		if err != nil {
-			s.logger.Error("failed to process file",
-				zap.String("file", filePath),
+			s.logger.Error("failed to expand archive",
+				zap.String("archive", filePath),
 				zap.Error(err),
 			)
-			task.Failed++
-		} else {
-			mu.Lock()
-			results = append(results, *result)
-			mu.Unlock()
+			files = append(files, fileUnit{paths: []string{filePath}, archive: archive, err: err})
+			continue
 		}
 
-		task.Processed++
-		task.Progress = float64(task.Processed) / float64(task.TotalFiles) * 100
-		s.tasks.Store(task.ID, task)
+		dirs = append(dirs, dir)
+		s.logger.Info("Expanded archive",
+			zap.String("archive", filePath),
+			zap.Int("documents", len(extracted)),
+		)
+		for _, file := range extracted {
+			files = append(files, fileUnit{paths: []string{file.path}, archive: archive, group: file.group})
+		}
 	}
+	return files, dirs
+}
 
-	task.Results = results
-	task.Status = "completed"
-	task.CompletedAt = time.Now()
+func groupFiles(files []fileUnit, imageSet bool) []fileUnit {
+	var units []fileUnit
+	imageUnits := map[string]int{}
+	for _, file := range files {
+		if imageSet && file.err == nil && parser.IsImageFile(file.paths[0]) {
+			key := file.archive + "\x00" + file.group
+			if i, ok := imageUnits[key]; ok {
+				units[i].paths = append(units[i].paths, file.paths[0])
+				continue
+			}
+			imageUnits[key] = len(units)
+		}
+		units = append(units, file)
+	}
+	return units
+}
+
+// worker processes queued files one at a time. While the AI service is down
+// it stops taking files, so they wait in the queue rather than fail.
+func (s *ExtractionService) worker() {
+	for {
+		s.aiClient.waitAvailable(context.Background())
+		run, index := s.queue.next()
+		s.processTaskFile(run, index)
+		if s.queue.done(run) {
+			s.finishTask(run)
+		}
+	}
+}
+
+// processTaskFile processes the file at index within the run's task. The
+// result is stored before the file is marked completed, so a file
+// interrupted by a restart is simply processed again. A file interrupted by
+// cancelling the task is left cancelled.
+func (s *ExtractionService) processTaskFile(run *taskRun, index int) {
+	run.mu.Lock()
+	task := run.task
+	if run.ctx.Err() != nil {
+		run.mu.Unlock()
+		return
+	}
+	if task.Status == "pending" {
+		task.Status = "processing"
+		s.saveTask(task)
+		s.publishStatus(task)
+	}
+	file := task.Files[index]
+	run.mu.Unlock()
+
+	report := func(stage string) {
+		run.mu.Lock()
+		defer run.mu.Unlock()
+		s.publishStage(task, index, stage, nil, nil)
+	}
+
+	file.Parser = ""
+	file.OCRUsed = false
+	file.Durations = model.StageDurations{}
+	file.StartedAt = time.Now()
+	ctx := withRequestID(run.ctx, fmt.Sprintf("%s-%d", task.ID, index))
+	result, err := s.processFileSafely(ctx, &file, report)
+	if err == nil {
+		if err = s.store.SaveResult(task.ID, index, result); err != nil {
+			err = failedAt(model.ErrorCategoryStorage, fmt.Errorf("failed to save result: %w", err))
+		}
+	}
+	file.CompletedAt = time.Now()
+	file.Durations.Total = file.CompletedAt.Sub(file.StartedAt).Seconds()
 
-	outputPath, err := s.exportResults(results, task.ID)
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	if err != nil && run.ctx.Err() != nil {
+		return
+	}
 	if err != nil {
-		task.Error = fmt.Sprintf("failed to export results: %v", err)
+		s.logger.Error("failed to process file",
+			zap.Strings("files", file.Paths),
+			zap.Error(err),
+		)
+		file.Status = "failed"
+		file.Error = err.Error()
+		file.ErrorCategory = errorCategory(err)
+		task.Failed++
+	} else {
+		file.Status = "completed"
+	}
+	task.Files[index] = file
+
+	task.Processed++
+	task.Progress = float64(task.Processed) / float64(task.TotalFiles) * 100
+	s.saveTask(task)
+
+	if err != nil {
+		s.publishStage(task, index, stageFailed, nil, err)
 	} else {
+		s.publishStage(task, index, stageExported, result, nil)
+	}
+	s.notifyFileDone(task, index, result, err)
+}
+
+// finishTask exports the results of a task whose files have all been
+// processed. A cancelled task is only marked finished; its stored results
+// remain available but no workbook is produced.
+func (s *ExtractionService) finishTask(run *taskRun) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	defer run.cancel()
+
+	task := run.task
+	task.CompletedAt = time.Now()
+	if task.Status == "cancelled" {
+		s.saveTask(task)
+		s.publishStatus(task)
+		s.notifyTaskFinished(task)
+		return
+	}
+	task.Status = "completed"
+
+	results, err := s.store.GetResults(task.ID)
+	if err == nil {
+		var outputPath string
+		outputPath, err = s.exportResults(task, results)
 		task.ResultPath = outputPath
 	}
+	if err != nil {
+		task.Error = fmt.Sprintf("failed to export results: %v", err)
+	}
 
-	s.tasks.Store(task.ID, task)
+	s.saveTask(task)
+	s.publishStatus(task)
+	s.notifyTaskFinished(task)
 }
 
-func (s *ExtractionService) processSingleFile(filePath string) (*model.ExtractionResult, error) {
-	startTime := time.Now()
+// saveTask stores the task's progress. A failed write is logged rather than
+// stopping the task; the next save writes the whole task again.
+func (s *ExtractionService) saveTask(task *Task) {
+	if err := s.store.SaveTask(task); err != nil {
+		s.logger.Error("failed to save task",
+			zap.String("task_id", task.ID),
+			zap.Error(err),
+		)
+	}
+}
 
-	data, err := os.ReadFile(filePath)
+// processFileSafely runs processFile, turning a panic into a failure of the
+// file so that one bad upload cannot take the server down.
+func (s *ExtractionService) processFileSafely(ctx context.Context, file *TaskFile, report stageFunc) (result *model.ExtractionResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error("panic while processing file",
+				zap.Strings("files", file.Paths),
+				zap.Any("panic", r),
+				zap.Stack("stack"),
+			)
+			result, err = nil, failedAt(model.ErrorCategoryParse, fmt.Errorf("internal error while processing file: %v", r))
+		}
+	}()
+	return s.processFile(ctx, file, report)
+}
+
+// processFile extracts the contract in file, recording the parser, OCR use
+// and stage durations in it. Errors are tagged with the stage that failed.
+func (s *ExtractionService) processFile(ctx context.Context, file *TaskFile, report stageFunc) (*model.ExtractionResult, error) {
+	var (
+		result *model.ExtractionResult
+		err    error
+	)
+	if len(file.Paths) > 1 {
+		result, err = s.processImageSet(ctx, file, report)
+	} else {
+		result, err = s.processSingleFile(ctx, file, report)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
 
-	doc, err := s.parserManager.Parse(filePath, data)
+	result.Metadata.Archive = file.Archive
+	result.Metadata.Group = file.Group
+	return result, nil
+}
+
+func (s *ExtractionService) processSingleFile(ctx context.Context, file *TaskFile, report stageFunc) (*model.ExtractionResult, error) {
+	startTime := time.Now()
+	filePath := file.Paths[0]
+
+	report(stageParsing)
+	doc, err := s.parserManager.ParseFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse document: %w", err)
+		category := model.ErrorCategoryParse
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			category = model.ErrorCategoryRead
+		}
+		return nil, failedAt(category, fmt.Errorf("failed to parse document: %w", err))
 	}
+	file.Parser = doc.Parser
+	file.Durations.Parse = time.Since(startTime).Seconds()
 
 	s.logger.Info("Parsed document",
 		zap.String("file", filePath),
@@ -129,11 +511,12 @@ func (s *ExtractionService) processSingleFile(filePath string) (*model.Extractio
 		zap.Int("contentLen", len(doc.Content)),
 	)
 
-	var aiResp *model.AIExtractionResponse
-
-	if doc.FileType == model.FileTypePDF {
+	ocrStart := time.Now()
+	if doc.FileType == model.FileTypePDF && doc.IsScanned {
+		file.OCRUsed = true
+		report(stageOCR)
 		s.logger.Info("Calling PDF OCR", zap.String("file", filePath))
-		pdfText, err := s.aiClient.PerformPDFOCR(data)
+		pdfText, err := s.performPDFOCR(ctx, filePath, doc.PageCount)
 		if err != nil {
 			s.logger.Warn("PDF OCR failed",
 				zap.String("file", filePath),
@@ -145,22 +528,95 @@ func (s *ExtractionService) processSingleFile(filePath string) (*model.Extractio
 			doc.Content = pdfText
 			doc.IsScanned = false
 		}
+		parser.BuildLayout(doc)
+	} else if doc.FileType == model.FileTypePDF {
+		if scanned := parser.ScannedPages(doc); len(scanned) > 0 {
+			file.OCRUsed = true
+			report(stageOCR)
+			s.ocrPDFPages(ctx, filePath, doc, scanned)
+		}
+	} else if doc.FileType == model.FileTypeImage {
+		file.OCRUsed = true
+		report(stageOCR)
+		images, release, err := openImagePages(filePath)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		s.ocrImagePages(ctx, doc, images, repeatName(filepath.Base(filePath), len(images)))
 	} else if doc.IsScanned {
-		ocrText, err := s.aiClient.PerformOCR(data)
+		file.OCRUsed = true
+		report(stageOCR)
+		ocrResult, err := s.performOCR(ctx, filePath)
 		if err != nil {
 			s.logger.Warn("OCR failed, using original content",
 				zap.String("file", filePath),
 				zap.Error(err),
 			)
 		} else {
-			doc.Content = ocrText
+			doc.Content = ocrResult.Text
+			parser.BuildLayout(doc)
+		}
+	}
+	if file.OCRUsed {
+		file.Durations.OCR = time.Since(ocrStart).Seconds()
+	}
+
+	return s.buildResult(ctx, file, report, filePath, doc, startTime)
+}
+
+func (s *ExtractionService) processImageSet(ctx context.Context, file *TaskFile, report stageFunc) (*model.ExtractionResult, error) {
+	startTime := time.Now()
+	filePaths := file.Paths
+
+	report(stageParsing)
+	var images []parser.ImagePage
+	var names []string
+	for _, filePath := range filePaths {
+		pages, release, err := openImagePages(filePath)
+		if err != nil {
+			return nil, err
 		}
+		defer release()
+		images = append(images, pages...)
+		names = append(names, repeatName(filepath.Base(filePath), len(pages))...)
 	}
 
-	aiResp, err = s.aiClient.ExtractContractInfo(doc)
+	doc := parser.NewImageDocument(filepath.Base(filePaths[0]), len(images))
+	file.Parser = doc.Parser
+	file.Durations.Parse = time.Since(startTime).Seconds()
+
+	s.logger.Info("Parsed image set",
+		zap.Strings("files", filePaths),
+		zap.Int("pages", len(images)),
+	)
+
+	report(stageOCR)
+	ocrStart := time.Now()
+	file.OCRUsed = true
+	s.ocrImagePages(ctx, doc, images, names)
+	file.Durations.OCR = time.Since(ocrStart).Seconds()
+
+	return s.buildResult(ctx, file, report, filePaths[0], doc, startTime)
+}
+
+func (s *ExtractionService) buildResult(ctx context.Context, file *TaskFile, report stageFunc, filePath string, doc *model.ParsedDocument, startTime time.Time) (*model.ExtractionResult, error) {
+	report(stageExtracting)
+	extractStart := time.Now()
+	aiResp, err := s.extractor.ExtractContractInfo(ctx, doc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract contract info: %w", err)
+		return nil, failedAt(model.ErrorCategoryExtraction, fmt.Errorf("failed to extract contract info: %w", err))
+	}
+
+	unverified := verifySourceRefs(doc, aiResp)
+	if unverified > 0 {
+		s.logger.Warn("source references not found in document",
+			zap.String("file", filePath),
+			zap.Int("count", unverified),
+		)
+		doc.Warnings = append(doc.Warnings, fmt.Sprintf("%d source references could not be found in the document", unverified))
 	}
+	file.Durations.Extract = time.Since(extractStart).Seconds()
 
 	result := &model.ExtractionResult{
 		ID:                uuid.New().String(),
@@ -184,12 +640,155 @@ func (s *ExtractionService) processSingleFile(filePath string) (*model.Extractio
 			ProcessingDuration: time.Since(startTime).Seconds(),
 			OverallConfidence:  s.calculateOverallConfidence(aiResp),
 			OCRRequired:        doc.IsScanned,
+			Comments:           doc.Comments,
+			RevisionAuthors:    revisionAuthors(doc.Revisions),
+			Warnings:           doc.Warnings,
+			Parser:             doc.Parser,
+			UnverifiedRefs:     unverified,
 		},
 	}
 
 	return result, nil
 }
 
+// performPDFOCR streams a whole PDF to the AI service for OCR.
+func (s *ExtractionService) performPDFOCR(ctx context.Context, filePath string, pages int) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return s.aiClient.PerformPDFOCR(ctx, file, pages)
+}
+
+// performOCR streams a single-image file to the AI service for OCR.
+func (s *ExtractionService) performOCR(ctx context.Context, filePath string) (*model.OCRResponse, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return s.aiClient.PerformOCR(ctx, filepath.Base(filePath), file)
+}
+
+func (s *ExtractionService) ocrPDFPages(ctx context.Context, filePath string, doc *model.ParsedDocument, pages []int) {
+	s.logger.Info("Calling PDF OCR for image-only pages",
+		zap.String("file", filePath),
+		zap.Ints("pages", pages),
+	)
+
+	var results map[int]model.OCRPage
+	file, err := os.Open(filePath)
+	if err == nil {
+		results, err = s.aiClient.PerformPDFPageOCR(ctx, file, pages)
+		file.Close()
+	}
+	if err != nil {
+		s.logger.Warn("PDF page OCR failed, keeping text layer only",
+			zap.String("file", filePath),
+			zap.Error(err),
+		)
+	}
+
+	for i := range doc.Pages {
+		page := &doc.Pages[i]
+		if !page.IsScanned {
+			continue
+		}
+		// The recognised text replaces the page's text layer, positions
+		// included.
+		page.Lines = nil
+		if result, ok := results[page.Number]; ok && !result.Failed {
+			page.Content = result.Text
+			page.OCRConfidence = result.Confidence
+		} else {
+			page.OCRFailed = true
+		}
+	}
+
+	parser.MergePages(doc)
+}
+
+// openImagePages opens the pages of an image file for OCR. release must be
+// called once they have been sent.
+func openImagePages(filePath string) ([]parser.ImagePage, func(), error) {
+	pages, release, err := parser.OpenImagePages(filePath)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			return nil, nil, failedAt(model.ErrorCategoryRead, fmt.Errorf("failed to read file: %w", err))
+		}
+		return nil, nil, failedAt(model.ErrorCategoryParse, fmt.Errorf("failed to read image %s: %w", filepath.Base(filePath), err))
+	}
+	return pages, release, nil
+}
+
+// ocrImagePages recognises each page image in turn. names holds the source
+// file name of every image and is sent along as the multipart filename.
+func (s *ExtractionService) ocrImagePages(ctx context.Context, doc *model.ParsedDocument, images []parser.ImagePage, names []string) {
+	s.logger.Info("Calling OCR for image pages",
+		zap.String("file", doc.FileName),
+		zap.Int("pages", len(images)),
+	)
+
+	for i := range doc.Pages {
+		page := &doc.Pages[i]
+		if i >= len(images) || ctx.Err() != nil {
+			page.OCRFailed = true
+			continue
+		}
+		image, err := images[i]()
+		if err != nil {
+			s.logger.Warn("failed to read image page",
+				zap.String("file", names[i]),
+				zap.Int("page", page.Number),
+				zap.Error(err),
+			)
+			page.OCRFailed = true
+			continue
+		}
+		result, err := s.aiClient.PerformOCR(ctx, names[i], image)
+		if err != nil {
+			s.logger.Warn("image OCR failed",
+				zap.String("file", names[i]),
+				zap.Int("page", page.Number),
+				zap.Error(err),
+			)
+			page.OCRFailed = true
+			continue
+		}
+		page.Content = result.Text
+		if len(result.Pages) > 0 {
+			page.OCRConfidence = result.Pages[0].Confidence
+		}
+	}
+
+	parser.MergePages(doc)
+}
+
+func repeatName(name string, n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = name
+	}
+	return names
+}
+
+func revisionAuthors(revisions []model.DocumentRevision) []string {
+	var authors []string
+	seen := map[string]bool{}
+	for _, revision := range revisions {
+		if revision.Author == "" || seen[revision.Author] {
+			continue
+		}
+		seen[revision.Author] = true
+		authors = append(authors, revision.Author)
+	}
+	return authors
+}
+
 func (s *ExtractionService) calculateOverallConfidence(resp *model.AIExtractionResponse) float64 {
 	confidences := []float64{
 		resp.ContractInfo.Confidence,
@@ -220,18 +819,18 @@ func (s *ExtractionService) calculateOverallConfidence(resp *model.AIExtractionR
 	return sum / float64(count)
 }
 
-func (s *ExtractionService) exportResults(results []model.ExtractionResult, taskID string) (string, error) {
+func (s *ExtractionService) exportResults(task *Task, results []model.ExtractionResult) (string, error) {
 	outputDir := s.cfg.Output.Path
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	outputPath := filepath.Join(outputDir, fmt.Sprintf("extraction_result_%s.xlsx", taskID))
+	outputPath := filepath.Join(outputDir, fmt.Sprintf("extraction_result_%s.xlsx", task.ID))
 
-	return s.exportToExcel(results, outputPath)
+	return s.exportToExcel(results, task.Files, outputPath)
 }
 
-func (s *ExtractionService) exportToExcel(results []model.ExtractionResult, outputPath string) (string, error) {
+func (s *ExtractionService) exportToExcel(results []model.ExtractionResult, files []TaskFile, outputPath string) (string, error) {
 	f := excelize.NewFile()
 	defer f.Close()
 
@@ -250,7 +849,7 @@ func (s *ExtractionService) exportToExcel(results []model.ExtractionResult, outp
 		"保密条款", "知识产权归属",
 		"变更条款", "转让条款", "合同份数",
 		"甲方盖章", "乙方盖章",
-		"置信度", "处理时间",
+		"置信度", "处理时间", "来源压缩包", "分组",
 	}
 
 	headerStyle, _ := f.NewStyle(&excelize.Style{
@@ -330,6 +929,8 @@ func (s *ExtractionService) exportToExcel(results []model.ExtractionResult, outp
 			fmt.Sprintf("%v", result.Signature.PartyBSeal),
 			fmt.Sprintf("%.1f%%", result.Metadata.OverallConfidence*100),
 			fmt.Sprintf("%.2fs", result.Metadata.ProcessingDuration),
+			result.Metadata.Archive,
+			result.Metadata.Group,
 		}
 
 		for col, value := range data {
@@ -351,6 +952,7 @@ func (s *ExtractionService) exportToExcel(results []model.ExtractionResult, outp
 	f.SetColWidth(sheetName, "AF", "AJ", 15)
 	f.SetColWidth(sheetName, "AK", "AM", 20)
 	f.SetColWidth(sheetName, "AN", "AP", 10)
+	f.SetColWidth(sheetName, "AS", "AT", 20)
 
 	if len(results) > 0 {
 		f.SetRowHeight(sheetName, 1, 30)
@@ -359,6 +961,8 @@ func (s *ExtractionService) exportToExcel(results []model.ExtractionResult, outp
 		}
 	}
 
+	writeFileSheet(f, files, headerStyle, cellStyle)
+
 	if err := f.SaveAs(outputPath); err != nil {
 		return "", fmt.Errorf("failed to save excel file: %w", err)
 	}
@@ -367,17 +971,198 @@ func (s *ExtractionService) exportToExcel(results []model.ExtractionResult, outp
 }
 
 func (s *ExtractionService) GetTaskStatus(taskID string) (*Task, error) {
-	value, ok := s.tasks.Load(taskID)
-	if !ok {
-		return nil, fmt.Errorf("task not found: %s", taskID)
+	task, err := s.store.GetTask(taskID)
+	if err != nil {
+		return nil, err
 	}
-	return value.(*Task), nil
+	task.QueuePosition = s.queue.position(taskID)
+	return task, nil
 }
 
 func (s *ExtractionService) GetTaskResults(taskID string) ([]model.ExtractionResult, error) {
-	task, err := s.GetTaskStatus(taskID)
+	return s.store.GetResults(taskID)
+}
+
+// GetWebhookDeliveries returns the log of a task's webhook deliveries.
+func (s *ExtractionService) GetWebhookDeliveries(taskID string) ([]model.WebhookDelivery, error) {
+	return s.store.GetDeliveries(taskID)
+}
+
+// CancelTask stops a task. Files not yet started are marked cancelled and
+// files in progress are interrupted, including their AI service calls.
+// Results already stored are kept. Cancelling a cancelled task does nothing.
+func (s *ExtractionService) CancelTask(taskID string) error {
+	run, err := s.activeRun(taskID, "cancelled")
+	if err != nil || run == nil {
+		return err
+	}
+
+	run.mu.Lock()
+	idle, ok := s.queue.cancel(run)
+	if !ok {
+		run.mu.Unlock()
+		return ErrTaskFinished
+	}
+	run.cancel()
+
+	task := run.task
+	task.Status = "cancelled"
+	for i := range task.Files {
+		if task.Files[i].Status == "pending" {
+			task.Files[i].Status = "cancelled"
+		}
+	}
+	s.saveTask(task)
+	s.publishStatus(task)
+	run.mu.Unlock()
+
+	s.logger.Info("Cancelled task", zap.String("task_id", taskID))
+	if idle {
+		s.finishTask(run)
+	}
+	return nil
+}
+
+// PauseTask stops handing the task's files to workers. Files already in
+// progress are left to finish, so no AI service call is wasted.
+func (s *ExtractionService) PauseTask(taskID string) error {
+	run, err := s.activeRun(taskID, "paused")
+	if err != nil || run == nil {
+		return err
+	}
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	if run.task.Status == "cancelled" {
+		return ErrTaskFinished
+	}
+	if !s.queue.pause(run) {
+		return ErrTaskFinished
+	}
+	run.task.Status = "paused"
+	s.saveTask(run.task)
+	s.publishStatus(run.task)
+
+	s.logger.Info("Paused task", zap.String("task_id", taskID))
+	return nil
+}
+
+// ResumeTask puts a paused task back in the queue. Resuming a task that is
+// not paused does nothing.
+func (s *ExtractionService) ResumeTask(taskID string) error {
+	run, err := s.activeRun(taskID, "")
+	if err != nil || run == nil {
+		return err
+	}
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	task := run.task
+	if task.Status != "paused" {
+		if task.Status == "cancelled" {
+			return ErrTaskFinished
+		}
+		return nil
+	}
+	if !s.queue.resume(run) {
+		return ErrTaskFinished
+	}
+	task.Status = "pending"
+	if task.Processed > 0 {
+		task.Status = "processing"
+	}
+	s.saveTask(task)
+	s.publishStatus(task)
+
+	s.logger.Info("Resumed task", zap.String("task_id", taskID))
+	return nil
+}
+
+// activeRun returns the run of an unfinished task. A finished task is an
+// ErrTaskFinished error, unless its status is already want, in which case
+// there is nothing to do and both results are nil.
+func (s *ExtractionService) activeRun(taskID, want string) (*taskRun, error) {
+	if run := s.queue.lookup(taskID); run != nil {
+		return run, nil
+	}
+
+	task, err := s.store.GetTask(taskID)
 	if err != nil {
 		return nil, err
 	}
-	return task.Results, nil
+	if want != "" && task.Status == want {
+		return nil, nil
+	}
+	return nil, ErrTaskFinished
+}
+
+var fileStatusLabels = map[string]string{
+	"pending":   "未处理",
+	"completed": "成功",
+	"failed":    "失败",
+	"cancelled": "已取消",
+}
+
+// writeFileSheet adds a sheet listing every file of the task, including the
+// ones that produced no result, with how each was processed.
+func writeFileSheet(f *excelize.File, files []TaskFile, headerStyle, cellStyle int) {
+	sheetName := "处理明细"
+	f.NewSheet(sheetName)
+
+	headers := []string{
+		"序号", "文件名", "来源压缩包", "分组", "状态", "解析器", "使用OCR",
+		"错误类型", "错误信息", "解析耗时", "OCR耗时", "提取耗时", "总耗时",
+	}
+	for col, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
+		f.SetCellValue(sheetName, cell, header)
+		f.SetCellStyle(sheetName, cell, cell, headerStyle)
+	}
+
+	for row, file := range files {
+		status := fileStatusLabels[file.Status]
+		if status == "" {
+			status = file.Status
+		}
+		ocrUsed := "否"
+		if file.OCRUsed {
+			ocrUsed = "是"
+		}
+
+		names := make([]string, len(file.Paths))
+		for i, path := range file.Paths {
+			names[i] = filepath.Base(path)
+		}
+
+		data := []interface{}{
+			row + 1,
+			strings.Join(names, "\n"),
+			file.Archive,
+			file.Group,
+			status,
+			file.Parser,
+			ocrUsed,
+			string(file.ErrorCategory),
+			file.Error,
+			fmt.Sprintf("%.2fs", file.Durations.Parse),
+			fmt.Sprintf("%.2fs", file.Durations.OCR),
+			fmt.Sprintf("%.2fs", file.Durations.Extract),
+			fmt.Sprintf("%.2fs", file.Durations.Total),
+		}
+
+		for col, value := range data {
+			cell, _ := excelize.CoordinatesToCellName(col+1, row+2)
+			f.SetCellValue(sheetName, cell, value)
+			f.SetCellStyle(sheetName, cell, cell, cellStyle)
+		}
+	}
+
+	f.SetColWidth(sheetName, "A", "A", 6)
+	f.SetColWidth(sheetName, "B", "B", 30)
+	f.SetColWidth(sheetName, "C", "H", 15)
+	f.SetColWidth(sheetName, "I", "I", 50)
+	f.SetColWidth(sheetName, "J", "M", 10)
+	f.SetRowHeight(sheetName, 1, 30)
 }
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"bytes"
+	"contract-key-extractor/internal/config"
+	"contract-key-extractor/internal/parser"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// testTaskAI stubs the AI service for task tests. OCR requests block until
+// release is closed or the request is cancelled.
+type testTaskAI struct {
+	ocrStarted chan struct{}
+	release    chan struct{}
+	ocrCalls   atomic.Int32
+}
+
+func (ai *testTaskAI) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v1/extract" {
+		w.Write([]byte("{}"))
+		return
+	}
+	// The server only notices a cancelled request once the body is read.
+	io.Copy(io.Discard, r.Body)
+	ai.ocrCalls.Add(1)
+	select {
+	case ai.ocrStarted <- struct{}{}:
+	default:
+	}
+	select {
+	case <-ai.release:
+		writeOCRResponse(w, "")
+	case <-r.Context().Done():
+	}
+}
+
+// newTestTaskService returns a service backed by a stub AI service. It has
+// no workers; tests hand out files with work.
+func newTestTaskService(t *testing.T) (*ExtractionService, *testTaskAI) {
+	t.Helper()
+	ai := &testTaskAI{ocrStarted: make(chan struct{}, 1), release: make(chan struct{})}
+	client := newTestAIClient(t, ai.serve)
+	cfg := &config.Config{}
+	cfg.Output.Path = t.TempDir()
+	store := NewMemoryTaskStore()
+	return &ExtractionService{
+		parserManager: parser.NewParserManager(&config.ParserConfig{}, zap.NewNop()),
+		aiClient:      client,
+		extractor:     client,
+		cfg:           cfg,
+		logger:        zap.NewNop(),
+		store:         store,
+		queue:         newTaskQueue(10),
+		events:        newEventBus(),
+		webhooks:      newWebhookSender(&cfg.Webhook, store, zap.NewNop()),
+	}, ai
+}
+
+// work does what a worker does with the next queued file.
+func work(s *ExtractionService) {
+	run, index := s.queue.next()
+	s.processTaskFile(run, index)
+	if s.queue.done(run) {
+		s.finishTask(run)
+	}
+}
+
+// startTestTask uploads n scanned pages as separate contracts.
+func startTestTask(t *testing.T, s *ExtractionService, n int) *Task {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < n; i++ {
+		path := filepath.Join(dir, string(rune('a'+i))+".png")
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	task, err := s.ProcessFiles(paths, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func checkTestTask(t *testing.T, s *ExtractionService, taskID, status string, fileStatuses ...string) *Task {
+	t.Helper()
+	task, err := s.GetTaskStatus(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != status {
+		t.Errorf("status = %s, want %s", task.Status, status)
+	}
+	for i, want := range fileStatuses {
+		if got := task.Files[i].Status; got != want {
+			t.Errorf("file %d status = %s, want %s", i, got, want)
+		}
+	}
+	return task
+}
+
+// checkTestTaskFinished checks that the task is no longer known to the queue
+// and was given a completion time.
+func checkTestTaskFinished(t *testing.T, s *ExtractionService, task *Task) {
+	t.Helper()
+	if s.queue.lookup(task.ID) != nil {
+		t.Error("task is still in the queue")
+	}
+	if task.CompletedAt.IsZero() {
+		t.Error("task has no completion time")
+	}
+}
+
+func TestCancelTaskDuringOCR(t *testing.T) {
+	s, ai := newTestTaskService(t)
+	task := startTestTask(t, s, 1)
+
+	worked := make(chan struct{})
+	go func() {
+		work(s)
+		close(worked)
+	}()
+	select {
+	case <-ai.ocrStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OCR was not called")
+	}
+
+	if err := s.CancelTask(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-worked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the OCR call was not interrupted")
+	}
+
+	task = checkTestTask(t, s, task.ID, "cancelled", "cancelled")
+	checkTestTaskFinished(t, s, task)
+	if task.Processed != 0 || task.ResultPath != "" {
+		t.Errorf("cancelled task processed %d files into %q", task.Processed, task.ResultPath)
+	}
+	if results, _ := s.GetTaskResults(task.ID); len(results) != 0 {
+		t.Errorf("stored %d results", len(results))
+	}
+}
+
+func TestPauseQueuedTask(t *testing.T) {
+	s, ai := newTestTaskService(t)
+	close(ai.release)
+	task := startTestTask(t, s, 2)
+
+	if err := s.PauseTask(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	task = checkTestTask(t, s, task.ID, "paused", "pending", "pending")
+	if task.QueuePosition != 0 || s.queue.queued != 0 {
+		t.Errorf("paused task is at position %d with %d files queued", task.QueuePosition, s.queue.queued)
+	}
+	// Pausing twice is allowed.
+	if err := s.PauseTask(task.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ResumeTask(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	task = checkTestTask(t, s, task.ID, "pending")
+	if task.QueuePosition != 1 {
+		t.Errorf("resumed task is at position %d, want 1", task.QueuePosition)
+	}
+
+	work(s)
+	work(s)
+	task = checkTestTask(t, s, task.ID, "completed", "completed", "completed")
+	checkTestTaskFinished(t, s, task)
+	if got := ai.ocrCalls.Load(); got != 2 {
+		t.Errorf("OCR called %d times, want 2", got)
+	}
+}
+
+func TestResumeAfterCancel(t *testing.T) {
+	for _, pause := range []bool{false, true} {
+		s, ai := newTestTaskService(t)
+		task := startTestTask(t, s, 2)
+
+		if pause {
+			if err := s.PauseTask(task.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.CancelTask(task.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ResumeTask(task.ID); !errors.Is(err, ErrTaskFinished) {
+			t.Errorf("paused %v: resume err = %v, want ErrTaskFinished", pause, err)
+		}
+		if err := s.PauseTask(task.ID); !errors.Is(err, ErrTaskFinished) {
+			t.Errorf("paused %v: pause err = %v, want ErrTaskFinished", pause, err)
+		}
+		// Cancelling again does nothing.
+		if err := s.CancelTask(task.ID); err != nil {
+			t.Errorf("paused %v: second cancel err = %v", pause, err)
+		}
+
+		task = checkTestTask(t, s, task.ID, "cancelled", "cancelled", "cancelled")
+		checkTestTaskFinished(t, s, task)
+		if s.queue.queued != 0 || ai.ocrCalls.Load() != 0 {
+			t.Errorf("paused %v: %d files queued and %d OCR calls after cancel", pause, s.queue.queued, ai.ocrCalls.Load())
+		}
+	}
+}
+
+// A worker may take a file from the queue just before the task is cancelled.
+// The file must then be skipped, and the worker must still finish the task.
+func TestCancelBetweenNextAndProcess(t *testing.T) {
+	s, ai := newTestTaskService(t)
+	task := startTestTask(t, s, 2)
+
+	run, index := s.queue.next()
+	if err := s.CancelTask(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s.queue.lookup(task.ID) != run {
+		t.Fatal("task left the queue with a file in progress")
+	}
+
+	s.processTaskFile(run, index)
+	if !s.queue.done(run) {
+		t.Fatal("done did not report the task's last file")
+	}
+	s.finishTask(run)
+
+	task = checkTestTask(t, s, task.ID, "cancelled", "cancelled", "cancelled")
+	checkTestTaskFinished(t, s, task)
+	if task.Processed != 0 || ai.ocrCalls.Load() != 0 {
+		t.Errorf("processed %d files with %d OCR calls after cancel", task.Processed, ai.ocrCalls.Load())
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"contract-key-extractor/internal/config"
+	"contract-key-extractor/internal/model"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+const (
+	extractorBackendAIService = "ai_service"
+	extractorBackendDirect    = "direct"
+)
+
+// Extractor turns the text of a parsed document into structured contract
+// information.
+type Extractor interface {
+	ExtractContractInfo(ctx context.Context, doc *model.ParsedDocument) (*model.AIExtractionResponse, error)
+}
+
+// NewExtractor returns the extractor selected by cfg.LLM.Backend: the AI
+// service (the default), or an in-process client of the configured LLM.
+func NewExtractor(cfg *config.Config, aiClient *AIServiceClient, logger *zap.Logger) (Extractor, error) {
+	switch cfg.LLM.Backend {
+	case "", extractorBackendAIService:
+		if !aiClient.Enabled() {
+			return nil, fmt.Errorf("llm.backend %q needs ai_service.host to be set", extractorBackendAIService)
+		}
+		return aiClient, nil
+	case extractorBackendDirect:
+		return NewLLMExtractor(&cfg.LLM, logger)
+	default:
+		return nil, fmt.Errorf("unknown llm.backend %q", cfg.LLM.Backend)
+	}
+}
@@ -0,0 +1,535 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"contract-key-extractor/internal/config"
+	"contract-key-extractor/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLLMTimeout = 120 * time.Second
+	// defaultLLMConfidence is assumed for sections the model gives no
+	// confidence for.
+	defaultLLMConfidence = 0.8
+	llmTemperature       = 0.1
+	llmMaxTokens         = 4000
+)
+
+// llmBaseURLs are the chat endpoints of the providers that need no
+// llm.base_url.
+var llmBaseURLs = map[string]string{
+	"zhipu":  "https://open.bigmodel.cn/api/paas/v4",
+	"openai": "https://api.openai.com/v1",
+}
+
+// LLMExtractor extracts contract information in process, by prompting any
+// OpenAI-compatible chat completions endpoint, so the AI service is not
+// needed for extraction.
+type LLMExtractor struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+	timeout    time.Duration
+	maxRetries int
+	logger     *zap.Logger
+}
+
+func NewLLMExtractor(cfg *config.LLMConfig, logger *zap.Logger) (*LLMExtractor, error) {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = llmBaseURLs[cfg.Provider]
+	}
+	if baseURL == "" {
+		return nil, fmt.Errorf("llm.base_url is required for provider %q", cfg.Provider)
+	}
+	if cfg.Model == "" {
+		return nil, errors.New("llm.model is required")
+	}
+
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultLLMTimeout
+	}
+
+	return &LLMExtractor{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     cfg.APIKey,
+		model:      cfg.Model,
+		httpClient: &http.Client{},
+		timeout:    timeout,
+		maxRetries: defaultAIMaxRetries,
+		logger:     logger,
+	}, nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+	MaxTokens   int           `json:"max_tokens"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+func (e *LLMExtractor) ExtractContractInfo(ctx context.Context, doc *model.ParsedDocument) (*model.AIExtractionResponse, error) {
+	body, err := json.Marshal(chatRequest{
+		Model: e.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: llmSystemPrompt},
+			{Role: "user", Content: strings.Replace(llmExtractionPrompt, "{document_text}", doc.Content, 1)},
+		},
+		Temperature: llmTemperature,
+		MaxTokens:   llmMaxTokens,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	content, err := e.complete(ctx, body, e.timeout+time.Duration(utf8.RuneCountInString(doc.Content)/aiExtractCharsPerSecond)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	e.logger.Debug("LLM response", zap.String("file", doc.FileName), zap.Int("length", len(content)))
+
+	result, err := parseLLMExtraction(content)
+	if err != nil {
+		// Like the AI service, answer with an empty, zero-confidence
+		// result rather than failing the file.
+		e.logger.Warn("failed to decode LLM extraction, using defaults",
+			zap.String("file", doc.FileName),
+			zap.Error(err),
+		)
+		return llmExtractionDefaults(0), nil
+	}
+	return result, nil
+}
+
+// complete sends a chat request and returns the reply, retrying network
+// errors, timeouts, 429 and 5xx responses like the AI service client does.
+func (e *LLMExtractor) complete(ctx context.Context, body []byte, timeout time.Duration) (string, error) {
+	for attempt := 0; ; attempt++ {
+		content, retry, wait, err := e.attempt(ctx, body, timeout)
+		if err == nil || !retry || ctx.Err() != nil || attempt >= e.maxRetries {
+			return content, err
+		}
+
+		delay := max(backoff(attempt), wait)
+		e.logger.Warn("LLM request failed, retrying",
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+			zap.Error(err),
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (e *LLMExtractor) attempt(ctx context.Context, body []byte, timeout time.Duration) (content string, retry bool, wait time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", false, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return "", true, 0, fmt.Errorf("failed to send LLM request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return "", retry, retryAfter(resp), fmt.Errorf("LLM API returned error: %s - %s", resp.Status, string(respBody))
+	}
+
+	var result chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", false, 0, fmt.Errorf("failed to decode LLM response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", false, 0, errors.New("LLM response has no choices")
+	}
+	return result.Choices[0].Message.Content, false, 0, nil
+}
+
+// parseLLMExtraction decodes the JSON object in a model reply, which may be
+// wrapped in a code fence or surrounded by prose. It fails only if there is
+// no JSON object to decode. Within it, fields are decoded the way the AI
+// service does: missing or unusable values get defaults instead of failing
+// the whole result.
+func parseLLMExtraction(content string) (*model.AIExtractionResponse, error) {
+	text := strings.TrimSpace(content)
+	if start, end := strings.Index(text, "{"), strings.LastIndex(text, "}"); start >= 0 && end > start {
+		text = text[start : end+1]
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal([]byte(text), &data); err != nil {
+		if err := json.Unmarshal([]byte(repairLLMJSON(text)), &data); err != nil {
+			return nil, fmt.Errorf("failed to decode LLM extraction: %w", err)
+		}
+	}
+	if data == nil {
+		return nil, errors.New("LLM extraction is not a JSON object")
+	}
+
+	// Older prompts named these service fields differently.
+	if types, ok := data["type_specific"].(map[string]any); ok {
+		if service, ok := types["service_fields"].(map[string]any); ok {
+			for old, name := range map[string]string{"service_type": "service_content", "fee": "service_fee"} {
+				if _, ok := service[name]; !ok && service[old] != nil {
+					service[name] = service[old]
+				}
+			}
+		}
+	}
+
+	result := llmExtractionDefaults(defaultLLMConfidence)
+	decodeLenient(reflect.ValueOf(result).Elem(), data)
+	return result, nil
+}
+
+// llmExtractionDefaults returns a result with every text field "Unknown",
+// every list empty and every section at the given confidence, as the AI
+// service fills in what the model leaves out.
+func llmExtractionDefaults(confidence float64) *model.AIExtractionResponse {
+	result := &model.AIExtractionResponse{}
+	fillLLMDefaults(reflect.ValueOf(result).Elem(), confidence)
+	result.ContractInfo.ContractType = model.ContractTypeOther
+	return result
+}
+
+func fillLLMDefaults(v reflect.Value, confidence float64) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.String:
+			field.SetString("Unknown")
+		case field.Kind() == reflect.Slice:
+			field.Set(reflect.MakeSlice(field.Type(), 0, 0))
+		case field.Kind() == reflect.Struct:
+			fillLLMDefaults(field, confidence)
+		case v.Type().Field(i).Name == "Confidence":
+			field.SetFloat(confidence)
+		}
+	}
+}
+
+// decodeLenient stores src, a value decoded from JSON, in dst. Scalars of
+// the wrong type are converted when that is unambiguous, a single value is
+// accepted for a list, and anything else is skipped, leaving dst as it was.
+// It reports whether src was used.
+func decodeLenient(dst reflect.Value, src any) bool {
+	switch dst.Kind() {
+	case reflect.Struct:
+		fields, ok := src.(map[string]any)
+		if !ok {
+			return false
+		}
+		for i := 0; i < dst.NumField(); i++ {
+			name, _, _ := strings.Cut(dst.Type().Field(i).Tag.Get("json"), ",")
+			if value, ok := fields[name]; ok {
+				decodeLenient(dst.Field(i), value)
+			}
+		}
+		return true
+	case reflect.Pointer:
+		if _, ok := src.(map[string]any); !ok {
+			return false
+		}
+		elem := reflect.New(dst.Type().Elem())
+		fillLLMDefaults(elem.Elem(), defaultLLMConfidence)
+		decodeLenient(elem.Elem(), src)
+		dst.Set(elem)
+		return true
+	case reflect.Slice:
+		items, ok := src.([]any)
+		if !ok {
+			if src == nil {
+				return false
+			}
+			items = []any{src}
+		}
+		list := reflect.MakeSlice(dst.Type(), 0, len(items))
+		for _, item := range items {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if decodeLenient(elem, item) {
+				list = reflect.Append(list, elem)
+			}
+		}
+		dst.Set(list)
+		return true
+	case reflect.String:
+		switch value := src.(type) {
+		case string:
+			dst.SetString(value)
+		case float64:
+			dst.SetString(strconv.FormatFloat(value, 'f', -1, 64))
+		case bool:
+			dst.SetString(strconv.FormatBool(value))
+		default:
+			return false
+		}
+		return true
+	case reflect.Float64:
+		switch value := src.(type) {
+		case float64:
+			dst.SetFloat(value)
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return false
+			}
+			dst.SetFloat(f)
+		default:
+			return false
+		}
+		return true
+	case reflect.Int:
+		switch value := src.(type) {
+		case float64:
+			dst.SetInt(int64(value))
+		case string:
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return false
+			}
+			dst.SetInt(int64(n))
+		default:
+			return false
+		}
+		return true
+	case reflect.Bool:
+		switch value := src.(type) {
+		case bool:
+			dst.SetBool(value)
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				return false
+			}
+			dst.SetBool(b)
+		default:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// repairLLMJSON fixes the mistakes models commonly make inside JSON
+// strings: unescaped quotes, and raw newlines and tabs. A quote is taken to
+// close a string only when it is followed by ':', ',', '}' or ']'.
+func repairLLMJSON(text string) string {
+	var b strings.Builder
+	inString, escaped := false, false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"' && !inString:
+			inString = true
+		case c == '"':
+			next := strings.TrimLeft(text[i+1:], " \t\r\n")
+			if next != "" && strings.ContainsRune(":,}]", rune(next[0])) {
+				inString = false
+			} else {
+				b.WriteString(`\"`)
+				continue
+			}
+		case inString && (c == '\n' || c == '\t'):
+			c = ' '
+		case inString && c == '\r':
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// llmSystemPrompt and llmExtractionPrompt are the AI service's prompts
+// (SYSTEM_PROMPT and EXTRACTION_PROMPT in ai-service/core/extractor.py),
+// so both backends extract alike. TestLLMPromptsMatchAIService fails when
+// the copies drift apart.
+const llmSystemPrompt = "你是一位专业的法律合同信息提取专家。请严格按照指定的JSON格式提取信息，字段名必须完全一致。"
+
+// {document_text} in llmExtractionPrompt is replaced with the document.
+const llmExtractionPrompt = `你是一位专业的法律合同分析师。请从以下合同文本中提取关键信息，并以JSON格式返回。
+
+合同文本:
+{document_text}
+
+请提取以下信息并严格按照以下JSON格式返回（注意字段名必须完全一致）:
+
+{
+    "contract_info": {
+        "contract_type": "purchase或lease或loan或employment或service或other",
+        "contract_number": "合同编号",
+        "signing_date": "签订日期(YYYY-MM-DD格式)",
+        "effective_date": "生效日期",
+        "expiry_date": "到期日期",
+        "signing_location": "签订地点",
+        "contract_status": "合同状态",
+        "confidence": 0.9,
+        "source_references": []
+    },
+    "party_a": {
+        "name": "甲方名称",
+        "type": "企业或个人",
+        "legal_representative": "法定代表人",
+        "id_number": "身份证号或统一社会信用代码",
+        "address": "地址",
+        "contact": "联系方式",
+        "bank_name": "开户银行",
+        "bank_account": "银行账号",
+        "confidence": 0.9,
+        "source_references": []
+    },
+    "party_b": {
+        "name": "乙方名称",
+        "type": "企业或个人",
+        "legal_representative": "法定代表人",
+        "id_number": "身份证号或统一社会信用代码",
+        "address": "地址",
+        "contact": "联系方式",
+        "bank_name": "开户银行",
+        "bank_account": "银行账号",
+        "confidence": 0.9,
+        "source_references": []
+    },
+    "financial": {
+        "transaction_amount": "交易金额",
+        "currency": "CNY",
+        "payment_method": "支付方式",
+        "payment_schedule": "付款安排",
+        "tax_info": "税务信息",
+        "confidence": 0.9,
+        "source_references": []
+    },
+    "validity": {
+        "effective_condition": "生效条件",
+        "termination_condition": "解除条件",
+        "contract_status": "合同状态",
+        "termination_date": "终止日期",
+        "confidence": 0.9,
+        "source_references": []
+    },
+    "rights_obligations": {
+        "party_a_obligations": ["义务1", "义务2"],
+        "party_b_obligations": ["义务1", "义务2"],
+        "party_a_rights": ["权利1", "权利2"],
+        "party_b_rights": ["权利1", "权利2"],
+        "performance_period": "履行期限",
+        "performance_location": "履行地点",
+        "confidence": 0.9,
+        "source_references": []
+    },
+    "breach_liability": {
+        "breach_scenarios": ["违约情形1", "违约情形2"],
+        "liquidated_damages": "违约金条款",
+        "compensation_limit": "赔偿限额",
+        "exemption_clauses": ["免责条款1"],
+        "force_majeure_clause": "不可抗力条款",
+        "confidence": 0.9,
+        "source_references": []
+    },
+    "dispute_resolution": {
+        "resolution_method": "诉讼或仲裁",
+        "jurisdiction_court": "管辖法院",
+        "arbitration_org": "仲裁机构",
+        "arbitration_location": "仲裁地点",
+        "governing_law": "适用法律",
+        "confidence": 0.9,
+        "source_references": []
+    },
+    "confidentiality_ip": {
+        "confidentiality_clause": "保密条款",
+        "confidentiality_period": "保密期限",
+        "ip_ownership": "知识产权归属",
+        "confidence": 0.9,
+        "source_references": []
+    },
+    "other_terms": {
+        "modification_clause": "变更条款",
+        "assignment_clause": "转让条款",
+        "termination_procedure": "解除程序",
+        "notice_clause": "通知条款",
+        "contract_copies": "合同份数",
+        "attachments": [],
+        "confidence": 0.9,
+        "source_references": []
+    },
+    "signature": {
+        "party_a_signatory": "甲方签字人",
+        "party_a_sign_date": "甲方签字日期",
+        "party_a_seal": false,
+        "party_b_signatory": "乙方签字人",
+        "party_b_sign_date": "乙方签字日期",
+        "party_b_seal": false,
+        "witness_name": "见证人姓名",
+        "witness_contact": "见证人联系方式",
+        "confidence": 0.9,
+        "source_references": []
+    },
+    "type_specific": {
+        "employment_fields": null,
+        "lease_fields": null,
+        "loan_fields": null,
+        "service_fields": {
+            "service_content": "服务内容描述",
+            "service_standard": "服务标准",
+            "service_period": "服务期限",
+            "service_fee": "服务费用",
+            "acceptance_criteria": "验收标准",
+            "confidence": 0.9
+        },
+        "purchase_fields": null
+    }
+}
+
+重要提示:
+1. 字段名必须完全按照上面的格式，不能修改
+2. 如果是服务合同(service)，type_specific.service_fields必须包含以下字段：service_content, service_standard, service_period, service_fee, acceptance_criteria, confidence
+3. 如果无法提取某字段，填写"Unknown"，数组填写[]
+4. 只返回JSON，不要额外解释
+5. 每个部分的source_references列出支持该部分信息的原文，格式为{"page": 页码, "paragraph": 段落序号, "text": "原文"}，text必须从合同文本中逐字摘录，不得改写或概括
+`
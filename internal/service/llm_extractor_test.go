@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"contract-key-extractor/internal/config"
+	"contract-key-extractor/internal/model"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// newTestLLMExtractor returns an extractor talking to a chat endpoint stub.
+// reply answers each call, numbered from 1, with a status and a message.
+func newTestLLMExtractor(t *testing.T, reply func(call int) (int, string)) (*LLMExtractor, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s, want /v1/chat/completions", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		if req.Model != "test-model" || len(req.Messages) != 2 || !strings.Contains(req.Messages[1].Content, "合同正文") {
+			t.Errorf("unexpected request: %+v", req)
+		}
+
+		status, content := reply(call)
+		if status != http.StatusOK {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, content, status)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []any{map[string]any{"message": chatMessage{Role: "assistant", Content: content}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	extractor, err := NewLLMExtractor(&config.LLMConfig{
+		BaseURL: server.URL + "/v1/",
+		APIKey:  "test-key",
+		Model:   "test-model",
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return extractor, &calls
+}
+
+var testLLMDocument = &model.ParsedDocument{FileName: "a.docx", Content: "合同正文"}
+
+func TestLLMExtractorRetriesTransientErrors(t *testing.T) {
+	extractor, calls := newTestLLMExtractor(t, func(call int) (int, string) {
+		switch call {
+		case 1:
+			return http.StatusBadGateway, "bad gateway"
+		case 2:
+			return http.StatusTooManyRequests, "slow down"
+		}
+		return http.StatusOK, "```json\n{\"party_a\": {\"name\": \"甲公司\", \"confidence\": 0.95}}\n```"
+	})
+
+	result, err := extractor.ExtractContractInfo(context.Background(), testLLMDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("%d calls, want 3", calls.Load())
+	}
+	if result.PartyA.Name != "甲公司" || result.PartyA.Confidence != 0.95 {
+		t.Errorf("party_a = %+v", result.PartyA)
+	}
+	if result.PartyB.Name != "Unknown" || result.PartyB.Confidence != defaultLLMConfidence {
+		t.Errorf("party_b = %+v, want defaults", result.PartyB)
+	}
+}
+
+func TestLLMExtractorGivesUpAfterMaxRetries(t *testing.T) {
+	extractor, calls := newTestLLMExtractor(t, func(int) (int, string) {
+		return http.StatusServiceUnavailable, "down"
+	})
+	extractor.maxRetries = 1
+
+	if _, err := extractor.ExtractContractInfo(context.Background(), testLLMDocument); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls.Load() != 2 {
+		t.Errorf("%d calls, want 2", calls.Load())
+	}
+}
+
+func TestLLMExtractorDoesNotRetryClientErrors(t *testing.T) {
+	extractor, calls := newTestLLMExtractor(t, func(int) (int, string) {
+		return http.StatusUnauthorized, "bad key"
+	})
+
+	if _, err := extractor.ExtractContractInfo(context.Background(), testLLMDocument); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("%d calls, want 1", calls.Load())
+	}
+}
+
+func TestLLMExtractorBadJSONReply(t *testing.T) {
+	extractor, _ := newTestLLMExtractor(t, func(int) (int, string) {
+		return http.StatusOK, "抱歉，我无法处理这份合同。"
+	})
+
+	result, err := extractor.ExtractContractInfo(context.Background(), testLLMDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ContractInfo.ContractType != model.ContractTypeOther || result.PartyA.Name != "Unknown" || result.PartyA.Confidence != 0 {
+		t.Errorf("got %+v, want zero-confidence defaults", result)
+	}
+}
+
+func TestRepairLLMJSON(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{`{"a": "b"}`, `{"a": "b"}`},
+		{`{"a": "他说"好"的", "b": "c"}`, `{"a": "他说\"好\"的", "b": "c"}`},
+		{"{\"a\": \"第一行\n第二行\tx\r\"}", `{"a": "第一行 第二行 x"}`},
+		{`{"a": "x\"y"}`, `{"a": "x\"y"}`},
+		{"{\n\t\"a\": [\"b\"]\n}", "{\n\t\"a\": [\"b\"]\n}"},
+	} {
+		got := repairLLMJSON(tc.in)
+		if got != tc.want {
+			t.Errorf("repairLLMJSON(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+		if !json.Valid([]byte(got)) {
+			t.Errorf("repairLLMJSON(%q) is not valid JSON", tc.in)
+		}
+	}
+}
+
+func TestParseLLMExtractionLenient(t *testing.T) {
+	result, err := parseLLMExtraction(`以下是结果：{
+		"contract_info": {"contract_type": "service", "contract_number": 20240101, "confidence": "0.7",
+			"source_references": [{"page": "2", "text": "第一条"}, "not a reference"]},
+		"party_a": null,
+		"rights_obligations": {"party_a_obligations": "按时付款"},
+		"signature": {"party_a_seal": "true", "party_b_seal": 1},
+		"type_specific": {"service_fields": {"service_type": "咨询"}, "loan_fields": null}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := result.ContractInfo
+	if info.ContractType != model.ContractTypeService || info.ContractNumber != "20240101" || info.Confidence != 0.7 || info.SigningDate != "Unknown" {
+		t.Errorf("contract_info = %+v", info)
+	}
+	if len(info.SourceReferences) != 1 || info.SourceReferences[0].Page != 2 || info.SourceReferences[0].Text != "第一条" {
+		t.Errorf("source_references = %+v", info.SourceReferences)
+	}
+	if result.PartyA.Name != "Unknown" || result.PartyA.Confidence != defaultLLMConfidence {
+		t.Errorf("party_a = %+v, want defaults", result.PartyA)
+	}
+	if obligations := result.RightsObligations.PartyAObligations; len(obligations) != 1 || obligations[0] != "按时付款" {
+		t.Errorf("party_a_obligations = %q", obligations)
+	}
+	if !result.Signature.PartyASeal || result.Signature.PartyBSeal {
+		t.Errorf("signature = %+v", result.Signature)
+	}
+	service := result.TypeSpecific.ServiceFields
+	if service == nil || service.ServiceContent != "咨询" || service.ServiceFee != "Unknown" || service.Confidence != defaultLLMConfidence {
+		t.Errorf("service_fields = %+v", service)
+	}
+	if result.TypeSpecific.LoanFields != nil {
+		t.Errorf("loan_fields = %+v, want nil", result.TypeSpecific.LoanFields)
+	}
+}
+
+// TestLLMPromptsMatchAIService checks that the direct backend sends the
+// same prompts as the AI service.
+func TestLLMPromptsMatchAIService(t *testing.T) {
+	data, err := os.ReadFile("../../ai-service/core/extractor.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	between := func(start, end string) string {
+		_, rest, ok := strings.Cut(source, start)
+		if !ok {
+			t.Fatalf("%q not found in extractor.py", start)
+		}
+		value, _, ok := strings.Cut(rest, end)
+		if !ok {
+			t.Fatalf("no %q after %q in extractor.py", end, start)
+		}
+		return value
+	}
+
+	if got := between(`SYSTEM_PROMPT = "`, `"`); got != llmSystemPrompt {
+		t.Errorf("system prompt differs from the AI service's:\n%s", got)
+	}
+
+	// The Python prompt is a str.format template, with literal braces
+	// doubled.
+	extraction := between(`EXTRACTION_PROMPT = """`, `"""`)
+	extraction = strings.NewReplacer("{{", "{", "}}", "}").Replace(extraction)
+	if extraction != llmExtractionPrompt {
+		t.Error("extraction prompt differs from the AI service's EXTRACTION_PROMPT")
+	}
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"contract-key-extractor/internal/model"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+const (
+	// minQuoteSimilarity is the share of a quote that must match the
+	// document, after normalisation, for it to count as found.
+	minQuoteSimilarity = 0.85
+	// maxQuoteAlignRunes bounds the fuzzy alignment cost for long quotes;
+	// only their beginning is aligned.
+	maxQuoteAlignRunes = 256
+	// quoteGramRunes is the length of the n-grams that anchor a fuzzy
+	// alignment. A quote within minQuoteSimilarity of the document shares
+	// at least one n-gram with it once it is twice this long; shorter quotes
+	// are aligned against the whole text.
+	quoteGramRunes = 3
+	// maxQuoteCandidates is the number of anchored windows aligned per
+	// quote, best anchored first.
+	maxQuoteCandidates = 4
+	// unverifiedConfidenceFactor is applied to a section's confidence when
+	// none of its quotes can be found; partial evidence scales linearly
+	// towards 1.
+	unverifiedConfidenceFactor = 0.5
+)
+
+// sourceSection is a section of the AI response whose evidence is checked.
+type sourceSection struct {
+	name       string
+	confidence *float64
+	refs       []model.SourceRef
+}
+
+func responseSections(resp *model.AIExtractionResponse) []sourceSection {
+	return []sourceSection{
+		{"contract_info", &resp.ContractInfo.Confidence, resp.ContractInfo.SourceReferences},
+		{"party_a", &resp.PartyA.Confidence, resp.PartyA.SourceReferences},
+		{"party_b", &resp.PartyB.Confidence, resp.PartyB.SourceReferences},
+		{"financial", &resp.Financial.Confidence, resp.Financial.SourceReferences},
+		{"validity", &resp.Validity.Confidence, resp.Validity.SourceReferences},
+		{"rights_obligations", &resp.RightsObligations.Confidence, resp.RightsObligations.SourceReferences},
+		{"breach_liability", &resp.BreachLiability.Confidence, resp.BreachLiability.SourceReferences},
+		{"dispute_resolution", &resp.DisputeResolution.Confidence, resp.DisputeResolution.SourceReferences},
+		{"confidentiality_ip", &resp.ConfidentialityIP.Confidence, resp.ConfidentialityIP.SourceReferences},
+		{"other_terms", &resp.OtherTerms.Confidence, resp.OtherTerms.SourceReferences},
+		{"signature", &resp.Signature.Confidence, resp.Signature.SourceReferences},
+	}
+}
+
+// verifySourceRefs looks up every quoted SourceRef in the parsed document.
+// Found quotes get their real page, paragraph, offsets and, for PDFs,
+// bounding box; quotes that cannot be found are left unverified and lower
+// the confidence of their section. It returns the number of unverified
+// quotes.
+func verifySourceRefs(doc *model.ParsedDocument, resp *model.AIExtractionResponse) int {
+	index := newQuoteIndex(doc)
+	unverified := 0
+
+	for _, section := range responseSections(resp) {
+		if len(section.refs) == 0 {
+			continue
+		}
+
+		verified := 0
+		for i := range section.refs {
+			if index.verify(&section.refs[i]) {
+				verified++
+			} else {
+				unverified++
+			}
+		}
+
+		share := float64(verified) / float64(len(section.refs))
+		*section.confidence *= unverifiedConfidenceFactor + (1-unverifiedConfidenceFactor)*share
+	}
+
+	return unverified
+}
+
+// quoteIndex is the document content normalised for matching, with the
+// position in the original content of every normalised rune. The n-gram
+// positions are built on the first fuzzy lookup.
+type quoteIndex struct {
+	doc    *model.ParsedDocument
+	text   []rune
+	str    string
+	origin []int
+	grams  map[string][]int
+}
+
+func newQuoteIndex(doc *model.ParsedDocument) *quoteIndex {
+	text, origin := normalizeQuote(doc.Content)
+	return &quoteIndex{doc: doc, text: text, str: string(text), origin: origin}
+}
+
+// normalizeQuote folds full-width forms and case and drops whitespace,
+// punctuation and table separators, which models routinely alter when
+// quoting. It returns the remaining runes with their rune offsets in s;
+// folding maps each rune to exactly one rune, so offsets in the folded text
+// are offsets in s.
+func normalizeQuote(s string) ([]rune, []int) {
+	var text []rune
+	var origin []int
+	offset := 0
+	for _, r := range string(width.Fold.Bytes([]byte(s))) {
+		if !unicode.IsSpace(r) && !unicode.IsPunct(r) && r != '|' {
+			text = append(text, unicode.ToLower(r))
+			origin = append(origin, offset)
+		}
+		offset++
+	}
+	return text, origin
+}
+
+func (x *quoteIndex) verify(ref *model.SourceRef) bool {
+	quote, _ := normalizeQuote(ref.Text)
+
+	ref.Verified = false
+	ref.Similarity = 0
+	ref.BBox = nil
+	if len(quote) == 0 || len(x.text) == 0 {
+		return false
+	}
+
+	start, end, similarity := x.find(quote)
+	ref.Similarity = similarity
+	if similarity < minQuoteSimilarity {
+		return false
+	}
+
+	ref.Verified = true
+	ref.Start = x.origin[start]
+	ref.End = x.origin[end-1] + 1
+	x.locate(ref)
+	return true
+}
+
+// find returns the normalised range [start, end) that best matches quote and
+// the similarity of the match, from 0 to 1.
+func (x *quoteIndex) find(quote []rune) (int, int, float64) {
+	if i := strings.Index(x.str, string(quote)); i >= 0 {
+		start := utf8.RuneCountInString(x.str[:i])
+		return start, start + len(quote), 1
+	}
+
+	aligned := quote
+	if len(aligned) > maxQuoteAlignRunes {
+		aligned = aligned[:maxQuoteAlignRunes]
+	}
+	start, end, distance := x.alignAnchored(aligned)
+	similarity := 1 - float64(distance)/float64(len(aligned))
+	if similarity < 0 {
+		similarity = 0
+	}
+	// Extend a truncated alignment by the part of the quote not aligned.
+	end = min(end+len(quote)-len(aligned), len(x.text))
+	return start, end, similarity
+}
+
+// alignAnchored aligns quote against the windows of text where most of its
+// n-grams occur, instead of against the whole text. A window is wide enough
+// for any alignment that would reach minQuoteSimilarity.
+func (x *quoteIndex) alignAnchored(quote []rune) (int, int, int) {
+	m := len(quote)
+	if m < 2*quoteGramRunes {
+		return alignQuote(quote, x.text)
+	}
+
+	if x.grams == nil {
+		x.grams = map[string][]int{}
+		for i := 0; i+quoteGramRunes <= len(x.text); i++ {
+			gram := string(x.text[i : i+quoteGramRunes])
+			x.grams[gram] = append(x.grams[gram], i)
+		}
+	}
+
+	// Each shared n-gram votes for where the quote would start. Votes are
+	// bucketed by the slack an alignment is allowed, so that edits which
+	// shift the rest of the quote still count towards the same window.
+	slack := int(float64(m)*(1-minQuoteSimilarity)) + 1
+	votes := map[int]int{}
+	for i := 0; i+quoteGramRunes <= m; i++ {
+		for _, pos := range x.grams[string(quote[i:i+quoteGramRunes])] {
+			votes[(pos-i+m)/slack]++
+		}
+	}
+	buckets := make([]int, 0, len(votes))
+	for bucket := range votes {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if votes[buckets[i]] != votes[buckets[j]] {
+			return votes[buckets[i]] > votes[buckets[j]]
+		}
+		return buckets[i] < buckets[j]
+	})
+	if len(buckets) > maxQuoteCandidates {
+		buckets = buckets[:maxQuoteCandidates]
+	}
+
+	bestStart, bestEnd, bestDistance := 0, min(m, len(x.text)), m
+	for _, bucket := range buckets {
+		// The bucket's starts run from lowest to lowest+slack.
+		lowest := bucket*slack - m
+		from := max(lowest-slack, 0)
+		to := min(lowest+2*slack+m, len(x.text))
+		if from >= to {
+			continue
+		}
+		start, end, distance := alignQuote(quote, x.text[from:to])
+		if distance < bestDistance {
+			bestStart, bestEnd, bestDistance = from+start, from+end, distance
+		}
+	}
+	return bestStart, bestEnd, bestDistance
+}
+
+// alignQuote finds the substring of text with the smallest edit distance to
+// quote (semi-global alignment: the match may begin and end anywhere in
+// text). It returns the substring's range and the distance.
+func alignQuote(quote, text []rune) (int, int, int) {
+	m := len(quote)
+	prev := make([]int, m+1)
+	curr := make([]int, m+1)
+	prevStart := make([]int, m+1)
+	currStart := make([]int, m+1)
+	for i := range prev {
+		prev[i] = i
+	}
+
+	bestDistance, bestEnd, bestStart := prev[m], 0, 0
+	for j := 1; j <= len(text); j++ {
+		curr[0] = 0
+		currStart[0] = j
+		for i := 1; i <= m; i++ {
+			cost := 1
+			if quote[i-1] == text[j-1] {
+				cost = 0
+			}
+			// Substitution or match, then deletion from text, then
+			// insertion into text.
+			curr[i], currStart[i] = prev[i-1]+cost, prevStart[i-1]
+			if prev[i]+1 < curr[i] {
+				curr[i], currStart[i] = prev[i]+1, prevStart[i]
+			}
+			if curr[i-1]+1 < curr[i] {
+				curr[i], currStart[i] = curr[i-1]+1, currStart[i-1]
+			}
+		}
+		if curr[m] < bestDistance {
+			bestDistance, bestEnd, bestStart = curr[m], j, currStart[m]
+		}
+		prev, curr = curr, prev
+		prevStart, currStart = currStart, prevStart
+	}
+
+	if bestEnd <= bestStart {
+		return 0, min(m, len(text)), bestDistance
+	}
+	return bestStart, bestEnd, bestDistance
+}
+
+// locate fills in the page, paragraph and bounding box of a found quote from
+// the layout block it starts in.
+func (x *quoteIndex) locate(ref *model.SourceRef) {
+	for _, page := range x.doc.Layout {
+		for _, block := range page.Blocks {
+			if block.Start > ref.Start {
+				return
+			}
+			ref.Page = page.Number
+			ref.Paragraph = block.Paragraph
+			ref.BBox = nil
+			if ref.Start < block.End && block.BBox != nil {
+				box := *block.BBox
+				ref.BBox = &box
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"contract-key-extractor/internal/model"
+	"math"
+	"strings"
+	"testing"
+)
+
+const testSourceContent = "第一条 合同双方\n甲方：上海星辰科技有限公司\n乙方：北京远航贸易有限公司\n第二条 合同金额\n合同总价为人民币１２０，０００元（大写：壹拾贰万元整）。"
+
+// newTestSourceDoc lays content out as one page with a paragraph per line.
+func newTestSourceDoc(content string) *model.ParsedDocument {
+	page := model.LayoutPage{Number: 1}
+	offset := 0
+	for i, line := range strings.Split(content, "\n") {
+		n := len([]rune(line))
+		page.Blocks = append(page.Blocks, model.LayoutBlock{
+			Type:      model.BlockTypeParagraph,
+			Paragraph: i + 1,
+			Text:      line,
+			Start:     offset,
+			End:       offset + n,
+		})
+		offset += n + 1
+	}
+	return &model.ParsedDocument{Content: content, Layout: []model.LayoutPage{page}}
+}
+
+func TestVerifySourceRef(t *testing.T) {
+	doc := newTestSourceDoc(testSourceContent)
+
+	for _, tc := range []struct {
+		name      string
+		quote     string
+		verified  bool
+		exact     bool
+		paragraph int
+		text      string
+	}{
+		{"exact", "乙方：北京远航贸易有限公司", true, true, 3, "乙方：北京远航贸易有限公司"},
+		// Width, spacing and punctuation differences are normalised away.
+		{"normalised", "合同总价为人民币 120,000 元", true, true, 5, "合同总价为人民币１２０，０００元"},
+		{"fuzzy", "合同总价为人民币１２０，０００元（大写：壹拾贰万圆整）", true, false, 5, "合同总价为人民币１２０，０００元（大写：壹拾贰万元整"},
+		{"rejected", "丙方：广州某某物流有限公司负责运输", false, false, 0, ""},
+	} {
+		ref := model.SourceRef{Text: tc.quote}
+		if got := newQuoteIndex(doc).verify(&ref); got != tc.verified || ref.Verified != tc.verified {
+			t.Errorf("%s: verify = %v, Verified = %v, want %v", tc.name, got, ref.Verified, tc.verified)
+			continue
+		}
+		if tc.exact != (ref.Similarity == 1) {
+			t.Errorf("%s: similarity = %v", tc.name, ref.Similarity)
+		}
+		if !tc.verified {
+			if ref.Similarity >= minQuoteSimilarity {
+				t.Errorf("%s: similarity = %v for a rejected quote", tc.name, ref.Similarity)
+			}
+			continue
+		}
+		if got := string([]rune(doc.Content)[ref.Start:ref.End]); got != tc.text {
+			t.Errorf("%s: matched %q, want %q", tc.name, got, tc.text)
+		}
+		if ref.Page != 1 || ref.Paragraph != tc.paragraph {
+			t.Errorf("%s: page %d paragraph %d, want page 1 paragraph %d", tc.name, ref.Page, ref.Paragraph, tc.paragraph)
+		}
+	}
+}
+
+func TestVerifySourceRefFuzzyInLongDocument(t *testing.T) {
+	// The quote has one wrong character and sits far from the start, so it
+	// is only found through its n-gram anchors.
+	filler := strings.Repeat("本合同其他条款按照双方另行签订的补充协议执行。", 400)
+	content := filler + "\n争议解决：因本合同引起的争议，提交上海国际仲裁中心仲裁。\n" + filler
+	doc := newTestSourceDoc(content)
+
+	ref := model.SourceRef{Text: "因本合同引起的纠议，提交上海国际仲裁中心仲裁"}
+	if !newQuoteIndex(doc).verify(&ref) || ref.Similarity == 1 {
+		t.Fatalf("Verified = %v, similarity = %v", ref.Verified, ref.Similarity)
+	}
+	if got := string([]rune(content)[ref.Start:ref.End]); got != "因本合同引起的争议，提交上海国际仲裁中心仲裁" {
+		t.Errorf("matched %q", got)
+	}
+	if ref.Paragraph != 2 {
+		t.Errorf("paragraph = %d, want 2", ref.Paragraph)
+	}
+}
+
+func TestVerifySourceRefsConfidence(t *testing.T) {
+	doc := newTestSourceDoc(testSourceContent)
+	found := model.SourceRef{Text: "甲方：上海星辰科技有限公司"}
+	missing := model.SourceRef{Text: "丙方：广州某某物流有限公司负责运输"}
+
+	resp := &model.AIExtractionResponse{}
+	resp.PartyA.Confidence = 0.8
+	resp.PartyA.SourceReferences = []model.SourceRef{found}
+	resp.PartyB.Confidence = 0.8
+	resp.PartyB.SourceReferences = []model.SourceRef{found, missing}
+	resp.Financial.Confidence = 0.8
+	resp.Financial.SourceReferences = []model.SourceRef{missing, missing}
+	// A section without quotes keeps its confidence.
+	resp.Validity.Confidence = 0.8
+
+	if got := verifySourceRefs(doc, resp); got != 3 {
+		t.Errorf("unverified = %d, want 3", got)
+	}
+	for _, tc := range []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"all found", resp.PartyA.Confidence, 0.8},
+		{"half found", resp.PartyB.Confidence, 0.8 * 0.75},
+		{"none found", resp.Financial.Confidence, 0.8 * 0.5},
+		{"no quotes", resp.Validity.Confidence, 0.8},
+	} {
+		if math.Abs(tc.got-tc.want) > 1e-9 {
+			t.Errorf("%s: confidence = %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+const (
+	defaultWorkers        = 4
+	defaultMaxQueuedFiles = 1000
+)
+
+var (
+	ErrQueueFull    = errors.New("extraction queue is full, try again later")
+	ErrTaskFinished = errors.New("task has already finished")
+)
+
+// taskRun is a task being worked on by the pool. mu guards task; ctx is
+// cancelled when the task is. The remaining fields belong to the queue.
+type taskRun struct {
+	mu     sync.Mutex
+	task   *Task
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	files  []int // indexes of files not yet handed to a worker
+	active int   // files handed to a worker and not yet done
+	paused bool
+}
+
+func newTaskRun(task *Task) *taskRun {
+	run := &taskRun{task: task}
+	run.ctx, run.cancel = context.WithCancel(context.Background())
+	for i, file := range task.Files {
+		if file.Status == "pending" {
+			run.files = append(run.files, i)
+		}
+	}
+	return run
+}
+
+// taskQueue hands the files of queued tasks to workers. Tasks take turns, one
+// file at a time, so a large batch does not hold up the tasks queued behind
+// it.
+//
+// A run stays known to the queue until its last file is done, including
+// while it is paused, so that it can be looked up, paused, resumed and
+// cancelled.
+type taskQueue struct {
+	mu    sync.Mutex
+	ready *sync.Cond
+
+	tasks    map[string]*taskRun
+	runs     []*taskRun // unpaused tasks with files not yet handed out, in turn order
+	queued   int        // files not yet handed out
+	reserved int        // files of tasks about to be pushed
+	limit    int
+}
+
+func newTaskQueue(limit int) *taskQueue {
+	q := &taskQueue{tasks: map[string]*taskRun{}, limit: limit}
+	q.ready = sync.NewCond(&q.mu)
+	return q
+}
+
+// reserve claims room for n files, or fails with ErrQueueFull. An empty
+// queue accepts a batch of any size, so large uploads are delayed rather
+// than refused forever. Every reserve is matched by a release.
+func (q *taskQueue) reserve(n int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	waiting := q.queued + q.reserved
+	if waiting > 0 && waiting+n > q.limit {
+		return ErrQueueFull
+	}
+	q.reserved += n
+	return nil
+}
+
+func (q *taskQueue) release(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.reserved -= n
+}
+
+// push adds a run with pending files to the queue. A paused run is only
+// registered; its files wait for resume.
+func (q *taskQueue) push(run *taskRun) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.tasks[run.task.ID] = run
+	if !run.paused {
+		q.schedule(run)
+	}
+}
+
+func (q *taskQueue) schedule(run *taskRun) {
+	if len(run.files) == 0 {
+		return
+	}
+	q.runs = append(q.runs, run)
+	q.queued += len(run.files)
+	q.ready.Broadcast()
+}
+
+func (q *taskQueue) unschedule(run *taskRun) {
+	for i, queued := range q.runs {
+		if queued == run {
+			q.runs = append(q.runs[:i], q.runs[i+1:]...)
+			q.queued -= len(run.files)
+			return
+		}
+	}
+}
+
+// lookup returns the run of an unfinished task, or nil.
+func (q *taskQueue) lookup(taskID string) *taskRun {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.tasks[taskID]
+}
+
+// pause stops handing out the run's files. Files already handed out are
+// left to finish. It reports false if the run has already finished.
+func (q *taskQueue) pause(run *taskRun) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.tasks[run.task.ID] != run {
+		return false
+	}
+	if !run.paused {
+		run.paused = true
+		q.unschedule(run)
+	}
+	return true
+}
+
+// resume puts a paused run back in the queue. It reports false if the run
+// has already finished.
+func (q *taskQueue) resume(run *taskRun) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.tasks[run.task.ID] != run {
+		return false
+	}
+	if run.paused {
+		run.paused = false
+		q.schedule(run)
+	}
+	return true
+}
+
+// cancel drops the run's files that have not been handed out. ok is false if
+// the run has already finished; otherwise idle reports whether no file is in
+// progress, in which case the caller must finish the task, as no worker
+// will.
+func (q *taskQueue) cancel(run *taskRun) (idle, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.tasks[run.task.ID] != run {
+		return false, false
+	}
+	q.unschedule(run)
+	run.files = nil
+	if run.active > 0 {
+		return false, true
+	}
+	delete(q.tasks, run.task.ID)
+	return true, true
+}
+
+// next blocks until a file is available and returns it with its run.
+func (q *taskQueue) next() (*taskRun, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.runs) == 0 {
+		q.ready.Wait()
+	}
+
+	run := q.runs[0]
+	index := run.files[0]
+	run.files = run.files[1:]
+	run.active++
+	q.queued--
+
+	q.runs = q.runs[1:]
+	if len(run.files) > 0 {
+		q.runs = append(q.runs, run)
+	}
+	return run, index
+}
+
+// done marks one of the run's files as finished and reports whether it was
+// the run's last, in which case the caller must finish the task.
+func (q *taskQueue) done(run *taskRun) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	run.active--
+	if run.active > 0 || len(run.files) > 0 {
+		return false
+	}
+	delete(q.tasks, run.task.ID)
+	return true
+}
+
+// position returns the task's place in the queue: 1 when its next file is
+// the next to be handed out, 0 when it has no files waiting.
+func (q *taskQueue) position(taskID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, run := range q.runs {
+		if run.task.ID == taskID {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func newTestRun(id string, files int) *taskRun {
+	task := &Task{ID: id}
+	for i := 0; i < files; i++ {
+		task.Files = append(task.Files, TaskFile{Status: "pending"})
+	}
+	return newTaskRun(task)
+}
+
+func TestTaskQueueReserve(t *testing.T) {
+	type step struct {
+		op  string // "reserve", "release", "push" or "next"
+		n   int
+		err error
+	}
+	for _, tc := range []struct {
+		name  string
+		steps []step
+	}{
+		{"empty queue takes any batch", []step{
+			{"reserve", 8, nil},
+			{"reserve", 1, ErrQueueFull},
+		}},
+		{"queued files count", []step{
+			{"push", 3, nil},
+			{"reserve", 2, nil},
+			{"reserve", 1, ErrQueueFull},
+		}},
+		{"release frees room", []step{
+			{"reserve", 3, nil},
+			{"reserve", 3, ErrQueueFull},
+			{"release", 3, nil},
+			{"reserve", 3, nil},
+		}},
+		{"handed out files leave the queue", []step{
+			{"push", 5, nil},
+			{"reserve", 1, ErrQueueFull},
+			{"next", 2, nil},
+			{"reserve", 2, nil},
+			{"reserve", 1, ErrQueueFull},
+		}},
+	} {
+		q := newTaskQueue(5)
+		for i, s := range tc.steps {
+			var err error
+			switch s.op {
+			case "reserve":
+				err = q.reserve(s.n)
+			case "release":
+				q.release(s.n)
+			case "push":
+				q.push(newTestRun(fmt.Sprintf("task-%d", i), s.n))
+			case "next":
+				for j := 0; j < s.n; j++ {
+					q.next()
+				}
+			}
+			if !errors.Is(err, s.err) {
+				t.Errorf("%s: step %d (%s %d): err = %v, want %v", tc.name, i, s.op, s.n, err, s.err)
+			}
+		}
+	}
+}
+
+func TestTaskQueueRoundRobin(t *testing.T) {
+	q := newTaskQueue(10)
+	q.push(newTestRun("a", 3))
+	q.push(newTestRun("b", 2))
+	paused := newTestRun("p", 2)
+	paused.paused = true
+	q.push(paused)
+	q.push(newTestRun("c", 1))
+
+	var order []string
+	for i := 0; i < 6; i++ {
+		run, index := q.next()
+		order = append(order, fmt.Sprintf("%s%d", run.task.ID, index))
+	}
+	if got, want := strings.Join(order, " "), "a0 b0 c0 a1 b1 a2"; got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+
+	// A resumed task joins the back of the queue.
+	q.push(newTestRun("d", 1))
+	if !q.resume(paused) {
+		t.Fatal("resume failed")
+	}
+	if run, _ := q.next(); run.task.ID != "d" {
+		t.Errorf("next = %s, want d", run.task.ID)
+	}
+	if run, _ := q.next(); run.task.ID != "p" {
+		t.Errorf("next = %s, want p", run.task.ID)
+	}
+}
+
+func TestTaskQueuePosition(t *testing.T) {
+	q := newTaskQueue(10)
+	a, b, c := newTestRun("a", 2), newTestRun("b", 1), newTestRun("c", 1)
+	for _, run := range []*taskRun{a, b, c} {
+		q.push(run)
+	}
+
+	for _, tc := range []struct {
+		step string
+		do   func()
+		want map[string]int
+	}{
+		{"queued", func() {}, map[string]int{"a": 1, "b": 2, "c": 3}},
+		{"a handed out once", func() { q.next() }, map[string]int{"b": 1, "c": 2, "a": 3}},
+		{"b paused", func() { q.pause(b) }, map[string]int{"c": 1, "a": 2, "b": 0}},
+		{"c handed out", func() { q.next() }, map[string]int{"a": 1, "b": 0, "c": 0}},
+		{"b resumed", func() { q.resume(b) }, map[string]int{"a": 1, "b": 2}},
+		{"a cancelled", func() { q.cancel(a) }, map[string]int{"b": 1, "a": 0}},
+		{"unknown task", func() {}, map[string]int{"x": 0}},
+	} {
+		tc.do()
+		for id, want := range tc.want {
+			if got := q.position(id); got != want {
+				t.Errorf("%s: position(%s) = %d, want %d", tc.step, id, got, want)
+			}
+		}
+	}
+}
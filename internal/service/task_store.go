@@ -0,0 +1,194 @@
+package service
+
+import (
+	"contract-key-extractor/internal/config"
+	"contract-key-extractor/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var ErrTaskNotFound = errors.New("task not found")
+
+// TaskStore keeps tasks and their per-file results. Implementations return
+// copies, so callers may keep and modify what they get back.
+type TaskStore interface {
+	SaveTask(task *Task) error
+	GetTask(taskID string) (*Task, error)
+	ListTasks() ([]*Task, error)
+	// SaveResult stores the result of the file at index within the task.
+	SaveResult(taskID string, index int, result *model.ExtractionResult) error
+	// GetResults returns a task's results ordered by file index.
+	GetResults(taskID string) ([]model.ExtractionResult, error)
+	// SaveDelivery adds or updates a webhook delivery record.
+	SaveDelivery(delivery *model.WebhookDelivery) error
+	// GetDeliveries returns a task's webhook deliveries, oldest first.
+	GetDeliveries(taskID string) ([]model.WebhookDelivery, error)
+	Close() error
+}
+
+// NewTaskStore opens the file-backed store at cfg.Path, or an in-memory
+// store when no path is configured.
+func NewTaskStore(cfg *config.StorageConfig) (TaskStore, error) {
+	if cfg.Path == "" {
+		return NewMemoryTaskStore(), nil
+	}
+	return NewBoltTaskStore(cfg.Path)
+}
+
+// MemoryTaskStore keeps tasks in process memory. Everything is lost on
+// restart; it is meant for development and single-shot runs.
+type MemoryTaskStore struct {
+	mu         sync.RWMutex
+	tasks      map[string][]byte
+	results    map[string]map[int][]byte
+	deliveries map[string]map[string][]byte
+}
+
+func NewMemoryTaskStore() *MemoryTaskStore {
+	return &MemoryTaskStore{
+		tasks:      map[string][]byte{},
+		results:    map[string]map[int][]byte{},
+		deliveries: map[string]map[string][]byte{},
+	}
+}
+
+func (s *MemoryTaskStore) SaveTask(task *Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode task: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[task.ID] = data
+	return nil
+}
+
+func (s *MemoryTaskStore) GetTask(taskID string) (*Task, error) {
+	s.mu.RLock()
+	data, ok := s.tasks[taskID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	return decodeTask(data)
+}
+
+func (s *MemoryTaskStore) ListTasks() ([]*Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tasks := make([]*Task, 0, len(s.tasks))
+	for _, data := range s.tasks {
+		task, err := decodeTask(data)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].CreatedAt.Before(tasks[j].CreatedAt) })
+	return tasks, nil
+}
+
+func (s *MemoryTaskStore) SaveResult(taskID string, index int, result *model.ExtractionResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.results[taskID] == nil {
+		s.results[taskID] = map[int][]byte{}
+	}
+	s.results[taskID][index] = data
+	return nil
+}
+
+func (s *MemoryTaskStore) GetResults(taskID string) ([]model.ExtractionResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tasks[taskID]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+
+	stored := s.results[taskID]
+	indexes := make([]int, 0, len(stored))
+	for index := range stored {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	results := make([]model.ExtractionResult, 0, len(indexes))
+	for _, index := range indexes {
+		var result model.ExtractionResult
+		if err := json.Unmarshal(stored[index], &result); err != nil {
+			return nil, fmt.Errorf("failed to decode result: %w", err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (s *MemoryTaskStore) SaveDelivery(delivery *model.WebhookDelivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to encode delivery: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.deliveries[delivery.TaskID] == nil {
+		s.deliveries[delivery.TaskID] = map[string][]byte{}
+	}
+	s.deliveries[delivery.TaskID][delivery.ID] = data
+	return nil
+}
+
+func (s *MemoryTaskStore) GetDeliveries(taskID string) ([]model.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tasks[taskID]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+
+	deliveries := make([]model.WebhookDelivery, 0, len(s.deliveries[taskID]))
+	for _, data := range s.deliveries[taskID] {
+		delivery, err := decodeDelivery(data)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+func (s *MemoryTaskStore) Close() error {
+	return nil
+}
+
+func decodeTask(data []byte) (*Task, error) {
+	var task Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, fmt.Errorf("failed to decode task: %w", err)
+	}
+	return &task, nil
+}
+
+func decodeDelivery(data []byte) (model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	if err := json.Unmarshal(data, &delivery); err != nil {
+		return delivery, fmt.Errorf("failed to decode delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+func sortDeliveries(deliveries []model.WebhookDelivery) {
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt) })
+}
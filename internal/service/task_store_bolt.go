@@ -0,0 +1,190 @@
+package service
+
+import (
+	"contract-key-extractor/internal/model"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltTasksBucket      = []byte("tasks")
+	boltResultsBucket    = []byte("results")
+	boltDeliveriesBucket = []byte("deliveries")
+)
+
+// BoltTaskStore keeps tasks in a single bbolt database file. Tasks are
+// stored as JSON under their ID; each task's results live in a nested
+// bucket keyed by file index, so saving one result does not rewrite the
+// others.
+//
+// bbolt locks the file, so only one server process can use a store at a
+// time.
+type BoltTaskStore struct {
+	db *bolt.DB
+}
+
+func NewBoltTaskStore(path string) (*BoltTaskStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create task store directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open task store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltTasksBucket, boltResultsBucket, boltDeliveriesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise task store: %w", err)
+	}
+
+	return &BoltTaskStore{db: db}, nil
+}
+
+func (s *BoltTaskStore) SaveTask(task *Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode task: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTasksBucket).Put([]byte(task.ID), data)
+	})
+}
+
+func (s *BoltTaskStore) GetTask(taskID string) (*Task, error) {
+	var task *Task
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltTasksBucket).Get([]byte(taskID))
+		if data == nil {
+			return fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+		}
+		var err error
+		task, err = decodeTask(data)
+		return err
+	})
+	return task, err
+}
+
+func (s *BoltTaskStore) ListTasks() ([]*Task, error) {
+	var tasks []*Task
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTasksBucket).ForEach(func(_, data []byte) error {
+			task, err := decodeTask(data)
+			if err != nil {
+				return err
+			}
+			tasks = append(tasks, task)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].CreatedAt.Before(tasks[j].CreatedAt) })
+	return tasks, nil
+}
+
+func (s *BoltTaskStore) SaveResult(taskID string, index int, result *model.ExtractionResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(boltResultsBucket).CreateBucketIfNotExists([]byte(taskID))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(boltIndexKey(index), data)
+	})
+}
+
+func (s *BoltTaskStore) GetResults(taskID string) ([]model.ExtractionResult, error) {
+	results := []model.ExtractionResult{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltTasksBucket).Get([]byte(taskID)) == nil {
+			return fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+		}
+		bucket := tx.Bucket(boltResultsBucket).Bucket([]byte(taskID))
+		if bucket == nil {
+			return nil
+		}
+		// Big-endian keys iterate in index order.
+		return bucket.ForEach(func(_, data []byte) error {
+			var result model.ExtractionResult
+			if err := json.Unmarshal(data, &result); err != nil {
+				return fmt.Errorf("failed to decode result: %w", err)
+			}
+			results = append(results, result)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (s *BoltTaskStore) SaveDelivery(delivery *model.WebhookDelivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to encode delivery: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(boltDeliveriesBucket).CreateBucketIfNotExists([]byte(delivery.TaskID))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(delivery.ID), data)
+	})
+}
+
+func (s *BoltTaskStore) GetDeliveries(taskID string) ([]model.WebhookDelivery, error) {
+	deliveries := []model.WebhookDelivery{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltTasksBucket).Get([]byte(taskID)) == nil {
+			return fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+		}
+		bucket := tx.Bucket(boltDeliveriesBucket).Bucket([]byte(taskID))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, data []byte) error {
+			delivery, err := decodeDelivery(data)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, delivery)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+func (s *BoltTaskStore) Close() error {
+	return s.db.Close()
+}
+
+func boltIndexKey(index int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(index))
+	return key
+}
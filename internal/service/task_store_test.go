@@ -0,0 +1,152 @@
+package service
+
+import (
+	"contract-key-extractor/internal/model"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var testStoreTime = time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+
+func newTestStoreTask(id string, statuses ...string) *Task {
+	task := &Task{
+		ID:         id,
+		Status:     "processing",
+		TotalFiles: len(statuses),
+		CreatedAt:  testStoreTime,
+	}
+	for i, status := range statuses {
+		task.Files = append(task.Files, TaskFile{
+			Paths:  []string{filepath.Join("uploads", id, string(rune('a'+i))+".docx")},
+			Status: status,
+		})
+	}
+	return task
+}
+
+func TestBoltTaskStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	store, err := NewBoltTaskStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task := newTestStoreTask("task-1", "completed", "pending")
+	task.CallbackURL = "https://example.com/hook"
+	task.Files[0].Durations = model.StageDurations{Parse: 0.5, Extract: 2}
+	deliveries := []model.WebhookDelivery{
+		{ID: "d2", TaskID: task.ID, Event: "task.completed", Attempts: 3, Delivered: true, StatusCode: 200, CreatedAt: testStoreTime.Add(time.Minute)},
+		{ID: "d1", TaskID: task.ID, Event: "task.started", Attempts: 1, Error: "timeout", CreatedAt: testStoreTime},
+	}
+
+	if err := store.SaveTask(task); err != nil {
+		t.Fatal(err)
+	}
+	// Results are returned in file order, not in the order they finished.
+	for _, index := range []int{1, 0} {
+		if err := store.SaveResult(task.ID, index, &model.ExtractionResult{ID: string(rune('0' + index))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range deliveries {
+		if err := store.SaveDelivery(&deliveries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = NewBoltTaskStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	got, err := store.GetTask(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, task) {
+		t.Errorf("GetTask = %+v, want %+v", got, task)
+	}
+
+	results, err := store.GetResults(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].ID != "0" || results[1].ID != "1" {
+		t.Errorf("GetResults = %+v, want results 0 and 1", results)
+	}
+
+	gotDeliveries, err := store.GetDeliveries(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []model.WebhookDelivery{deliveries[1], deliveries[0]}; !reflect.DeepEqual(gotDeliveries, want) {
+		t.Errorf("GetDeliveries = %+v, want %+v", gotDeliveries, want)
+	}
+
+	tasks, err := store.ListTasks()
+	if err != nil || len(tasks) != 1 || tasks[0].ID != task.ID {
+		t.Errorf("ListTasks = %v, %v", tasks, err)
+	}
+
+	if _, err := store.GetTask("missing"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("GetTask of a missing task: err = %v", err)
+	}
+	if _, err := store.GetResults("missing"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("GetResults of a missing task: err = %v", err)
+	}
+}
+
+func TestRecoverTasksRequeuesUnfinishedFiles(t *testing.T) {
+	store, err := NewBoltTaskStore(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	unfinished := newTestStoreTask("unfinished", "completed", "pending", "failed", "pending")
+	paused := newTestStoreTask("paused", "pending")
+	paused.Status = "paused"
+	paused.CreatedAt = testStoreTime.Add(time.Second)
+	done := newTestStoreTask("done", "completed")
+	done.Status = "completed"
+	for _, task := range []*Task{unfinished, paused, done} {
+		if err := store.SaveTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &ExtractionService{store: store, queue: newTaskQueue(10), logger: zap.NewNop()}
+	if err := s.RecoverTasks(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.queue.lookup(done.ID) != nil {
+		t.Error("a completed task was recovered")
+	}
+	run := s.queue.lookup(paused.ID)
+	if run == nil || !run.paused || s.queue.position(paused.ID) != 0 {
+		t.Error("the paused task should be recovered without being scheduled")
+	}
+
+	for _, want := range []int{1, 3} {
+		run, index := s.queue.next()
+		if run.task.ID != unfinished.ID || index != want {
+			t.Fatalf("next = %s file %d, want %s file %d", run.task.ID, index, unfinished.ID, want)
+		}
+		if got := run.task.Files[index].Paths; !reflect.DeepEqual(got, unfinished.Files[index].Paths) {
+			t.Errorf("file %d paths = %v, want %v", index, got, unfinished.Files[index].Paths)
+		}
+	}
+	if s.queue.position(unfinished.ID) != 0 {
+		t.Error("files that already finished were re-queued")
+	}
+}
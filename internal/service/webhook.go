@@ -0,0 +1,426 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"contract-key-extractor/internal/config"
+	"contract-key-extractor/internal/model"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	webhookTaskCompleted = "task.completed"
+	webhookTaskCancelled = "task.cancelled"
+	webhookFileFailed    = "file.failed"
+	webhookLowConfidence = "file.low_confidence"
+
+	webhookEventHeader     = "X-Webhook-Event"
+	webhookDeliveryHeader  = "X-Webhook-Delivery"
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+	webhookSignatureHeader = "X-Webhook-Signature"
+
+	defaultWebhookAttempts      = 5
+	defaultWebhookTimeout       = 10 * time.Second
+	defaultWebhookLowConfidence = 0.6
+	webhookInitialBackoff       = time.Second
+	webhookMaxBackoff           = 5 * time.Minute
+)
+
+var errPrivateCallback = errors.New("callback address is not public")
+
+// nonPublicPrefixes are the ranges a callback may not connect to: local,
+// private, shared and reserved networks, multicast, and the IPv6 ranges
+// that embed or translate IPv4 addresses. IPv4-mapped IPv6 addresses are
+// checked as IPv4.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("10.0.0.0/8"),      // private
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // link-local
+	netip.MustParsePrefix("172.16.0.0/12"),   // private
+	netip.MustParsePrefix("192.0.0.0/24"),    // protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("192.168.0.0/16"),  // private
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, broadcast
+
+	netip.MustParsePrefix("::/96"),          // unspecified, loopback, IPv4-compatible
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local NAT64
+	netip.MustParsePrefix("100::/64"),       // discard
+	netip.MustParsePrefix("2001::/23"),      // protocol assignments, Teredo
+	netip.MustParsePrefix("2001:db8::/32"),  // documentation
+	netip.MustParsePrefix("2002::/16"),      // 6to4
+	netip.MustParsePrefix("fc00::/7"),       // unique local
+	netip.MustParsePrefix("fe80::/10"),      // link-local
+	netip.MustParsePrefix("fec0::/10"),      // site-local
+	netip.MustParsePrefix("ff00::/8"),       // multicast
+}
+
+// webhookSender delivers webhook payloads in the background, retrying failed
+// deliveries with exponential backoff and recording every attempt in the
+// task store. Callback URLs given with an upload go through callbackClient,
+// which refuses to connect to non-public addresses; the configured webhooks
+// are trusted.
+//
+// A delivery is stored as pending, with its payload, until it succeeds or
+// runs out of attempts, so that deliveries interrupted by close or a crash
+// can be resumed after a restart.
+type webhookSender struct {
+	cfg            *config.WebhookConfig
+	client         *http.Client
+	callbackClient *http.Client
+	store          TaskStore
+	logger         *zap.Logger
+	attempts       int
+
+	// stop interrupts the waits between attempts; attempts under way are
+	// left to finish.
+	stop    chan struct{}
+	mu      sync.Mutex
+	closed  bool
+	running sync.WaitGroup
+}
+
+func newWebhookSender(cfg *config.WebhookConfig, store TaskStore, logger *zap.Logger) *webhookSender {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	attempts := cfg.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultWebhookAttempts
+	}
+
+	// The address is checked when connecting, after DNS resolution, so a
+	// host that resolved to a public address when the task was created
+	// cannot be rebound to an internal one. Proxies are bypassed, as the
+	// check would otherwise apply to the proxy.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if addr, err := netip.ParseAddr(host); err != nil || !isPublicAddr(addr) {
+				return fmt.Errorf("%w: %s", errPrivateCallback, host)
+			}
+			return nil
+		},
+	}).DialContext
+
+	return &webhookSender{
+		cfg:            cfg,
+		client:         &http.Client{Timeout: timeout},
+		callbackClient: &http.Client{Timeout: timeout, Transport: transport},
+		store:          store,
+		logger:         logger,
+		attempts:       attempts,
+		stop:           make(chan struct{}),
+	}
+}
+
+// CheckCallbackURL reports an error unless raw is an absolute http or https
+// URL whose host resolves only to public addresses. Any address in
+// nonPublicPrefixes would let an API client make the server call internal
+// services.
+func CheckCallbackURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("callback_url must be an absolute http or https URL")
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve callback_url host: %w", err)
+	}
+	for _, addr := range addrs {
+		if ip, ok := netip.AddrFromSlice(addr.IP); !ok || !isPublicAddr(ip) {
+			return fmt.Errorf("%w: %s resolves to %s", errPrivateCallback, u.Hostname(), addr.IP)
+		}
+	}
+	return nil
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	if !addr.IsValid() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// send posts payload to every URL without waiting for the deliveries.
+func (w *webhookSender) send(urls []string, payload model.WebhookPayload) {
+	for _, url := range urls {
+		payload.DeliveryID = uuid.New().String()
+		body, err := json.Marshal(payload)
+		if err != nil {
+			w.logger.Error("failed to encode webhook payload", zap.Error(err))
+			return
+		}
+
+		delivery := &model.WebhookDelivery{
+			ID:        payload.DeliveryID,
+			TaskID:    payload.TaskID,
+			Event:     payload.Event,
+			URL:       url,
+			Pending:   true,
+			CreatedAt: time.Now(),
+			Payload:   body,
+		}
+		w.save(delivery)
+		w.start(delivery)
+	}
+}
+
+// resume restarts the pending deliveries among deliveries, waiting out
+// the rest of their backoff first.
+func (w *webhookSender) resume(deliveries []model.WebhookDelivery) int {
+	resumed := 0
+	for i := range deliveries {
+		delivery := deliveries[i]
+		if !delivery.Pending || delivery.Delivered || len(delivery.Payload) == 0 {
+			continue
+		}
+		w.start(&delivery)
+		resumed++
+	}
+	return resumed
+}
+
+// start delivers in the background, unless the sender is closed; the
+// delivery then stays pending in the store.
+func (w *webhookSender) start(delivery *model.WebhookDelivery) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.running.Add(1)
+	go func() {
+		defer w.running.Done()
+		w.deliver(delivery)
+	}()
+}
+
+// close stops scheduling attempts and waits for those under way. Pending
+// deliveries are resumed by RecoverTasks after a restart.
+func (w *webhookSender) close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.stop)
+	}
+	w.mu.Unlock()
+	w.running.Wait()
+}
+
+func (w *webhookSender) save(delivery *model.WebhookDelivery) {
+	if err := w.store.SaveDelivery(delivery); err != nil {
+		w.logger.Warn("failed to record webhook delivery",
+			zap.String("delivery_id", delivery.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+// webhookBackoff is the wait after the given number of failed attempts.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookInitialBackoff
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, webhookMaxBackoff)
+}
+
+func (w *webhookSender) deliver(delivery *model.WebhookDelivery) {
+	for delivery.Pending {
+		if delivery.Attempts > 0 {
+			timer := time.NewTimer(time.Until(delivery.LastAttempt.Add(webhookBackoff(delivery.Attempts))))
+			select {
+			case <-timer.C:
+			case <-w.stop:
+				timer.Stop()
+				return
+			}
+		}
+
+		retry := w.attempt(delivery.URL, delivery, delivery.Payload)
+		delivery.Pending = !delivery.Delivered && retry && delivery.Attempts < w.attempts
+		w.save(delivery)
+	}
+
+	if !delivery.Delivered {
+		w.logger.Warn("webhook delivery failed",
+			zap.String("task_id", delivery.TaskID),
+			zap.String("event", delivery.Event),
+			zap.String("url", delivery.URL),
+			zap.Int("attempts", delivery.Attempts),
+			zap.String("error", delivery.Error),
+		)
+	}
+}
+
+// attempt makes one delivery attempt and reports whether a failure is worth
+// retrying: network errors, timeouts, 429 and 5xx responses are, but a
+// callback refused for its address is not.
+func (w *webhookSender) attempt(url string, delivery *model.WebhookDelivery, body []byte) bool {
+	delivery.Attempts++
+	delivery.LastAttempt = time.Now()
+	delivery.StatusCode = 0
+	delivery.Error = ""
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return false
+	}
+
+	timestamp := strconv.FormatInt(delivery.LastAttempt.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, delivery.Event)
+	req.Header.Set(webhookDeliveryHeader, delivery.ID)
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	if w.cfg.Secret != "" {
+		req.Header.Set(webhookSignatureHeader, "sha256="+signWebhook(w.cfg.Secret, timestamp, body))
+	}
+
+	client := w.callbackClient
+	if slices.Contains(w.cfg.URLs, url) {
+		client = w.client
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		delivery.Error = err.Error()
+		return !errors.Is(err, errPrivateCallback)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	delivery.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		delivery.Delivered = true
+		return false
+	}
+	delivery.Error = resp.Status
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode >= 500
+}
+
+// signWebhook returns the hex HMAC-SHA256 of "timestamp.body". Receivers
+// recompute it with the shared secret to authenticate a payload, and check
+// the timestamp to reject replays.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookURLs returns the global webhooks followed by the task's callback
+// URL, without duplicates.
+func (s *ExtractionService) webhookURLs(task *Task) []string {
+	var urls []string
+	seen := map[string]bool{}
+	for _, url := range append(append([]string{}, s.cfg.Webhook.URLs...), task.CallbackURL) {
+		if url != "" && !seen[url] {
+			seen[url] = true
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+func webhookPayload(task *Task, event string) model.WebhookPayload {
+	return model.WebhookPayload{
+		Event:      event,
+		TaskID:     task.ID,
+		Status:     task.Status,
+		TotalFiles: task.TotalFiles,
+		Processed:  task.Processed,
+		Failed:     task.Failed,
+		ResultPath: task.ResultPath,
+		Time:       time.Now().Format(time.RFC3339),
+	}
+}
+
+func (s *ExtractionService) notifyTaskFinished(task *Task) {
+	urls := s.webhookURLs(task)
+	if len(urls) == 0 {
+		return
+	}
+
+	event := webhookTaskCompleted
+	if task.Status == "cancelled" {
+		event = webhookTaskCancelled
+	}
+	payload := webhookPayload(task, event)
+	payload.Error = task.Error
+	s.webhooks.send(urls, payload)
+}
+
+// notifyFileDone reports a failed file, or a result whose confidence is
+// below the configured threshold.
+func (s *ExtractionService) notifyFileDone(task *Task, index int, result *model.ExtractionResult, err error) {
+	urls := s.webhookURLs(task)
+	if len(urls) == 0 {
+		return
+	}
+
+	threshold := s.cfg.Webhook.LowConfidence
+	if threshold <= 0 {
+		threshold = defaultWebhookLowConfidence
+	}
+
+	var payload model.WebhookPayload
+	switch {
+	case err != nil:
+		payload = webhookPayload(task, webhookFileFailed)
+		payload.ErrorCategory = errorCategory(err)
+		payload.Error = err.Error()
+	case result.Metadata.OverallConfidence < threshold:
+		payload = webhookPayload(task, webhookLowConfidence)
+		payload.Confidence = result.Metadata.OverallConfidence
+		payload.Result = result
+	default:
+		return
+	}
+
+	payload.FileIndex = &index
+	payload.FileName = filepath.Base(task.Files[index].Paths[0])
+	s.webhooks.send(urls, payload)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"contract-key-extractor/internal/config"
+	"contract-key-extractor/internal/model"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestCheckCallbackURL(t *testing.T) {
+	for _, tc := range []struct {
+		url     string
+		private bool
+		ok      bool
+	}{
+		{"http://93.184.216.34/hook", false, true},
+		{"https://[2606:2800:220:1:248:1893:25c8:1946]/hook", false, true},
+		{"ftp://93.184.216.34/hook", false, false},
+		{"/hook", false, false},
+		{"http://127.0.0.1:8080/hook", true, false},
+		{"http://localhost/hook", true, false},
+		{"http://10.1.2.3/hook", true, false},
+		{"http://192.168.0.10/hook", true, false},
+		{"http://169.254.169.254/latest/meta-data", true, false},
+		{"http://0.0.0.0/hook", true, false},
+		{"http://[::1]/hook", true, false},
+		{"http://[fe80::1]/hook", true, false},
+		{"http://172.16.0.1/hook", true, false},
+		{"http://100.64.0.1/hook", true, false},
+		{"http://198.18.0.1/hook", true, false},
+		{"http://224.0.0.251/hook", true, false},
+		{"http://255.255.255.255/hook", true, false},
+		{"http://[::ffff:127.0.0.1]/hook", true, false},
+		{"http://[::ffff:10.0.0.1]/hook", true, false},
+		{"http://[64:ff9b::a00:1]/hook", true, false},
+		{"http://[2002:a00:1::]/hook", true, false},
+		{"http://[fd00::1]/hook", true, false},
+		{"http://[ff02::1]/hook", true, false},
+	} {
+		err := CheckCallbackURL(context.Background(), tc.url)
+		if (err == nil) != tc.ok || errors.Is(err, errPrivateCallback) != tc.private {
+			t.Errorf("CheckCallbackURL(%q) = %v", tc.url, err)
+		}
+	}
+}
+
+func TestWebhookSenderRefusesPrivateCallbacks(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	store := NewMemoryTaskStore()
+	configured := newWebhookSender(&config.WebhookConfig{URLs: []string{server.URL + "/configured"}}, store, zap.NewNop())
+	for _, tc := range []struct {
+		url       string
+		delivered bool
+	}{
+		{server.URL + "/configured", true},
+		{server.URL + "/callback", false},
+	} {
+		delivery := &model.WebhookDelivery{ID: tc.url, Event: webhookTaskCompleted}
+		retry := configured.attempt(tc.url, delivery, []byte("{}"))
+		if delivery.Delivered != tc.delivered || retry {
+			t.Errorf("%s: delivered = %v, retry = %v, error = %q", tc.url, delivery.Delivered, retry, delivery.Error)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("server was hit %d times, want only by the configured webhook", hits.Load())
+	}
+}
+
+func TestWebhookDeliveriesResumeAfterRestart(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	store := NewMemoryTaskStore()
+	task := &Task{ID: "task-1", Status: "completed"}
+	if err := store.SaveTask(task); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Webhook: config.WebhookConfig{URLs: []string{server.URL}}}
+
+	// The first attempt fails, and close interrupts the wait for the
+	// second.
+	sender := newWebhookSender(&cfg.Webhook, store, zap.NewNop())
+	sender.send([]string{server.URL}, webhookPayload(task, webhookTaskCompleted))
+	waitForDelivery(t, store, task.ID, func(d model.WebhookDelivery) bool { return d.Attempts == 1 })
+	sender.close()
+
+	deliveries, _ := store.GetDeliveries(task.ID)
+	if len(deliveries) != 1 || !deliveries[0].Pending || deliveries[0].Delivered || len(deliveries[0].Payload) == 0 {
+		t.Fatalf("after close: %+v", deliveries)
+	}
+
+	healthy.Store(true)
+	s := &ExtractionService{store: store, queue: newTaskQueue(1), logger: zap.NewNop(), webhooks: newWebhookSender(&cfg.Webhook, store, zap.NewNop())}
+	if err := s.RecoverTasks(); err != nil {
+		t.Fatal(err)
+	}
+	delivery := waitForDelivery(t, store, task.ID, func(d model.WebhookDelivery) bool { return !d.Pending })
+	s.Close()
+
+	if !delivery.Delivered || delivery.Attempts != 2 || hits.Load() != 2 {
+		t.Errorf("after restart: %+v, %d requests", delivery, hits.Load())
+	}
+}
+
+// waitForDelivery polls the task's only delivery until done reports true.
+func waitForDelivery(t *testing.T, store TaskStore, taskID string, done func(model.WebhookDelivery) bool) model.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, err := store.GetDeliveries(taskID)
+		if err == nil && len(deliveries) == 1 && done(deliveries[0]) {
+			return deliveries[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery never reached the expected state: %+v, %v", deliveries, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
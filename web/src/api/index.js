@@ -5,11 +5,14 @@ const api = axios.create({
   timeout: 30000
 })
 
-export const uploadFiles = async (files) => {
+export const uploadFiles = async (files, { imageSet = false } = {}) => {
   const formData = new FormData()
   files.forEach(file => {
     formData.append('files', file)
   })
+  if (imageSet) {
+    formData.append('image_set', 'true')
+  }
   const response = await api.post('/upload', formData, {
     headers: {
       'Content-Type': 'multipart/form-data'
@@ -23,6 +26,21 @@ export const getTaskStatus = async (taskId) => {
   return response.data
 }
 
+export const cancelTask = async (taskId) => {
+  const response = await api.delete(`/task/${taskId}`)
+  return response.data
+}
+
+export const pauseTask = async (taskId) => {
+  const response = await api.post(`/task/${taskId}/pause`)
+  return response.data
+}
+
+export const resumeTask = async (taskId) => {
+  const response = await api.post(`/task/${taskId}/resume`)
+  return response.data
+}
+
 export const getTaskResults = async (taskId) => {
   const response = await api.get(`/task/${taskId}/results`)
   return response.data